@@ -0,0 +1,92 @@
+package gotaskflow
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// TaskInfo describes a task queued or running in executor
+type TaskInfo struct {
+	Name  string    `json:"name"`
+	Graph string    `json:"graph"` // name of taskflow or subflow holding the task
+	RunID uint64    `json:"run_id"`
+	Since time.Time `json:"since"` // when task is queued or started running
+}
+
+// activity keeps tasks being queued and running, so that they are listed without walking whole graphs.
+// It takes a lock on every scheduling step, so executor only keeps it with WithTaskTracking, nil means off.
+type activity struct {
+	queued  map[*innerNode]time.Time
+	running map[*innerNode]time.Time
+	clock   Clock
+	mu      *sync.Mutex
+}
+
+func newActivity() *activity {
+	return &activity{
+		queued:  make(map[*innerNode]time.Time),
+		running: make(map[*innerNode]time.Time),
+		clock:   realClock{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (a *activity) enqueue(n *innerNode) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.queued[n] = a.clock.Now()
+}
+
+func (a *activity) dequeue(n *innerNode) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.queued, n)
+}
+
+// enter moves n from queued to running
+func (a *activity) enter(n *innerNode) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.queued, n)
+	a.running[n] = a.clock.Now()
+}
+
+func (a *activity) leave(n *innerNode) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.running, n)
+}
+
+// list returns running or queued tasks, earliest first
+func (a *activity) list(running bool) []TaskInfo {
+	if a == nil {
+		return []TaskInfo{}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	nodes := a.queued
+	if running {
+		nodes = a.running
+	}
+	res := make([]TaskInfo, 0, len(nodes))
+	for n, since := range nodes {
+		res = append(res, TaskInfo{Name: n.name, Graph: n.g.name, RunID: n.g.runID.Load(), Since: since})
+	}
+	slices.SortFunc(res, func(a, b TaskInfo) int {
+		return a.Since.Compare(b.Since)
+	})
+	return res
+}
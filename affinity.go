@@ -0,0 +1,129 @@
+package gotaskflow
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// affinityKey picks dedicated goroutine of a task, numbered ids are apart from keys of the same text
+type affinityKey struct {
+	key      string
+	id       int
+	numbered bool
+}
+
+// set reports whether task has an affinity
+func (k affinityKey) set() bool {
+	return k.numbered || k.key != ""
+}
+
+// WithAffinity makes task run on a dedicated goroutine of executor shared by tasks of the same key, one at a time,
+// for resources which must be used from one goroutine, like non-thread-safe C libraries. See WithAffinityThreadLock.
+// Subflow tasks ignore affinity, while tasks inside them can have one. Empty key means no affinity.
+// Affine tasks are never taken by pool workers, nor moved between dedicated goroutines, so the goroutine
+// of a key and its OS thread, if locked, are the only ones to run its tasks.
+func (t *Task) WithAffinity(key string) *Task {
+	t.node.affinity = affinityKey{key: key}
+	return t
+}
+
+// Affinity is WithAffinity keyed by number id, for pinning tasks to numbered workers, such as one per C handle.
+// Ids have goroutines of their own, apart from keys of WithAffinity even of the same text. It panics if id is negative.
+func (t *Task) Affinity(id int) *Task {
+	if id < 0 {
+		panic(fmt.Sprintf("affinity of task %v cannot be negative, got %v", t.node.name, id))
+	}
+	t.node.affinity = affinityKey{id: id, numbered: true}
+	return t
+}
+
+// affinityWorkers are dedicated goroutines of executor by affinity
+type affinityWorkers map[affinityKey]*affinityWorker
+
+// affinityWorker runs tasks of an affinity key one by one on a dedicated goroutine
+type affinityWorker struct {
+	id     int // worker id passed to tasks, after ids of pool workers
+	jobs   []func(worker int)
+	closed bool
+	mu     *sync.Mutex
+	cond   *sync.Cond
+	done   chan struct{}
+}
+
+func newAffinityWorker(id int, lockThread bool) *affinityWorker {
+	mu := &sync.Mutex{}
+	w := &affinityWorker{
+		id:   id,
+		jobs: make([]func(worker int), 0),
+		mu:   mu,
+		cond: sync.NewCond(mu),
+		done: make(chan struct{}),
+	}
+	go w.loop(lockThread)
+	return w
+}
+
+func (w *affinityWorker) loop(lockThread bool) {
+	defer close(w.done)
+	if lockThread {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	}
+	for {
+		w.mu.Lock()
+		for len(w.jobs) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.jobs) == 0 {
+			w.mu.Unlock()
+			return
+		}
+		job := w.jobs[0]
+		w.jobs = w.jobs[1:]
+		w.mu.Unlock()
+		job(w.id)
+	}
+}
+
+// submit queues job without blocking
+func (w *affinityWorker) submit(job func(worker int)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.jobs = append(w.jobs, job)
+	w.cond.Signal()
+}
+
+// close stops the goroutine once queued jobs are done
+func (w *affinityWorker) close() {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Signal()
+	w.mu.Unlock()
+	<-w.done
+}
+
+// affine returns the worker of affinity key, starting it on first use
+func (e *innerExecutorImpl) affine(key affinityKey) *affinityWorker {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w, ok := e.affinity[key]
+	if !ok {
+		w = newAffinityWorker(int(e.concurrency)+len(e.affinity), e.lockThreads)
+		e.affinity[key] = w
+	}
+	return w
+}
+
+// Close waits for all tasks, then stops dedicated goroutines of task affinity.
+// Executor can still be used, goroutines are started again on demand.
+func (e *innerExecutorImpl) Close() {
+	e.Wait()
+	e.mu.Lock()
+	workers := e.affinity
+	e.affinity = make(affinityWorkers)
+	e.mu.Unlock()
+	for _, w := range workers {
+		w.close()
+	}
+}
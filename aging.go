@@ -0,0 +1,117 @@
+package gotaskflow
+
+import (
+	"sync"
+	"time"
+
+	"github.com/noneback/go-taskflow/utils"
+)
+
+// workQueue holds scheduled nodes until they are dispatched to pool
+type workQueue interface {
+	Put(node *innerNode)
+	PutWithLimit(node *innerNode, limit int) error // see utils.Queue.PutWithLimit
+	PeakAndTake() *innerNode                       // nil if empty, as loops of graphs sharing the queue race for its nodes
+	Len() int32
+	WaitEmpty()
+}
+
+// agingQueue takes the node of highest effective priority first. Each time a node is passed over for one of
+// higher priority, its effective priority rises by delta, and it rises by one level for every per it has been queued,
+// so that low priority nodes are not starved. Priority gained by time is computed when nodes are taken.
+type agingQueue struct {
+	nodes []agingEntry
+	delta float64
+	per   time.Duration // 0 means not aging by time
+	clock Clock
+	mu    *sync.Mutex
+	empty *sync.Cond
+}
+
+// agingEntry is a queued node and when it is queued
+type agingEntry struct {
+	node *innerNode
+	at   time.Time
+}
+
+func newAgingQueue(delta float64, per time.Duration) *agingQueue {
+	mu := &sync.Mutex{}
+	return &agingQueue{
+		delta: delta,
+		per:   per,
+		clock: realClock{},
+		mu:    mu,
+		empty: sync.NewCond(mu),
+	}
+}
+
+// Put enqueues node with its base priority
+func (q *agingQueue) Put(node *innerNode) {
+	q.PutWithLimit(node, 0)
+}
+
+func (q *agingQueue) PutWithLimit(node *innerNode, limit int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if limit > 0 && len(q.nodes) >= limit {
+		return utils.ErrQueueFull
+	}
+	node.effectivePriority = float64(node.priority)
+	q.nodes = append(q.nodes, agingEntry{node: node, at: q.clock.Now()})
+	return nil
+}
+
+// effective returns priority of queued node at now
+func (q *agingQueue) effective(e agingEntry, now time.Time) float64 {
+	p := e.node.effectivePriority
+	if q.per > 0 {
+		p -= float64(now.Sub(e.at)) / float64(q.per)
+	}
+	return p
+}
+
+// PeakAndTake takes node of highest effective priority, the earliest one among equals, and ages the ones passed over
+func (q *agingQueue) PeakAndTake() *innerNode {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.nodes) == 0 {
+		return nil
+	}
+
+	now := q.clock.Now()
+	best, bestPriority := 0, q.effective(q.nodes[0], now)
+	for i, e := range q.nodes {
+		// smaller value is higher priority
+		if p := q.effective(e, now); p < bestPriority {
+			best, bestPriority = i, p
+		}
+	}
+	node := q.nodes[best].node
+	q.nodes = append(q.nodes[:best], q.nodes[best+1:]...)
+	node.effectivePriority = bestPriority
+
+	for _, e := range q.nodes {
+		if q.effective(e, now) > bestPriority {
+			e.node.effectivePriority -= q.delta
+		}
+	}
+	if len(q.nodes) == 0 {
+		q.empty.Broadcast()
+	}
+	return node
+}
+
+func (q *agingQueue) Len() int32 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int32(len(q.nodes))
+}
+
+// WaitEmpty blocks until queue is empty
+func (q *agingQueue) WaitEmpty() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.nodes) != 0 {
+		q.empty.Wait()
+	}
+}
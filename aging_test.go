@@ -0,0 +1,111 @@
+package gotaskflow
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAgingQueue(t *testing.T) {
+	q := newAgingQueue(0.5, 0)
+	if q.PeakAndTake() != nil {
+		t.Errorf("expected nil from empty queue")
+	}
+	low := newNode("low")
+	low.priority = LOW
+	q.Put(low)
+
+	// keep enqueuing HIGH nodes, low is taken once aged beyond HIGH
+	for i := 0; ; i++ {
+		high := newNode(fmt.Sprint(i))
+		high.priority = HIGH
+		q.Put(high)
+		if q.PeakAndTake() == low {
+			if i != 4 {
+				t.Errorf("expected low to be taken after passed over 4 times, got %v", i)
+			}
+			break
+		}
+		if i > 10 {
+			t.Fatal("low is starved")
+		}
+	}
+
+	if low.effectivePriority != float64(HIGH) {
+		t.Errorf("expected aged priority of HIGH, got %v", low.effectivePriority)
+	}
+	q.Put(low)
+	if low.effectivePriority != float64(LOW) {
+		t.Errorf("expected priority reset once enqueued again, got %v", low.effectivePriority)
+	}
+}
+
+func TestExecutorPriorityAging(t *testing.T) {
+	executor := NewExecutor(2, WithPriorityAging(0.5))
+	tf := NewTaskFlow("G")
+	count := 0
+	prev := NewTask("0", func() { count++ })
+	tf.Push(prev)
+	for i := 1; i < 10; i++ {
+		cur := NewTask(fmt.Sprint(i), func() { count++ }).Priority(TaskPriority(i % 3))
+		prev.Precede(cur)
+		tf.Push(cur)
+		prev = cur
+	}
+	executor.Run(tf).Wait()
+	if count != 10 {
+		t.Errorf("expected 10 tasks run, got %v", count)
+	}
+}
+
+// stepClock moves only when a test sets it
+type stepClock struct {
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time                            { return c.now }
+func (c *stepClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+func (c *stepClock) Sleep(d time.Duration)                     { c.now = c.now.Add(d) }
+
+func TestExecutorPriorityAgingStarvation(t *testing.T) {
+	// a LOW task is queued among a stream of HIGH ones: each completion takes 1ms and releases a fresh HIGH task,
+	// returns how many completions pass before LOW is taken, -1 if it starves
+	run := func(opts ...Option) int {
+		clock := &stepClock{now: time.Unix(0, 0)}
+		e, err := NewExecutorWithOptions(2, append(opts, WithClock(clock))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := e.(*innerExecutorImpl).wq.(*agingQueue)
+		low := newNode("low")
+		low.priority = LOW
+		q.Put(low)
+		for i := 0; ; i++ {
+			high := newNode(fmt.Sprint(i))
+			high.priority = HIGH
+			q.Put(high)
+			if i < 2 {
+				continue
+			}
+			if q.PeakAndTake() == low {
+				return i - 2
+			}
+			if i > 1000 {
+				return -1
+			}
+			clock.Sleep(time.Millisecond)
+		}
+	}
+
+	// a priority queue without aging
+	if at := run(func(e *innerExecutorImpl) { e.wq = newAgingQueue(0, 0) }); at != -1 {
+		t.Errorf("expected low starved without aging, started after %v completions", at)
+	}
+	// LOW catches up with HIGH queued meanwhile after 2 periods
+	if at := run(WithPriorityAgingOverTime(10 * time.Millisecond)); at < 20 || at > 25 {
+		t.Errorf("expected low started within 25 completions, started after %v", at)
+	}
+	if at := run(WithPriorityAging(0.5)); at < 0 || at > 4 {
+		t.Errorf("expected low started within 4 completions, started after %v", at)
+	}
+}
@@ -0,0 +1,73 @@
+package gotaskflow
+
+// NewBarrier returns a task doing nothing, which is a point for other tasks to arrive at, see Task.Arrive
+func NewBarrier(name string) *Task {
+	return NewTask(name, func() {})
+}
+
+// Arrive makes barrier wait for *this*, without *this* being its dependent: barrier becomes ready once its dependents
+// and all tasks arrived at it finish. A subflow task arrives once its handle returns, rather than once its tasks finish,
+// so that a barrier waits for the tasks a subflow builds and makes arrive, but not for the rest of the subflow.
+//
+// Arrive can be called in a subflow handle while taskflow runs. Such late arrivals must be registered before
+// barrier is ready, which holds if the subflow task itself arrives at barrier. A task arriving more than once
+// in a run, like one in a condition loop, is not supported.
+func (t *Task) Arrive(barrier *Task) {
+	b := barrier.node
+	b.rw.Lock()
+	defer b.rw.Unlock()
+	b.arrivals = append(b.arrivals, t.node)
+	t.node.arrives = append(t.node.arrives, b)
+	if b.armed {
+		// counted by join counter already set for this run
+		b.joinCounter.Increase()
+	}
+}
+
+// pending returns how many tasks n waits for in a run: its strong dependents and tasks arriving at it
+func (n *innerNode) pending() int {
+	n.rw.RLock()
+	defer n.rw.RUnlock()
+	return n.waits()
+}
+
+// waits counts strong dependents and arrivals of n, only those in scope of a partial run, see Executor.RunFrom.
+// Caller holds rw of n.
+func (n *innerNode) waits() int {
+	scope := n.g.scope
+	if scope == nil {
+		return n.strongDependents() + len(n.arrivals)
+	}
+	cnt := 0
+	for _, dep := range n.dependents {
+		if _, ok := scope[dep]; ok && dep.Typ != nodeCondition {
+			cnt++
+		}
+	}
+	for _, t := range n.arrivals {
+		if _, ok := scope[t]; ok {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (n *innerNode) strongDependents() int {
+	cnt := 0
+	for _, dep := range n.dependents {
+		if dep.Typ != nodeCondition {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// arrive releases barriers node arrives at, scheduling the ready ones
+func (e *innerExecutorImpl) arrive(node *innerNode) {
+	for _, b := range node.arrives {
+		if node.release(b) == 0 {
+			b.live.Store(true)
+			e.schedule(b)
+		}
+	}
+}
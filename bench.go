@@ -0,0 +1,171 @@
+package gotaskflow
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kBenchSampleInterval is how often Benchmark samples work queue and pool while a run is going on
+const kBenchSampleInterval = time.Millisecond
+
+// DurationStats summarizes durations of runs
+type DurationStats struct {
+	Min  time.Duration `json:"min"`
+	Max  time.Duration `json:"max"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P95  time.Duration `json:"p95"`
+}
+
+func newDurationStats(ds []time.Duration) DurationStats {
+	if len(ds) == 0 {
+		return DurationStats{}
+	}
+	ds = slices.Clone(ds)
+	slices.Sort(ds)
+	var total time.Duration
+	for _, d := range ds {
+		total += d
+	}
+	return DurationStats{
+		Min:  ds[0],
+		Max:  ds[len(ds)-1],
+		Mean: total / time.Duration(len(ds)),
+		P50:  ds[(len(ds)-1)*50/100],
+		P95:  ds[(len(ds)-1)*95/100],
+	}
+}
+
+func (s DurationStats) String() string {
+	return fmt.Sprintf("min %v  mean %v  p50 %v  p95 %v  max %v", s.Min, s.Mean, s.P50, s.P95, s.Max)
+}
+
+// NodeBench is durations of a task over iterations of Benchmark, a task skipped in an iteration is not counted
+type NodeBench struct {
+	Name string `json:"name"`
+	Runs int    `json:"runs"`
+	DurationStats
+}
+
+// BenchReport is the result of Benchmark, which can be marshaled to JSON to track regressions
+type BenchReport struct {
+	Flow        string        `json:"flow"`
+	Concurrency uint          `json:"concurrency"`
+	Iterations  int           `json:"iterations"`
+	Wall        DurationStats `json:"wall"`        // wall time of an iteration
+	Nodes       []NodeBench   `json:"nodes"`       // top level tasks sorted by name, a subflow costs its whole run
+	PeakQueued  int           `json:"peak_queued"` // most tasks seen waiting in work queue
+	// Utilization is the average fraction of pool workers seen busy while running, in [0, 1].
+	// It is sampled from Executor.RunningTasks, so it is 0 unless exec has WithTaskTracking
+	Utilization float64 `json:"utilization"`
+}
+
+// Benchmark runs tf iterations times by exec one after another, collecting wall time of each iteration, durations
+// of each task, and work queue depth and pool utilization sampled every millisecond. Other taskflows run by exec
+// meanwhile are counted in samples, so exec should be dedicated to it. It stops at the first failed iteration.
+func Benchmark(exec Executor, tf *TaskFlow, iterations int) (BenchReport, error) {
+	if iterations <= 0 {
+		return BenchReport{}, fmt.Errorf("benchmark iterations must be positive, got %v", iterations)
+	}
+	concurrency := exec.Stats().Concurrency
+	report := BenchReport{Flow: tf.Name(), Concurrency: concurrency, Iterations: iterations}
+	walls := make([]time.Duration, 0, iterations)
+	costs := make(map[string][]time.Duration)
+	var busy float64
+	samples := 0
+
+	for i := 0; i < iterations; i++ {
+		stop := make(chan struct{})
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(kBenchSampleInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					report.PeakQueued = max(report.PeakQueued, exec.Stats().Queued)
+					busy += min(1, float64(len(exec.RunningTasks()))/float64(concurrency))
+					samples++
+				}
+			}
+		}()
+
+		begin := time.Now()
+		exec.Run(tf).Wait()
+		walls = append(walls, time.Since(begin))
+		close(stop)
+		wg.Wait()
+
+		if err := tf.graph.err(); err != nil {
+			return BenchReport{}, fmt.Errorf("benchmark %v failed at iteration %v -> %w", tf.Name(), i, err)
+		}
+		tf.graph.costsMu.Lock()
+		for node, c := range tf.graph.costs {
+			costs[node.name] = append(costs[node.name], c.cost)
+		}
+		tf.graph.costsMu.Unlock()
+	}
+
+	report.Wall = newDurationStats(walls)
+	report.Nodes = make([]NodeBench, 0, len(costs))
+	for name, ds := range costs {
+		report.Nodes = append(report.Nodes, NodeBench{Name: name, Runs: len(ds), DurationStats: newDurationStats(ds)})
+	}
+	slices.SortFunc(report.Nodes, func(a, b NodeBench) int { return cmp.Compare(a.Name, b.Name) })
+	if samples > 0 {
+		report.Utilization = busy / float64(samples)
+	}
+	return report, nil
+}
+
+func (r BenchReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "benchmark %v: %v iterations, concurrency %v\n", r.Flow, r.Iterations, r.Concurrency)
+	fmt.Fprintf(&sb, "wall: %v\n", r.Wall)
+	fmt.Fprintf(&sb, "peak queued: %v  utilization: %.1f%%\n", r.PeakQueued, r.Utilization*100)
+	for _, n := range r.Nodes {
+		fmt.Fprintf(&sb, "  %v (%v runs): %v\n", n.Name, n.Runs, n.DurationStats)
+	}
+	return sb.String()
+}
+
+// Regression is a task whose mean duration grew over a baseline, see BenchReport.Compare
+type Regression struct {
+	Name     string        `json:"name"`
+	Baseline time.Duration `json:"baseline"`
+	Current  time.Duration `json:"current"`
+	Slower   float64       `json:"slower"` // growth over baseline, 0.5 means 50% slower
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%v: %v -> %v (+%.1f%%)", r.Name, r.Baseline, r.Current, r.Slower*100)
+}
+
+// Compare returns tasks of r whose mean duration is more than threshold slower than in baseline,
+// such as 0.1 for 10%, largest growth first. Tasks missing in either report are ignored.
+func (r BenchReport) Compare(baseline BenchReport, threshold float64) []Regression {
+	base := make(map[string]time.Duration, len(baseline.Nodes))
+	for _, n := range baseline.Nodes {
+		base[n.Name] = n.Mean
+	}
+	regs := make([]Regression, 0)
+	for _, n := range r.Nodes {
+		b, ok := base[n.Name]
+		if !ok || b <= 0 {
+			continue
+		}
+		if slower := float64(n.Mean-b) / float64(b); slower > threshold {
+			regs = append(regs, Regression{Name: n.Name, Baseline: b, Current: n.Mean, Slower: slower})
+		}
+	}
+	slices.SortFunc(regs, func(a, b Regression) int { return cmp.Compare(b.Slower, a.Slower) })
+	return regs
+}
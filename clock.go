@@ -0,0 +1,26 @@
+package gotaskflow
+
+import "time"
+
+// Clock tells time to executor: span durations, timeouts, soft deadlines, taskflow deadlines and timestamps of
+// progress events and panic reports. It is the real clock by default, and a fake one in tests, see WithClock.
+// Samples of WithSampling and Benchmark always take the real clock.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f on a goroutine of its own once d elapses, unless the returned Timer is stopped ahead
+	AfterFunc(d time.Duration, f func()) Timer
+	Sleep(d time.Duration)
+}
+
+// Timer is a pending call of Clock.AfterFunc
+type Timer interface {
+	// Stop prevents the call, reporting false if it has been made or stopped already
+	Stop() bool
+}
+
+// realClock is Clock of package time
+type realClock struct{}
+
+func (realClock) Now() time.Time                            { return time.Now() }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+func (realClock) Sleep(d time.Duration)                     { time.Sleep(d) }
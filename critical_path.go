@@ -0,0 +1,219 @@
+package gotaskflow
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/noneback/go-taskflow/utils"
+)
+
+// SpanInfo is a read-only view of a recorded span
+type SpanInfo struct {
+	Name  string
+	Type  string
+	Begin time.Time
+	Cost  time.Duration
+	RunID uint64
+	// OverDeadline reports whether task ran over its soft deadline, see Task.WithSoftDeadline
+	OverDeadline bool
+}
+
+func (s *span) info() SpanInfo {
+	return SpanInfo{
+		Name:         s.extra.name,
+		Type:         string(s.extra.typ),
+		Begin:        s.begin,
+		Cost:         s.cost,
+		RunID:        s.extra.run,
+		OverDeadline: s.overDeadline,
+	}
+}
+
+// criticalPath finds the most costly dependency chain among recorded spans.
+// Only executed nodes have spans, so untaken condition branches are never on the path.
+func (t *profiler) criticalPath(expandSubflow bool) []SpanInfo {
+	t.mu.Lock()
+	byNode := make(map[*innerNode]*span, len(t.spans))
+	roots := make([]*eGraph, 0)
+	for _, s := range t.spans {
+		if s.node == nil {
+			continue
+		}
+		// latest run of node
+		if prev, ok := byNode[s.node]; ok && prev.begin.After(s.begin) {
+			continue
+		}
+		byNode[s.node] = s
+		if s.parent == nil && !slices.Contains(roots, s.node.g) {
+			roots = append(roots, s.node.g)
+		}
+	}
+	t.mu.Unlock()
+
+	var best []*span
+	var bestCost time.Duration
+	for _, g := range roots {
+		path, cost := longestPath(g, byNode, expandSubflow)
+		if cost > bestCost || best == nil {
+			best, bestCost = path, cost
+		}
+	}
+
+	infos := make([]SpanInfo, 0, len(best))
+	for _, s := range best {
+		infos = append(infos, s.info())
+	}
+	return infos
+}
+
+// longestPath returns the most costly chain in g. A subflow node weighs its own cost plus the chain of its graph,
+// which is inserted after the subflow node if expand. Edges are only followed forward in time,
+// which breaks cycles introduced by condition loops.
+func longestPath(g *eGraph, byNode map[*innerNode]*span, expand bool) ([]*span, time.Duration) {
+	executed := make([]*innerNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		if _, ok := byNode[node]; ok {
+			executed = append(executed, node)
+		}
+	}
+	slices.SortStableFunc(executed, func(a, b *innerNode) int {
+		return byNode[a].begin.Compare(byNode[b].begin)
+	})
+
+	inner := make(map[*innerNode][]*span)
+	dist := make(map[*innerNode]time.Duration, len(executed))
+	prev := make(map[*innerNode]*innerNode, len(executed))
+	var last *innerNode
+
+	for _, node := range executed {
+		s := byNode[node]
+		weight := s.cost
+		if p, ok := node.ptr.(*Subflow); ok {
+			path, cost := longestPath(p.g, byNode, expand)
+			if expand {
+				inner[node] = path
+			}
+			weight += cost
+		}
+
+		dist[node] = weight
+		for _, dep := range node.dependents {
+			d, ok := dist[dep]
+			if !ok || dep == node || byNode[dep].begin.After(s.begin) {
+				continue
+			}
+			if d+weight > dist[node] {
+				dist[node] = d + weight
+				prev[node] = dep
+			}
+		}
+
+		if last == nil || dist[node] > dist[last] {
+			last = node
+		}
+	}
+
+	if last == nil {
+		return nil, 0
+	}
+
+	chain := make([]*innerNode, 0)
+	for cur := last; cur != nil; cur = prev[cur] {
+		chain = append(chain, cur)
+	}
+	slices.Reverse(chain)
+
+	path := make([]*span, 0, len(chain))
+	for _, node := range chain {
+		path = append(path, byNode[node])
+		path = append(path, inner[node]...)
+	}
+	return path, dist[last]
+}
+
+func writeCriticalPath(w io.Writer, path []SpanInfo) error {
+	var total time.Duration
+	for i, s := range path {
+		total += s.Cost
+		msg := fmt.Sprintf("%d. %s,%s,cost %v\n", i+1, s.Type, s.Name, utils.NormalizeDuration(s.Cost))
+		if _, err := w.Write([]byte(msg)); err != nil {
+			return fmt.Errorf("write critical path -> %w", err)
+		}
+	}
+
+	if _, err := w.Write([]byte(fmt.Sprintf("total %v\n", utils.NormalizeDuration(total)))); err != nil {
+		return fmt.Errorf("write critical path -> %w", err)
+	}
+	return nil
+}
+
+// nodeCost is how long a node ran, and when it first finished in a run, as the number of nodes finished before it
+type nodeCost struct {
+	cost time.Duration
+	seq  int
+}
+
+// recordCost records a finished run of node costing d, a node run more than once in a loop weighs its longest run
+func (g *eGraph) recordCost(node *innerNode, d time.Duration) {
+	g.costsMu.Lock()
+	defer g.costsMu.Unlock()
+	c, ok := g.costs[node]
+	if !ok {
+		c.seq = len(g.costs)
+	}
+	c.cost = max(c.cost, d)
+	g.costs[node] = c
+}
+
+// CriticalPath returns the chain of tasks costing most in the latest run of tf, and its total cost, which limits
+// latency of the run. A subflow is a single task costing its whole run. Unlike Executor.CriticalPath,
+// it is kept by tf itself, and is not affected by profiling options. Edges are only followed forward in time,
+// which breaks cycles introduced by condition loops.
+func (tf *TaskFlow) CriticalPath() ([]*Task, time.Duration) {
+	g := tf.graph
+	g.costsMu.Lock()
+	costs := maps.Clone(g.costs)
+	g.costsMu.Unlock()
+
+	executed := make([]*innerNode, 0, len(costs))
+	for node := range costs {
+		executed = append(executed, node)
+	}
+	slices.SortFunc(executed, func(a, b *innerNode) int {
+		return cmp.Compare(costs[a].seq, costs[b].seq)
+	})
+
+	dist := make(map[*innerNode]time.Duration, len(executed))
+	prev := make(map[*innerNode]*innerNode, len(executed))
+	var last *innerNode
+	for _, node := range executed {
+		dist[node] = costs[node].cost
+		for _, dep := range node.dependents {
+			d, ok := dist[dep]
+			if !ok || dep == node {
+				continue
+			}
+			if d+costs[node].cost > dist[node] {
+				dist[node] = d + costs[node].cost
+				prev[node] = dep
+			}
+		}
+		if last == nil || dist[node] > dist[last] {
+			last = node
+		}
+	}
+	if last == nil {
+		return nil, 0
+	}
+
+	path := make([]*Task, 0)
+	for cur := last; cur != nil; cur = prev[cur] {
+		path = append(path, &Task{node: cur})
+	}
+	slices.Reverse(path)
+	return path, dist[last]
+}
@@ -0,0 +1,84 @@
+// Package debug serves state of a running executor over http, for inspecting services embedding go-taskflow.
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	gotaskflow "github.com/noneback/go-taskflow"
+)
+
+// NewDebugHandler returns a handler serving:
+//
+//	GET  /stats              executor stats in json
+//	GET  /graph              running taskflows in dot format
+//	GET  /profile            flame graph raw text
+//	POST /cancel?graph=name  cancel running taskflows named name
+func NewDebugHandler(e gotaskflow.Executor) http.Handler {
+	h := &handler{e: e}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", method(http.MethodGet, h.stats))
+	mux.HandleFunc("/graph", method(http.MethodGet, h.graph))
+	mux.HandleFunc("/profile", method(http.MethodGet, h.profile))
+	mux.HandleFunc("/cancel", method(http.MethodPost, h.cancel))
+	return mux
+}
+
+type handler struct {
+	e gotaskflow.Executor
+}
+
+func method(m string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			w.Header().Set("Allow", m)
+			http.Error(w, fmt.Sprintf("method %v not allowed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		f(w, r)
+	}
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.e.Stats()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) graph(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	for _, tf := range h.e.Running() {
+		if err := gotaskflow.Visualize(tf, &buf); err != nil {
+			http.Error(w, fmt.Sprintf("visualize %v -> %v", tf.Name(), err), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	w.Write(buf.Bytes())
+}
+
+func (h *handler) profile(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := h.e.Profile(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func (h *handler) cancel(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("graph")
+	if name == "" {
+		http.Error(w, "missing graph", http.StatusBadRequest)
+		return
+	}
+	if err := h.e.CancelGraph(name); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
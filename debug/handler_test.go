@@ -0,0 +1,86 @@
+package debug_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gotaskflow "github.com/noneback/go-taskflow"
+	"github.com/noneback/go-taskflow/debug"
+)
+
+func TestDebugHandler(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	srv := httptest.NewServer(debug.NewDebugHandler(executor))
+	defer srv.Close()
+
+	tf := gotaskflow.NewTaskFlow("G")
+	started, release := make(chan struct{}), make(chan struct{})
+	A := gotaskflow.NewTask("A", func() {
+		close(started)
+		<-release
+	})
+	B := gotaskflow.NewTask("B", func() {})
+	A.Precede(B)
+	tf.Push(A, B)
+
+	done := make(chan struct{})
+	go func() {
+		executor.Run(tf).Wait()
+		close(done)
+	}()
+	<-started
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	code, body := get("/stats")
+	stats := gotaskflow.ExecutorStats{}
+	if err := json.Unmarshal([]byte(body), &stats); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected stats %v %v", code, body)
+	}
+	if len(stats.Flows) != 1 || stats.Flows[0] != "G" || stats.InFlight != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if code, body := get("/graph"); code != http.StatusOK || !strings.Contains(body, "digraph") {
+		t.Errorf("unexpected graph %v %v", code, body)
+	}
+	if code, _ := get("/cancel?graph=G"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected cancel to require post, got %v", code)
+	}
+
+	resp, err := http.Post(srv.URL+"/cancel?graph=missing", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected not found, got %v", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/cancel?graph=G", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected canceled, got %v", resp.StatusCode)
+	}
+	close(release)
+	<-done
+
+	if code, body := get("/profile"); code != http.StatusOK || !strings.Contains(body, "static,A") {
+		t.Errorf("unexpected profile %v %v", code, body)
+	}
+}
@@ -0,0 +1,97 @@
+package gotaskflow
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Edge is a dependency between two tasks, identified by name
+type Edge struct {
+	From string
+	To   string
+}
+
+// GraphDiff is the structural difference between two taskflows, every field is sorted
+type GraphDiff struct {
+	AddedNodes   []string
+	RemovedNodes []string
+	AddedEdges   []Edge
+	RemovedEdges []Edge
+}
+
+// Empty reports whether two taskflows have the same topology
+func (d GraphDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0
+}
+
+func (d GraphDiff) String() string {
+	var sb strings.Builder
+	for _, n := range d.AddedNodes {
+		fmt.Fprintf(&sb, "+ node %s\n", n)
+	}
+	for _, n := range d.RemovedNodes {
+		fmt.Fprintf(&sb, "- node %s\n", n)
+	}
+	for _, e := range d.AddedEdges {
+		fmt.Fprintf(&sb, "+ edge %s -> %s\n", e.From, e.To)
+	}
+	for _, e := range d.RemovedEdges {
+		fmt.Fprintf(&sb, "- edge %s -> %s\n", e.From, e.To)
+	}
+	return sb.String()
+}
+
+// Diff compares topology of taskflow a and b by task names, reporting what b adds to or removes from a.
+// Only top level tasks are compared, subflows are compared as single tasks.
+func Diff(a, b *TaskFlow) GraphDiff {
+	nodesA, edgesA := a.graph.structure()
+	nodesB, edgesB := b.graph.structure()
+
+	return GraphDiff{
+		AddedNodes:   subtract(nodesB, nodesA, cmp.Compare[string]),
+		RemovedNodes: subtract(nodesA, nodesB, cmp.Compare[string]),
+		AddedEdges:   subtract(edgesB, edgesA, compareEdge),
+		RemovedEdges: subtract(edgesA, edgesB, compareEdge),
+	}
+}
+
+func compareEdge(a, b Edge) int {
+	if c := cmp.Compare(a.From, b.From); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.To, b.To)
+}
+
+// structure returns names of nodes and edges of g
+func (g *eGraph) structure() ([]string, []Edge) {
+	topo := g.topology()
+	nodes := make([]string, 0, len(topo.Nodes))
+	edges := make([]Edge, 0, len(topo.Edges))
+	for _, n := range topo.Nodes {
+		nodes = append(nodes, n.Name)
+	}
+	for _, e := range topo.Edges {
+		edges = append(edges, Edge{From: e.From, To: e.To})
+	}
+	return nodes, edges
+}
+
+// subtract returns sorted distinct elements of a which are not in b
+func subtract[T comparable](a, b []T, compare func(x, y T) int) []T {
+	exclude := make(map[T]struct{}, len(b))
+	for _, v := range b {
+		exclude[v] = struct{}{}
+	}
+
+	res := make([]T, 0)
+	for _, v := range a {
+		if _, ok := exclude[v]; !ok {
+			res = append(res, v)
+			exclude[v] = struct{}{}
+		}
+	}
+	slices.SortFunc(res, compare)
+	return res
+}
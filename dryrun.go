@@ -0,0 +1,61 @@
+package gotaskflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TopologicalOrder returns names of tasks of tf in an order they may run, layer by layer, see Layers.
+// Unlike Layers, a condition loop does not fail it, the edge closing a loop is left out. Subflows are single tasks.
+func (tf *TaskFlow) TopologicalOrder() []string {
+	order := make([]string, 0, len(tf.graph.nodes))
+	for _, layer := range tf.graph.rankLayers() {
+		order = append(order, nodeNames(layer)...)
+	}
+	return order
+}
+
+// unreachable returns nodes of g which no entry leads to, such as ones on a cycle without condition
+func (g *eGraph) unreachable() []*innerNode {
+	reached := make(map[*innerNode]struct{}, len(g.nodes))
+	for _, n := range g.nodes {
+		if len(n.dependents) != 0 {
+			continue
+		}
+		for r := range reach(n) {
+			reached[r] = struct{}{}
+		}
+	}
+	nodes := make([]*innerNode, 0)
+	for _, n := range g.nodes {
+		if _, ok := reached[n]; !ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// DryRun checks tf as Validate does, and for tasks no entry leads to, then logs layers tasks would run in,
+// following TopologicalOrder, without running any handle, subflow handles included. It changes no state of tf,
+// so it is safe before a real run, such as in CI. It returns what checks found, joined.
+func (e *innerExecutorImpl) DryRun(tf *TaskFlow) error {
+	errs := make([]error, 0)
+	if err := tf.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if nodes := tf.graph.unreachable(); len(nodes) > 0 {
+		errs = append(errs, fmt.Errorf("tasks %v in %v are never run, no entry leads to them", nodeNames(nodes), tf.name))
+	}
+
+	rep := e.reporter()
+	for i, layer := range tf.graph.rankLayers() {
+		names := nodeNames(layer)
+		rep.log("dry run", fmt.Sprintf("taskflow %v layer %d: %v", tf.name, i, strings.Join(names, ", ")),
+			"graph", tf.name, "layer", i, "tasks", names)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("dry run of %v -> %w", tf.name, err)
+	}
+	return nil
+}
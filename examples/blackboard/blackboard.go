@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+
+	gotaskflow "github.com/noneback/go-taskflow"
+)
+
+func main() {
+	executor := gotaskflow.NewExecutor(uint(runtime.NumCPU()))
+	tf := gotaskflow.NewTaskFlow("G")
+
+	// fetch stores its status code in the store of the run, instead of a variable shared by closures
+	fetch := gotaskflow.NewControlledTask("fetch", func(tc gotaskflow.TaskControl) {
+		status := []int{200, 404}[rand.Intn(2)]
+		fmt.Println("fetched with status", status)
+		tc.Set("status", status)
+	})
+	// route branches on what fetch stored
+	route := gotaskflow.NewRuntimeCondition("route", func(rt *gotaskflow.Runtime) uint {
+		if status, _ := rt.Get("status"); status == 200 {
+			return 0
+		}
+		return 1
+	})
+	parse := gotaskflow.NewTask("parse", func() {
+		fmt.Println("parse body")
+	})
+	fallback := gotaskflow.NewTask("fallback", func() {
+		fmt.Println("use cached body")
+	})
+
+	fetch.Precede(route)
+	route.Precede(parse, fallback)
+	tf.Push(fetch, route, parse, fallback)
+
+	executor.Run(tf).Wait()
+}
@@ -2,11 +2,17 @@ package gotaskflow
 
 import (
 	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"runtime/debug"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/noneback/go-taskflow/utils"
@@ -14,193 +20,985 @@ import (
 
 // Executor schedule and execute taskflow
 type Executor interface {
-	Wait()                     // Wait block until all tasks finished
-	Profile(w io.Writer) error // Profile write flame graph raw text into w
-	Run(tf *TaskFlow) Executor // Run start to schedule and execute taskflow
+	Wait()                                        // Wait block until all tasks finished
+	Profile(w io.Writer, runs ...uint64) error    // Profile write flame graph raw text into w, only of runs if any given
+	Run(tf *TaskFlow, opts ...RunOption) Executor // Run start to schedule and execute taskflow
+	Cancel()                                      // Cancel stop scheduling tasks of all running taskflows, running tasks are not interrupted
+	// RunUntilSignal run taskflow and wait, cancel it gracefully once one of sig is received. os.Interrupt by default
+	RunUntilSignal(tf *TaskFlow, sig ...os.Signal) Executor
+	// CriticalPath returns the chain of executed tasks which costs most, subflows are expanded into their inner tasks if expandSubflow
+	CriticalPath(expandSubflow bool) []SpanInfo
+	WriteCriticalPath(w io.Writer) error // WriteCriticalPath write expanded critical path in a readable format into w
+	// ProfileTimeline write a timeline of recorded spans into w, one row per pool worker or per topological layer by format
+	ProfileTimeline(w io.Writer, format TimelineFormat) error
+	Progress() <-chan ProgressEvent // Progress returns channel of task progress events, events are dropped if it is full
+	ProgressDropped() uint64        // ProgressDropped returns how many progress events are dropped
+	Drain() error                   // Drain block until all scheduled tasks are picked up from work queue, running tasks are not waited
+	ResetProfile()                  // ResetProfile drops spans recorded so far, so that profiles only reflect later runs
+	CancelGraph(name string) error  // CancelGraph stop scheduling tasks of running taskflows named name, running tasks are not interrupted
+	Running() []*TaskFlow           // Running returns taskflows being run, in no particular order
+	RunningTasks() []TaskInfo       // RunningTasks returns tasks being run, earliest started first, subflow tasks included, see WithTaskTracking
+	QueuedTasks() []TaskInfo        // QueuedTasks returns tasks scheduled but not started yet, earliest queued first, see WithTaskTracking
+	Stats() ExecutorStats           // Stats returns a snapshot of executor state
+	// NodeStats returns runs of tasks named name accumulated over the lifetime of executor, which ResetProfile keeps
+	NodeStats(name string) NodeRunStats
+	// RunAsync start to schedule and execute taskflow in background, returns a handle to wait for its completion
+	RunAsync(tf *TaskFlow, opts ...RunOption) *RunHandle
+	// SubmitOnce runs taskflow in background like RunAsync, unless it is already running by SubmitOnce, then it returns nil.
+	// A finished taskflow is reset and run again.
+	SubmitOnce(tf *TaskFlow, opts ...RunOption) *RunHandle
+	Spans(runs ...uint64) []SpanInfo // Spans returns recorded spans ordered by begin time, only of runs if any given
+	Close()                          // Close waits for all tasks, then stops dedicated goroutines of task affinity
+	// ProfileFiltered is Profile of tasks labeled by any of labels, see Task.WithLabel. It is Profile if no label is given
+	ProfileFiltered(w io.Writer, labels ...string) error
+	// RunFrom runs only start and tasks reachable from it, such as to resume a failed run without running upstream tasks again
+	RunFrom(tf *TaskFlow, start *Task, opts ...RunOption) Executor
+	// ProfileRun is Profile of run runID alone, such as one of taskflows run concurrently, see Task.RunID and WithRunID
+	ProfileRun(runID uint64, w io.Writer) error
+	// RunAll runs taskflows concurrently as a batch in background, Wait blocks until all of them are done
+	RunAll(tfs ...*TaskFlow) Executor
+	// Errors returns failures of taskflows in the latest RunAll batch, each tagged with taskflow name. It is valid after Wait
+	Errors() []error
+	// Unfinished returns tasks which did not finish in taskflows run until the latest Wait, subflow tasks included. It is valid after Wait
+	Unfinished() []*Task
+	// WaitErr blocks like Wait, then returns failures of taskflows run until then, see WithErrorPolicy
+	WaitErr() error
+	// RunOnce runs taskflow and returns its failure, callers arriving while it runs wait for it and share its result
+	RunOnce(tf *TaskFlow) error
+	Pause()  // Pause stops dispatching queued tasks until Resume, running tasks finish
+	Resume() // Resume dispatches queued tasks again after Pause
+	// DryRun validates taskflow and logs the order its tasks would run in, without running any of them
+	DryRun(tf *TaskFlow) error
 }
 
 type innerExecutorImpl struct {
-	concurrency uint                     // 最大并发数
-	pool        *utils.Copool            // 协程池
-	wq          *utils.Queue[*innerNode] // 工作队列
-	wg          *sync.WaitGroup          // 等待组
-	profiler    *profiler                // 性能分析器
+	concurrency    uint                  // 最大并发数
+	pool           *utils.Copool         // 协程池
+	wq             workQueue             // 工作队列
+	wg             *sync.WaitGroup       // 等待组
+	profiler       *profiler             // 性能分析器
+	flows          map[*eGraph]*TaskFlow // 正在运行的顶层图
+	mu             *sync.Mutex
+	limits         map[nodeType]*limiter // 按任务类型的并发限制
+	tagLimits      map[string]*limiter   // 按标签的并发限制
+	coalesce       bool                  // 合并静态任务链
+	progress       *progress             // 进度事件
+	observers      []Observer
+	releaseHandles bool                        // 任务完成后释放闭包
+	policy         SchedulePolicy              // 协程池出队顺序
+	strict         bool                        // 检查计数是否平衡
+	runs           atomic.Uint64               // 最近分配的运行编号
+	sampler        *sampler                    // 采样模式下记录运行中的任务, nil 表示精确模式
+	logger         io.Writer                   // 日志输出
+	errs           []error                     // 无效的选项
+	locals         map[reflect.Type]func() any // 工作协程本地存储的工厂
+	affinity       affinityWorkers             // 按亲和键的专用协程
+	flights        *flights                    // 按 singleflight 键正在运行的任务
+	paused         atomic.Bool                 // 暂停分发队列中的任务
+	loops          *loops                      // 正在调度的图, Resume 时唤醒
+	lockThreads    bool                        // 专用协程绑定系统线程
+	activity       *activity                   // 排队中和运行中的任务, nil 表示不跟踪
+	stats          *nodeStats                  // 按任务名累计的运行统计, 从不重置
+	stack          StackPolicy                 // panic 时保留的调用栈
+	maxDepth       int                         // 子流最大嵌套深度
+	maxQueue       int                         // 工作队列最大深度, 0 表示不限
+	slog           *slog.Logger                // 结构化日志, 设置后替代 logger
+	strictStates   bool                        // 非法状态转换时 panic
+	disallowed     []string                    // 任务函数不允许来自的包
+	clock          Clock                       // 时钟, 测试中可替换
+	runGate        *runGate                    // 多个顶层图同时运行时的策略
+	errPolicy      ErrorPolicy                 // 失败取消的范围, 以及报告哪些失败
+	batch          []*RunHandle                // 最近一次 RunAll 提交的运行
+	batches        *sync.WaitGroup             // 未完成的 RunAll 批次
+	ran            []*eGraph                   // 上次 Wait 之后运行完的图
+	running        *sync.WaitGroup             // 正在运行的顶层图, Wait 等待其收尾
+	settled        []*eGraph                   // 上次 Wait 之前运行完的图, 供 Unfinished 使用
 }
 
-// NewExecutor return a Executor with a specified max goroutine concurrency(recommend a value bigger than Runtime.NumCPU)
-func NewExecutor(concurrency uint) Executor {
+// kMaxConcurrency bounds executor concurrency checked by NewExecutorWithOptions, far beyond any useful value,
+// to catch misconfigured ones such as computed from config
+const kMaxConcurrency = 1 << 20
+
+// NewExecutor return a Executor with a specified max goroutine concurrency(recommend a value bigger than Runtime.NumCPU).
+// runtime.NumCPU suits CPU bound tasks, while tasks blocking on IO make use of a few times of it,
+// see TaskFlow.RecommendedConcurrency for a bound by graph structure.
+// It panics if concurrency is zero or options are invalid, see NewExecutorWithOptions.
+func NewExecutor(concurrency uint, opts ...Option) Executor {
+	e, err := newExecutor(concurrency, opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return e
+}
+
+// NewExecutorWithOptions is NewExecutor returning an error instead of panicking, for invalid concurrency or invalid or
+// conflicting options. Concurrency above 1<<20 is taken as misconfigured, such as computed at runtime from config.
+func NewExecutorWithOptions(concurrency uint, opts ...Option) (Executor, error) {
+	if concurrency > kMaxConcurrency {
+		return nil, fmt.Errorf("executor concurrency %v exceeds %v", concurrency, kMaxConcurrency)
+	}
+	return newExecutor(concurrency, opts...)
+}
+
+func newExecutor(concurrency uint, opts ...Option) (Executor, error) {
 	if concurrency == 0 {
-		panic("executor concrurency cannot be zero")
+		return nil, errors.New("executor concrurency cannot be zero")
 	}
 	t := newProfiler()
-	return &innerExecutorImpl{
+	e := &innerExecutorImpl{
 		concurrency: concurrency,
 		pool:        utils.NewCopool(concurrency),
 		wq:          utils.NewQueue[*innerNode](),
 		wg:          &sync.WaitGroup{},
+		batches:     &sync.WaitGroup{},
+		running:     &sync.WaitGroup{},
 		profiler:    t,
+		flows:       make(map[*eGraph]*TaskFlow),
+		mu:          &sync.Mutex{},
+		limits:      make(map[nodeType]*limiter),
+		tagLimits:   make(map[string]*limiter),
+		progress:    newProgress(kDefaultProgressBuffer),
+		logger:      panicOutput,
+		locals:      make(map[reflect.Type]func() any),
+		affinity:    make(affinityWorkers),
+		flights:     newFlights(),
+		loops:       newLoops(),
+		stats:       newNodeStats(),
+		stack:       StackFull,
+		maxDepth:    kDefaultMaxSubflowDepth,
+		clock:       realClock{},
+		runGate:     newRunGate(RunAllow),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if _, aging := e.wq.(*agingQueue); aging && e.policy == LIFO {
+		e.invalid("priority aging conflicts with LIFO schedule policy")
+	}
+	if err := errors.Join(e.errs...); err != nil {
+		return nil, err
+	}
+	if e.activity != nil {
+		e.activity.clock = e.clock
+	}
+	if q, ok := e.wq.(*agingQueue); ok {
+		q.clock = e.clock
+	}
+
+	if e.profiler.disabled {
+		e.sampler = nil
+	}
+	if e.policy == LIFO {
+		e.pool.SetLIFO()
+	}
+	if len(e.locals) > 0 {
+		e.pool.SetWorkerInit(e.workerInit)
+	}
+	return e, nil
+}
+
+// invalid records an error of options
+func (e *innerExecutorImpl) invalid(format string, args ...any) {
+	e.errs = append(e.errs, fmt.Errorf(format, args...))
+}
+
+// ExecutorStats is a snapshot of executor state
+type ExecutorStats struct {
+	Concurrency     uint     `json:"concurrency"`
+	Flows           []string `json:"flows"`            // names of running taskflows, sorted
+	Queued          int      `json:"queued"`           // tasks in work queue, not dispatched to pool yet
+	InFlight        int      `json:"in_flight"`        // scheduled but unfinished tasks of running taskflows, excluding tasks inside subflows
+	ProgressDropped uint64   `json:"progress_dropped"` // see ProgressDropped
+	// ProfileSampleRate is the fraction of spans recorded, profiles are estimates from a sample if it is below 1
+	ProfileSampleRate float64 `json:"profile_sample_rate"`
+}
+
+// ErrCanceled is reported by RunHandle if taskflow is canceled
+var ErrCanceled = errors.New("taskflow canceled")
+
+// ErrQueueFull is reported by RunHandle if a task is scheduled while work queue is full, see WithMaxQueueDepth
+var ErrQueueFull = utils.ErrQueueFull
+
+// ErrSubflowTooDeep is reported by RunHandle if subflows are nested deeper than allowed, see WithMaxSubflowDepth
+var ErrSubflowTooDeep = errors.New("subflow nested too deep")
+
+// RunOption configures a single run of taskflow
+type RunOption func(opts *runOptions)
+
+type runOptions struct {
+	skipTags map[string]struct{}
+	runID    uint64
+	from     *innerNode // see RunFrom
+	handle   *RunHandle // see RunAsync
+}
+
+func newRunOptions(opts []RunOption) runOptions {
+	o := runOptions{skipTags: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithRunID sets id of this run, which is attached to its spans, progress events and panic reports.
+// Executor numbers runs from 1 otherwise, so id should be unique and non-zero.
+func WithRunID(id uint64) RunOption {
+	return func(opts *runOptions) {
+		opts.runID = id
+	}
+}
+
+// SkipTags skips tasks carrying any of tags in this run. Skipped tasks finish instantly without running,
+// so their successors are still released, except that a skipped condition selects no branch.
+func SkipTags(tags ...string) RunOption {
+	return func(opts *runOptions) {
+		for _, tag := range tags {
+			opts.skipTags[tag] = struct{}{}
+		}
+	}
+}
+
+// withHandle makes run started by RunAsync apply cancel of its handle once graph is set up
+func withHandle(h *RunHandle) RunOption {
+	return func(opts *runOptions) {
+		opts.handle = h
 	}
 }
 
 // Run start to schedule and execute taskflow
-func (e *innerExecutorImpl) Run(tf *TaskFlow) Executor {
+func (e *innerExecutorImpl) Run(tf *TaskFlow, opts ...RunOption) Executor {
+	o := newRunOptions(opts)
+	if o.runID == 0 {
+		o.runID = e.runs.Add(1)
+	}
+	if o.from != nil && o.from.g != tf.graph {
+		panic(fmt.Sprintf("task %v is not in taskflow %v", o.from.name, tf.name))
+	}
+	if err := e.runGate.enter(); err != nil {
+		e.mu.Lock()
+		_, running := e.flows[tf.graph]
+		e.mu.Unlock()
+		// a taskflow rejected while running itself keeps the state of that run
+		if !running {
+			tf.graph.reset()
+			tf.graph.fail(fmt.Errorf("taskflow %v -> %w", tf.name, err))
+		}
+		return e
+	}
+	defer e.runGate.leave()
+	e.running.Add(1)
+	defer e.running.Done()
+	tf.graph.skipTags = o.skipTags
+	tf.graph.errPolicy = e.errPolicy
+	tf.graph.from = o.from
+	tf.graph.handle = o.handle
+	tf.graph.slog = e.slog
+	tf.graph.runID.Store(o.runID)
+
+	e.mu.Lock()
+	e.flows[tf.graph] = tf
+	e.mu.Unlock()
+
+	e.sampler.start()
 	e.scheduleGraph(tf.graph, nil)
+	e.sampler.done()
+
+	e.mu.Lock()
+	delete(e.flows, tf.graph)
+	e.ran = append(e.ran, tf.graph)
+	e.mu.Unlock()
+	return e
+}
+
+// RunFrom runs only start and its transitive successors in tf, start being the sole entry.
+// Tasks outside are neither run nor waited for: a join counts only its dependents reachable from start.
+// Barriers arrived at by those tasks are run as well. It panics if start is not pushed into tf.
+func (e *innerExecutorImpl) RunFrom(tf *TaskFlow, start *Task, opts ...RunOption) Executor {
+	return e.Run(tf, append(slices.Clone(opts), func(o *runOptions) {
+		o.from = start.node
+	})...)
+}
+
+// RunAsync start to schedule and execute taskflow in background, returns a handle to wait for its completion
+func (e *innerExecutorImpl) RunAsync(tf *TaskFlow, opts ...RunOption) *RunHandle {
+	opts, id := e.withRunID(opts)
+	h := newRunHandle(tf.graph, id)
+	opts = append(slices.Clone(opts), withHandle(h))
+	e.running.Add(1)
+	go func() {
+		defer e.running.Done()
+		defer close(h.done)
+		e.Run(tf, opts...)
+		h.err, h.canceled = tf.graph.err(), tf.graph.canceled.Load()
+	}()
+	return h
+}
+
+// SubmitOnce runs taskflow in background like RunAsync, unless it is already running by SubmitOnce, then it returns nil.
+// A finished taskflow is reset and run again.
+func (e *innerExecutorImpl) SubmitOnce(tf *TaskFlow, opts ...RunOption) *RunHandle {
+	if !tf.submitted.CompareAndSwap(false, true) {
+		return nil
+	}
+	tf.Reset()
+	opts, id := e.withRunID(opts)
+	h := newRunHandle(tf.graph, id)
+	opts = append(slices.Clone(opts), withHandle(h))
+	e.running.Add(1)
+	go func() {
+		defer e.running.Done()
+		defer close(h.done)
+		e.Run(tf, opts...)
+		h.err, h.canceled = tf.graph.err(), tf.graph.canceled.Load()
+		tf.submitted.Store(false)
+	}()
+	return h
+}
+
+// RunOnce runs taskflow and returns its failure, like RunAsync followed by RunHandle.Err. Callers arriving
+// while it runs, from any goroutine, wait for that run and get its result instead of running it again,
+// such as triggers of a cache refresh. The result is not kept once the run is done, a later call runs again.
+func (e *innerExecutorImpl) RunOnce(tf *TaskFlow) error {
+	tf.sharedMu.Lock()
+	if run := tf.shared; run != nil {
+		tf.sharedMu.Unlock()
+		<-run.done
+		return run.err
+	}
+	run := &sharedRun{done: make(chan struct{})}
+	tf.shared = run
+	tf.sharedMu.Unlock()
+
+	defer func() {
+		tf.sharedMu.Lock()
+		tf.shared = nil
+		tf.sharedMu.Unlock()
+		close(run.done)
+	}()
+	e.Run(tf)
+	run.err = tf.graph.err()
+	return run.err
+}
+
+// RunAll runs taskflows concurrently as a batch in background, Wait blocks until all of them are done.
+// Failures are collected by Errors. It panics if a taskflow is given twice, as a taskflow cannot run concurrently with itself.
+func (e *innerExecutorImpl) RunAll(tfs ...*TaskFlow) Executor {
+	seen := make(map[*TaskFlow]struct{}, len(tfs))
+	for _, tf := range tfs {
+		if _, ok := seen[tf]; ok {
+			panic(fmt.Sprintf("taskflow %v is given to RunAll twice", tf.name))
+		}
+		seen[tf] = struct{}{}
+	}
+
+	handles := make([]*RunHandle, 0, len(tfs))
+	e.batches.Add(1)
+	for _, tf := range tfs {
+		handles = append(handles, e.RunAsync(tf))
+	}
+	e.mu.Lock()
+	e.batch = handles
+	e.mu.Unlock()
+	go func() {
+		defer e.batches.Done()
+		for _, h := range handles {
+			<-h.Done()
+		}
+	}()
+	return e
+}
+
+// Errors returns failures of taskflows in the latest RunAll batch, each tagged with taskflow name. It is valid after Wait
+func (e *innerExecutorImpl) Errors() []error {
+	e.mu.Lock()
+	handles := e.batch
+	e.mu.Unlock()
+	errs := make([]error, 0)
+	for _, h := range handles {
+		select {
+		case <-h.Done():
+			if err := h.Err(); err != nil {
+				errs = append(errs, fmt.Errorf("taskflow %v -> %w", h.g.name, err))
+			}
+		default:
+		}
+	}
+	return errs
+}
+
+// Unfinished returns tasks not in finished state, of taskflows whose runs ended between the latest Wait and the one before.
+// They are tasks skipped by a condition, a tag or cancellation, and tasks failed. A taskflow run again
+// after Wait reports its current state. Tasks are ordered by taskflow run, then by pushing order.
+func (e *innerExecutorImpl) Unfinished() []*Task {
+	e.mu.Lock()
+	graphs := slices.Clone(e.settled)
+	e.mu.Unlock()
+
+	tasks := make([]*Task, 0)
+	seen := make(map[*eGraph]struct{}, len(graphs))
+	for _, g := range graphs {
+		if _, ok := seen[g]; ok {
+			continue
+		}
+		seen[g] = struct{}{}
+		g.walk(func(n *innerNode) {
+			if n.state.Load() != kNodeStateFinished {
+				tasks = append(tasks, &Task{node: n})
+			}
+		})
+	}
+	return tasks
+}
+
+// withRunID assigns a run id unless opts has one, so that it is known before run starts
+func (e *innerExecutorImpl) withRunID(opts []RunOption) ([]RunOption, uint64) {
+	if id := newRunOptions(opts).runID; id != 0 {
+		return opts, id
+	}
+	id := e.runs.Add(1)
+	return append(slices.Clone(opts), WithRunID(id)), id
+}
+
+// Cancel stop scheduling tasks of all running taskflows, running tasks are not interrupted
+func (e *innerExecutorImpl) Cancel() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for g := range e.flows {
+		g.cancel()
+	}
+}
+
+// RunUntilSignal run taskflow and wait, cancel it gracefully once one of sig is received. os.Interrupt by default
+func (e *innerExecutorImpl) RunUntilSignal(tf *TaskFlow, sig ...os.Signal) Executor {
+	if len(sig) == 0 {
+		sig = []os.Signal{os.Interrupt}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ch:
+			tf.graph.cancel()
+		case <-done:
+		}
+	}()
+
+	e.Run(tf).Wait()
+	signal.Stop(ch)
+	close(done)
 	return e
 }
 
 // 任务执行循环
-func (e *innerExecutorImpl) invokeGraph(g *eGraph, parentSpan *span) {
+func (e *innerExecutorImpl) invokeGraph(g *eGraph) {
+	e.loops.enter(g)
+	defer e.loops.leave(g)
 	for {
 		g.scheCond.L.Lock()
-		for g.JoinCounter() != 0 && e.wq.Len() == 0 && !g.canceled.Load() {
+		for g.JoinCounter() != 0 && (e.wq.Len() == 0 || e.saturated() || e.paused.Load()) {
 			g.scheCond.Wait()
 		}
 		g.scheCond.L.Unlock()
 
 		// tasks can only be executed after sched, and joinCounter incr when sched, so here no need to lock up.
-		if g.JoinCounter() == 0 || g.canceled.Load() {
+		if g.JoinCounter() == 0 {
 			break
 		}
 
+		g.checkDeadline(e.clock.Now())
 		node := e.wq.PeakAndTake() // hang
-		e.invokeNode(node, parentSpan)
+		if node == nil {
+			// taken by loop of another graph
+			continue
+		}
+		if node.g.isCanceled() {
+			e.activity.dequeue(node)
+			// drain nodes queued before cancel, so that wait group and join counter stay balanced
+			node.g.joinCounter.Decrease()
+			e.wg.Done()
+			node.g.scheCond.Signal()
+			continue
+		}
+		if node.hasTag(node.g.skipTags) || e.vetoed(node) || !e.admitted(node) {
+			e.activity.dequeue(node)
+			e.skipNode(node)
+			continue
+		}
+		if err := e.sandbox(node); err != nil {
+			e.activity.dequeue(node)
+			e.reject(node, err)
+			continue
+		}
+		// the queue is shared, node may belong to another graph
+		e.invokeNode(node, node.g.span)
 	}
 }
 
-// 任务完成后更新依赖计数，调度后续任务
-func (e *innerExecutorImpl) sche_successors(node *innerNode) {
-	candidate := make([]*innerNode, 0, len(node.successors))
+// saturated reports whether queued nodes are held back until pool has idle workers, see WithFairScheduling
+func (e *innerExecutorImpl) saturated() bool {
+	q, ok := e.wq.(*fairQueue)
+	return ok && q.saturated()
+}
 
-	for _, n := range node.successors {
-		if n.JoinCounter() == 0 || n.Typ == nodeCondition {
-			// deps all done or condition node
-			candidate = append(candidate, n)
-		}
-	}
+// 任务完成后更新依赖计数，调度后续任务
+// ready are successors released by node.drop()
+func (e *innerExecutorImpl) sche_successors(node *innerNode, ready []*innerNode) {
+	candidate := ready
 
-	slices.SortFunc(candidate, func(i, j *innerNode) int {
-		return cmp.Compare(i.priority, j.priority)
-	})
-	node.setup()
+	e.order(node.g, candidate)
+	node.rearm()
 	e.schedule(candidate...)
 }
 
-func (e *innerExecutorImpl) invokeStatic(node *innerNode, parentSpan *span, p *Static) func() {
-	return func() {
-		span := span{extra: attr{
-			typ:  nodeStatic,
-			name: node.name,
-		}, begin: time.Now(), parent: parentSpan}
+// order sorts nodes to be scheduled together, so that they are taken in priority order
+func (e *innerExecutorImpl) order(g *eGraph, nodes []*innerNode) {
+	if !g.shuffle(nodes) {
+		slices.SortFunc(nodes, func(i, j *innerNode) int {
+			return cmp.Compare(i.priority, j.priority)
+		})
+	}
+	if e.policy == LIFO {
+		// pool takes the last submitted first
+		slices.Reverse(nodes)
+	}
+}
 
-		defer func() {
-			span.cost = time.Now().Sub(span.begin)
-			if r := recover(); r != nil {
-				node.g.canceled.Store(true)
-				fmt.Printf("[recovered] node %s, panic: %s, stack: %s", node.name, r, debug.Stack())
-			} else {
-				e.profiler.AddSpan(&span) // remove canceled node span
+func (e *innerExecutorImpl) invokeStatic(node *innerNode, parentSpan *span, p *Static) func(worker int) {
+	return func(worker int) {
+		for cur := node; cur != nil; {
+			if cur != node && e.vetoed(cur) {
+				e.skipNode(cur)
+				return
 			}
+			if cur != node {
+				if err := e.sandbox(cur); err != nil {
+					e.reject(cur, err)
+					return
+				}
+			}
+			cur = e.runStatic(cur, parentSpan, cur.ptr.(*Static), worker)
+		}
+	}
+}
 
-			node.drop()
-			e.sche_successors(node)
-			node.g.joinCounter.Decrease()
-			e.wg.Done()
-			node.g.scheCond.Signal()
-		}()
+// runStatic runs a static node, returns its successor if they are coalesced and it should run right after in the same goroutine
+func (e *innerExecutorImpl) runStatic(node *innerNode, parentSpan *span, p *Static, worker int) (next *innerNode) {
+	span := span{extra: attr{
+		typ:  nodeStatic,
+		name: node.spanName(),
+		run:  node.g.runID.Load(),
+	}, begin: e.clock.Now(), parent: parentSpan, node: node, worker: worker, label: node.label}
+	stop := e.startTimeout(node, nil)
+	soft := e.startSoftDeadline(node)
+	var err error // returned by handle
+
+	defer func() {
+		span.cost = e.clock.Now().Sub(span.begin)
+		span.overDeadline = soft()
+		if r := recover(); r != nil {
+			stop()
+			node.g.fail(reportPanic(e.reporter(), node, r, worker))
+			e.transition(node, kNodeStateFailed)
+			e.finish(node, worker, r)
+		} else if err != nil {
+			stop()
+			node.g.fail(fmt.Errorf("%v %v -> %w", node.Typ, node.name, err))
+			e.transition(node, kNodeStateFailed)
+			e.finish(node, worker, err)
+		} else if stop() {
+			e.transition(node, kNodeStateFailed)
+			e.finish(node, worker, ErrTimeout)
+		} else {
+			e.profiler.AddSpan(&span) // remove canceled node span
+			e.finish(node, worker, nil)
+		}
 
-		node.state.Store(kNodeStateRunning)
-		p.handle()
-		node.state.Store(kNodeStateFinished)
+		e.stats.record(node.name, span.cost, node.state.Load() == kNodeStateFailed)
+		node.g.history.record(node.name, span.cost, node.state.Load() == kNodeStateFailed)
+		node.g.recordCost(node, span.cost)
+		ready := node.drop()
+		e.arrive(node)
+		if next = e.coalesced(node, ready); next != nil {
+			node.rearm()
+			next.g.joinCounter.Increase()
+			e.wg.Add(1)
+			e.transition(next, kNodeStateWaiting)
+		} else {
+			e.sche_successors(node, ready)
+		}
+		node.g.joinCounter.Decrease()
+		e.wg.Done()
+		node.g.scheCond.Signal()
+	}()
+
+	e.sampler.enter(worker, node)
+	defer e.sampler.leave(worker)
+	e.activity.enter(node)
+	defer e.activity.leave(node)
+	e.transition(node, kNodeStateRunning)
+	e.onNode(node, NodeStarted)
+	if err = e.call(node, p); err != nil {
+		return nil
+	}
+	e.transition(node, kNodeStateFinished)
+	if e.releaseHandles {
+		node.releaseHandle()
+	}
+	return nil
+}
+
+// coalesced returns the only successor of a finished static node if it is a static node depending on nothing else,
+// so that the chain can run in one pool job instead of going through the work queue.
+func (e *innerExecutorImpl) coalesced(node *innerNode, ready []*innerNode) *innerNode {
+	// while paused, the successor is queued like any other
+	if !e.coalesce || len(node.successors) != 1 || len(ready) != 1 || node.g.isCanceled() || e.paused.Load() {
+		return nil
+	}
+	next := ready[0]
+	if next.Typ != nodeStatic || len(next.dependents) != 1 || next.hasTag(next.g.skipTags) || next.affinity != node.affinity ||
+		next.ptr.(*Static).gate != nil || len(e.tagLimiters(next)) > 0 {
+		return nil
 	}
+	return next
+}
+
+func (e *innerExecutorImpl) invokeSubflow(node *innerNode, parentSpan *span, p *Subflow) func(worker int) {
+	if p.async && !p.g.instancelized && p.g.depth() <= e.maxDepth {
+		return func(worker int) {
+			begin := e.clock.Now()
+			// handle may block on IO, so it runs off pool, and the rest is submitted again once it returns
+			go func() {
+				var r any
+				func() {
+					defer func() { r = recover() }()
+					p.handle(p)
+				}()
+				e.submitter(node)(e.runSubflow(node, parentSpan, p, begin, func() {
+					if r != nil {
+						panic(r)
+					}
+				}))
+			}()
+		}
+	}
+	return e.runSubflow(node, parentSpan, p, time.Time{}, func() {
+		if !p.g.instancelized {
+			p.handle(p)
+		}
+	})
 }
 
-func (e *innerExecutorImpl) invokeSubflow(node *innerNode, parentSpan *span, p *Subflow) func() {
-	return func() {
+// runSubflow returns job running subflow node, build runs its handle. Span of node begins at begin unless it is zero
+func (e *innerExecutorImpl) runSubflow(node *innerNode, parentSpan *span, p *Subflow, begin time.Time, build func()) func(worker int) {
+	return func(worker int) {
+		if begin.IsZero() {
+			begin = e.clock.Now()
+		}
 		span := span{extra: attr{
 			typ:  nodeSubflow,
-			name: node.name,
-		}, begin: time.Now(), parent: parentSpan}
+			name: node.spanName(),
+			run:  node.g.runID.Load(),
+		}, begin: begin, parent: parentSpan, node: node, worker: worker, label: node.label}
+		stop := e.startTimeout(node, p.g)
+		soft := e.startSoftDeadline(node)
 		defer func() {
-			span.cost = time.Now().Sub(span.begin)
+			span.cost = e.clock.Now().Sub(span.begin)
 			if r := recover(); r != nil {
-				fmt.Printf("[recovered] subflow %s, panic: %s, stack: %s", node.name, r, debug.Stack())
-				node.g.canceled.Store(true)
+				stop()
+				soft()
+				node.g.fail(reportPanic(e.reporter(), node, r, worker))
+				e.transition(node, kNodeStateFailed)
 				p.g.canceled.Store(true)
+				e.finish(node, worker, r)
+				e.arrive(node)
+			} else if node.state.Load() == kNodeStateFailed {
+				// nested too deep, never built
+				stop()
+				soft()
+				e.finish(node, worker, ErrSubflowTooDeep)
+				e.arrive(node)
 			} else {
 				e.profiler.AddSpan(&span) // remove canceled node span
+				// arrive once handle returns, before tasks it built run
+				e.arrive(node)
+				// blocks until subflow graph drains, so that successors released below see what its tasks did
+				e.scheduleGraph(p.g, &span)
+				if soft() {
+					e.profiler.flagOverDeadline(&span)
+				}
+				if stop() {
+					e.transition(node, kNodeStateFailed)
+					e.finish(node, worker, ErrTimeout)
+				} else {
+					// finished only once its graph drained, before successors are released
+					e.transition(node, kNodeStateFinished)
+					p.publish()
+					e.finish(node, worker, nil)
+					if e.releaseHandles {
+						node.releaseHandle()
+					}
+				}
 			}
 
-			e.scheduleGraph(p.g, &span)
-			node.drop()
-			e.sche_successors(node)
+			// span of subflow only covers its handle
+			cost := e.clock.Now().Sub(span.begin)
+			e.stats.record(node.name, cost, node.state.Load() == kNodeStateFailed)
+			node.g.history.record(node.name, cost, node.state.Load() == kNodeStateFailed)
+			node.g.recordCost(node, cost)
+			e.sche_successors(node, node.drop())
 			node.g.joinCounter.Decrease()
 			e.wg.Done()
 			node.g.scheCond.Signal()
 		}()
 
-		node.state.Store(kNodeStateRunning)
-		if !p.g.instancelized {
-			p.handle(p)
+		e.sampler.enter(worker, node)
+		defer e.sampler.leave(worker)
+		e.activity.enter(node)
+		defer e.activity.leave(node)
+		e.transition(node, kNodeStateRunning)
+		e.onNode(node, NodeStarted)
+		if depth := p.g.depth(); depth > e.maxDepth {
+			node.g.fail(fmt.Errorf("subflow %v at depth %v exceeds %v -> %w", node.name, depth, e.maxDepth, ErrSubflowTooDeep))
+			// stop the whole run, graphs in between cannot tell it from a subflow finishing
+			node.g.root().canceled.Store(true)
+			e.transition(node, kNodeStateFailed)
+			return
 		}
+		p.g.inheritOrder(node.g)
+		p.g.skipTags = node.g.skipTags
+		p.g.runID.Store(node.g.runID.Load())
+		build()
 		p.g.instancelized = true
-		node.state.Store(kNodeStateFinished)
 	}
 }
 
-func (e *innerExecutorImpl) invokeCondition(node *innerNode, parentSpan *span, p *Condition) func() {
-	return func() {
+func (e *innerExecutorImpl) invokeCondition(node *innerNode, parentSpan *span, p *Condition) func(worker int) {
+	return func(worker int) {
 		span := span{extra: attr{
 			typ:  nodeCondition,
-			name: node.name,
-		}, begin: time.Now(), parent: parentSpan}
+			name: node.spanName(),
+			run:  node.g.runID.Load(),
+		}, begin: e.clock.Now(), parent: parentSpan, node: node, worker: worker, label: node.label}
+		stop := e.startTimeout(node, nil)
+		soft := e.startSoftDeadline(node)
 
 		defer func() {
-			span.cost = time.Now().Sub(span.begin)
+			span.cost = e.clock.Now().Sub(span.begin)
+			span.overDeadline = soft()
 			if r := recover(); r != nil {
-				node.g.canceled.Store(true)
-				fmt.Printf("[recovered] node %s, panic: %s, stack: %s", node.name, r, debug.Stack())
+				stop()
+				node.g.fail(reportPanic(e.reporter(), node, r, worker))
+				e.transition(node, kNodeStateFailed)
+				e.finish(node, worker, r)
+			} else if stop() {
+				e.transition(node, kNodeStateFailed)
+				e.finish(node, worker, ErrTimeout)
 			} else {
 				e.profiler.AddSpan(&span) // remove canceled node span
+				e.finish(node, worker, nil)
 			}
+			e.stats.record(node.name, span.cost, node.state.Load() == kNodeStateFailed)
+			node.g.history.record(node.name, span.cost, node.state.Load() == kNodeStateFailed)
+			node.g.recordCost(node, span.cost)
 			node.drop()
+			e.arrive(node)
 			// e.sche_successors(node)
 			node.g.joinCounter.Decrease()
-			node.setup()
+			node.rearm()
 			e.wg.Done()
 			node.g.scheCond.Signal()
 		}()
 
-		node.state.Store(kNodeStateRunning)
+		e.sampler.enter(worker, node)
+		defer e.sampler.leave(worker)
+		e.activity.enter(node)
+		defer e.activity.leave(node)
+		e.transition(node, kNodeStateRunning)
+		e.onNode(node, NodeStarted)
 
-		choice := p.handle()
-		if choice > uint(len(p.mapper)) {
-			panic(fmt.Sprintln("condition task failed, successors of condition should be more than precondition choice", p.handle()))
+		var next *innerNode
+		if p.keyHandle != nil {
+			key := p.keyHandle()
+			var ok bool
+			if next, ok = p.stringMapper[key]; !ok {
+				panic(fmt.Sprintf("condition task failed, no successor for key %q", key))
+			}
+		} else {
+			choice := p.handle()
+			if choice >= uint(len(p.mapper)) {
+				panic(fmt.Sprintln("condition task failed, successors of condition should be more than precondition choice", choice))
+			}
+			next = p.mapper[choice]
+		}
+		if next.state.Load() == kNodeStateSkipped && p.looping {
+			panic(fmt.Sprintf("condition task failed, branch %v is canceled", next.name))
 		}
+		canceled := e.canceledBranches(p, next)
+		p.record(next)
 		// do choice and cancel others
-		node.state.Store(kNodeStateFinished)
+		e.transition(node, kNodeStateFinished)
 		// 只调度选择的路径
-		e.schedule(p.mapper[choice])
+		e.schedule(next)
+		if !p.looping {
+			for _, succ := range node.successors {
+				if succ != next {
+					e.skipBranch(succ)
+				}
+			}
+		}
+		for _, succ := range canceled {
+			e.skipBranch(succ)
+		}
 	}
 }
 
+// canceledBranches returns branches of p to cancel along with its choice next, see NewCancelingCondition
+func (e *innerExecutorImpl) canceledBranches(p *Condition, next *innerNode) []*innerNode {
+	defer func() { p.canceling = nil }()
+	res := make([]*innerNode, 0, len(p.canceling))
+	for _, idx := range p.canceling {
+		succ, ok := p.mapper[idx]
+		if !ok {
+			panic(fmt.Sprintf("condition task failed, no branch %v to cancel", idx))
+		}
+		if succ == next {
+			panic(fmt.Sprintf("condition task failed, branch %v is both chosen and canceled", idx))
+		}
+		res = append(res, succ)
+	}
+	return res
+}
+
+// skipBranch marks an untaken branch of condition as skipped, if nothing else is going to release it.
+// Skipping cascades to successors, and a successor runs only if some of its dependents has run rather than been skipped,
+// so joins across branches of a condition behave as weak dependencies.
+func (e *innerExecutorImpl) skipBranch(node *innerNode) {
+	if node.JoinCounter() != 0 || node.live.Load() || !node.state.CompareAndSwap(kNodeStateIdle, kNodeStateSkipped) {
+		return
+	}
+	e.arrive(node)
+
+	for _, succ := range node.successors {
+		if node.Typ == nodeCondition {
+			// successors of a condition are not counted
+			e.skipBranch(succ)
+			continue
+		}
+		if node.release(succ) != 0 {
+			continue
+		}
+		if succ.live.Load() {
+			e.schedule(succ)
+		} else {
+			e.skipBranch(succ)
+		}
+	}
+}
+
+// admitted evaluates gate of node, see NewConditionalTask. A panic in gate fails the graph of node, which is skipped then
+func (e *innerExecutorImpl) admitted(node *innerNode) (ok bool) {
+	p, static := node.ptr.(*Static)
+	if !static || p.gate == nil {
+		return true
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			node.g.fail(reportPanic(e.reporter(), node, r, -1))
+			ok = false
+		}
+	}()
+	return p.gate()
+}
+
+// skipNode finishes node without running it, releasing its successors as if it was done
+func (e *innerExecutorImpl) skipNode(node *innerNode) {
+	e.sche_successors(node, node.drop())
+	e.arrive(node)
+	e.transition(node, kNodeStateSkipped)
+	node.g.joinCounter.Decrease()
+	e.wg.Done()
+	node.g.scheCond.Signal()
+}
+
 func (e *innerExecutorImpl) invokeNode(node *innerNode, parentSpan *span) {
+	var job func(worker int)
 	switch p := node.ptr.(type) {
 	case *Static:
-		e.pool.Go(e.invokeStatic(node, parentSpan, p))
+		job = e.invokeStatic(node, parentSpan, p)
 	case *Subflow:
-		e.pool.Go(e.invokeSubflow(node, parentSpan, p))
+		job = e.invokeSubflow(node, parentSpan, p)
 	case *Condition:
-		e.pool.Go(e.invokeCondition(node, parentSpan, p))
+		job = e.invokeCondition(node, parentSpan, p)
 	default:
 		panic("unsupported node")
 	}
+
+	e.submitter(node)(job)
+}
+
+// submitter returns how jobs of node are submitted, through limits of its type, tags and graph, see invokeNode
+func (e *innerExecutorImpl) submitter(node *innerNode) func(job func(worker int)) {
+	base := e.pool.GoWorker
+	if q, ok := e.wq.(*fairQueue); ok {
+		base = q.track(node, base)
+	}
+	if node.affinity.set() && node.Typ != nodeSubflow {
+		base = e.affine(node.affinity).submit
+	}
+	// slots of tags are taken in order of tag, so that tasks sharing tags cannot hold slots waited by each other
+	tags := e.tagLimiters(node)
+	for i := len(tags) - 1; i >= 0; i-- {
+		l, inner := tags[i], base
+		base = func(job func(worker int)) {
+			l.do(inner, job)
+		}
+	}
+	submit := base
+	if l, ok := e.limits[node.Typ]; ok {
+		submit = func(job func(worker int)) {
+			l.do(base, job)
+		}
+	}
+	if node.g.limiter != nil {
+		return func(job func(worker int)) {
+			node.g.limiter.do(submit, job)
+		}
+	}
+	return submit
+}
+
+// tagLimiters returns limits of tags node carries, ordered by tag
+func (e *innerExecutorImpl) tagLimiters(node *innerNode) []*limiter {
+	if len(e.tagLimits) == 0 {
+		return nil
+	}
+	tags := slices.Clone(node.tags)
+	slices.Sort(tags)
+	limiters := make([]*limiter, 0)
+	for _, tag := range slices.Compact(tags) {
+		if l, ok := e.tagLimits[tag]; ok {
+			limiters = append(limiters, l)
+		}
+	}
+	return limiters
 }
 
 func (e *innerExecutorImpl) schedule(nodes ...*innerNode) {
 	for _, node := range nodes {
-		if node.g.canceled.Load() {
+		if e.strict {
+			if state := node.state.Load(); state == kNodeStateWaiting || state == kNodeStateRunning {
+				panic(fmt.Sprintf("node %v in graph %v is scheduled again before it finished", node.name, node.g.name))
+			}
+		}
+		if node.g.isCanceled() {
 			node.g.scheCond.Signal()
-			fmt.Printf("node %v is not scheduled, as graph %v is canceled\n", node.name, node.g.name)
+			if e.slog != nil {
+				e.slog.Info("task is not scheduled, as graph is canceled", "task", node.name, "graph", node.g.name)
+			} else {
+				fmt.Printf("node %v is not scheduled, as graph %v is canceled\n", node.name, node.g.name)
+			}
 			return
 		}
 
@@ -208,7 +1006,7 @@ func (e *innerExecutorImpl) schedule(nodes ...*innerNode) {
 		// 	node.g.scheCond.Signal()
 		// 	fmt.Printf("node %v is canceled\n", node.name)
 		// 	for _, v := range node.successors {
-		// 		v.state.Store(kNodeStateCanceled)
+		// 		e.transition(v, kNodeStateCanceled)
 		// 	}
 
 		// 	continue
@@ -216,8 +1014,19 @@ func (e *innerExecutorImpl) schedule(nodes ...*innerNode) {
 
 		node.g.joinCounter.Increase()
 		e.wg.Add(1)
-		e.wq.Put(node)
-		node.state.Store(kNodeStateWaiting)
+		e.activity.enqueue(node)
+		// waiting ahead of put, as a worker may take and start node right after
+		prev := node.state.Load()
+		e.transition(node, kNodeStateWaiting)
+		if err := e.wq.PutWithLimit(node, e.maxQueue); err != nil {
+			node.state.Store(prev)
+			e.activity.dequeue(node)
+			node.g.joinCounter.Decrease()
+			e.wg.Done()
+			node.g.fail(fmt.Errorf("node %v of graph %v is not scheduled -> %w", node.name, node.g.name, err))
+			node.g.scheCond.Signal()
+			return
+		}
 		node.g.scheCond.Signal()
 	}
 }
@@ -225,23 +1034,189 @@ func (e *innerExecutorImpl) schedule(nodes ...*innerNode) {
 // scheduleGraph 对图进行初始化
 // 入口节点按优先级排序并添加到工作队列
 func (e *innerExecutorImpl) scheduleGraph(g *eGraph, parentSpan *span) {
+	g.span = parentSpan
 	g.setup()
-	slices.SortFunc(g.entries, func(i, j *innerNode) int {
-		return cmp.Compare(i.priority, j.priority)
-	})
+	if g.handle != nil {
+		g.handle.start()
+	}
+	if e.releaseHandles {
+		g.markReentrant()
+	}
+	e.order(g, g.entries)
+	g.runHooks(e.reporter(), false)
 
+	var timer Timer
+	if !g.deadline.IsZero() {
+		now := e.clock.Now()
+		g.checkDeadline(now)
+		timer = e.clock.AfterFunc(g.deadline.Sub(now), g.exceed)
+	}
 	e.schedule(g.entries...)
-	e.invokeGraph(g, parentSpan)
+	e.invokeGraph(g)
+	if timer != nil {
+		timer.Stop()
+	}
+	if e.strict {
+		if err := g.checkBalance(); err != nil {
+			e.reporter().log("strict counting", fmt.Sprintf("run %d: %v", g.runID.Load(), err), "graph", g.name, "run", g.runID.Load(), "error", err)
+			g.fail(err)
+		}
+	}
+	e.progress.emitGraph(g, e.clock.Now())
+	g.runHooks(e.reporter(), true)
+	// release resources of context, like errgroup does once Wait returns
+	g.stopContext(context.Canceled)
 
 	g.scheCond.Signal()
 }
 
 // Wait: block until all tasks finished
 func (e *innerExecutorImpl) Wait() {
+	// runs of RunAll may not have scheduled their tasks yet
+	e.batches.Wait()
 	e.wg.Wait()
+	// runs record themselves after their tasks are done
+	e.running.Wait()
+
+	e.mu.Lock()
+	e.settled, e.ran = e.ran, nil
+	e.mu.Unlock()
+}
+
+// Drain block until all scheduled tasks are picked up from work queue, running tasks are not waited.
+// It fails if work queue is not empty while no taskflow is running to consume it.
+func (e *innerExecutorImpl) Drain() error {
+	e.mu.Lock()
+	running := len(e.flows)
+	e.mu.Unlock()
+
+	if running == 0 && e.wq.Len() != 0 {
+		return fmt.Errorf("drain %v queued tasks -> no running taskflow", e.wq.Len())
+	}
+	e.wq.WaitEmpty()
+	return nil
+}
+
+// Profile write flame graph raw text into w, only of runs if any given
+func (e *innerExecutorImpl) Profile(w io.Writer, runs ...uint64) error {
+	return e.profiler.draw(w, runs...)
+}
+
+// ProfileRun write flame graph raw text of run runID into w, spans of runs going on meanwhile are left out.
+// It fails if nothing of the run is recorded, as the run is unknown, not finished any task yet, or profiling is off.
+func (e *innerExecutorImpl) ProfileRun(runID uint64, w io.Writer) error {
+	if !e.profiler.recorded(runID) {
+		return fmt.Errorf("no profile of run %v", runID)
+	}
+	return e.profiler.draw(w, runID)
+}
+
+// ProfileFiltered write flame graph raw text of tasks labeled by any of labels into w
+func (e *innerExecutorImpl) ProfileFiltered(w io.Writer, labels ...string) error {
+	return e.profiler.drawFiltered(w, labels, nil)
+}
+
+// Spans returns recorded spans ordered by begin time, only of runs if any given
+func (e *innerExecutorImpl) Spans(runs ...uint64) []SpanInfo {
+	spans := e.profiler.spansOf(runs)
+	sortSpans(spans)
+	infos := make([]SpanInfo, 0, len(spans))
+	for _, s := range spans {
+		infos = append(infos, s.info())
+	}
+	return infos
+}
+
+// ResetProfile drops spans recorded so far, so that profiles only reflect later runs
+func (e *innerExecutorImpl) ResetProfile() {
+	e.profiler.reset()
+}
+
+// CancelGraph stop scheduling tasks of running taskflows named name, running tasks are not interrupted
+func (e *innerExecutorImpl) CancelGraph(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	found := false
+	for g := range e.flows {
+		if g.name == name {
+			g.cancel()
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("cancel %v -> no such running taskflow", name)
+	}
+	return nil
+}
+
+// Running returns taskflows being run, in no particular order
+func (e *innerExecutorImpl) Running() []*TaskFlow {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	flows := make([]*TaskFlow, 0, len(e.flows))
+	for _, tf := range e.flows {
+		flows = append(flows, tf)
+	}
+	return flows
+}
+
+// RunningTasks returns tasks being run, earliest started first. It is empty unless executor has WithTaskTracking
+func (e *innerExecutorImpl) RunningTasks() []TaskInfo {
+	return e.activity.list(true)
+}
+
+// QueuedTasks returns tasks scheduled but not started yet, earliest queued first.
+// Tasks dispatched to pool and waiting for an idle worker are included. It is empty unless executor has WithTaskTracking
+func (e *innerExecutorImpl) QueuedTasks() []TaskInfo {
+	return e.activity.list(false)
+}
+
+// NodeStats returns runs of tasks named name accumulated over the lifetime of executor
+func (e *innerExecutorImpl) NodeStats(name string) NodeRunStats {
+	return e.stats.get(name)
+}
+
+// Stats returns a snapshot of executor state
+func (e *innerExecutorImpl) Stats() ExecutorStats {
+	stats := ExecutorStats{
+		Concurrency:       e.concurrency,
+		Flows:             make([]string, 0),
+		Queued:            int(e.wq.Len()),
+		ProgressDropped:   e.ProgressDropped(),
+		ProfileSampleRate: e.profiler.rate,
+	}
+
+	e.mu.Lock()
+	for g := range e.flows {
+		stats.Flows = append(stats.Flows, g.name)
+		stats.InFlight += g.JoinCounter()
+	}
+	e.mu.Unlock()
+	slices.Sort(stats.Flows)
+	return stats
+}
+
+// Progress returns channel of task progress events, events are dropped if it is full
+func (e *innerExecutorImpl) Progress() <-chan ProgressEvent {
+	return e.progress.channel()
+}
+
+// ProgressDropped returns how many progress events are dropped
+func (e *innerExecutorImpl) ProgressDropped() uint64 {
+	return e.progress.dropped.Load()
+}
+
+// ProfileTimeline write a timeline of recorded spans into w, one row per pool worker or per topological layer by format
+func (e *innerExecutorImpl) ProfileTimeline(w io.Writer, format TimelineFormat) error {
+	return e.profiler.timeline(w, format)
+}
+
+// CriticalPath returns the chain of executed tasks which costs most, subflows are expanded into their inner tasks if expandSubflow
+func (e *innerExecutorImpl) CriticalPath(expandSubflow bool) []SpanInfo {
+	return e.profiler.criticalPath(expandSubflow)
 }
 
-// Profile write flame graph raw text into w
-func (e *innerExecutorImpl) Profile(w io.Writer) error {
-	return e.profiler.draw(w)
+// WriteCriticalPath write expanded critical path in a readable format into w
+func (e *innerExecutorImpl) WriteCriticalPath(w io.Writer) error {
+	return writeCriticalPath(w, e.CriticalPath(true))
 }
@@ -1,12 +1,28 @@
 package gotaskflow_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	gotaskflow "github.com/noneback/go-taskflow"
+	"github.com/noneback/go-taskflow/taskflowtest"
+	"github.com/noneback/go-taskflow/utils"
 )
 
 func TestExecutor(t *testing.T) {
@@ -45,3 +61,2115 @@ func TestExecutor(t *testing.T) {
 	executor.Run(tf).Wait()
 	executor.Profile(os.Stdout)
 }
+
+func TestExecutorRunUntilSignal(t *testing.T) {
+	executor := gotaskflow.NewExecutor(uint(runtime.NumCPU()))
+	tf := gotaskflow.NewTaskFlow("G")
+	ran, signaled := false, false
+	A, B :=
+		gotaskflow.NewTask("A", func() {
+			if signaled {
+				return
+			}
+			signaled = true
+			p, _ := os.FindProcess(os.Getpid())
+			if err := p.Signal(os.Interrupt); err != nil {
+				t.Error(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}),
+		gotaskflow.NewTask("B", func() {
+			ran = true
+		})
+	A.Precede(B)
+	tf.Push(A, B)
+
+	executor.RunUntilSignal(tf, os.Interrupt)
+	if ran {
+		t.Fail()
+	}
+
+	// canceled flag is cleared on next run
+	executor.Run(tf).Wait()
+	if !ran {
+		t.Fail()
+	}
+}
+
+func TestExecutorTypeConcurrency(t *testing.T) {
+	executor := gotaskflow.NewExecutor(16, gotaskflow.WithStaticConcurrency(2), gotaskflow.WithSubflowConcurrency(1))
+	tf := gotaskflow.NewTaskFlow("G")
+
+	var statics, subflows, maxStatics, maxSubflows atomic.Int32
+	track := func(cur, max *atomic.Int32) {
+		n := cur.Add(1)
+		for m := max.Load(); n > m && !max.CompareAndSwap(m, n); m = max.Load() {
+		}
+	}
+
+	for i := 0; i < 8; i++ {
+		tf.Push(gotaskflow.NewTask(fmt.Sprint("static", i), func() {
+			track(&statics, &maxStatics)
+			time.Sleep(10 * time.Millisecond)
+			statics.Add(-1)
+		}))
+	}
+	for i := 0; i < 3; i++ {
+		tf.Push(gotaskflow.NewSubflow(fmt.Sprint("subflow", i), func(sf *gotaskflow.Subflow) {
+			track(&subflows, &maxSubflows)
+			sf.Push(gotaskflow.NewTask("inner", func() {
+				time.Sleep(10 * time.Millisecond)
+				subflows.Add(-1)
+			}))
+		}))
+	}
+
+	executor.Run(tf).Wait()
+	if maxStatics.Load() > 2 || maxSubflows.Load() > 1 {
+		t.Errorf("concurrency exceeds cap, static %v, subflow %v", maxStatics.Load(), maxSubflows.Load())
+	}
+}
+
+func TestExecutorTagConcurrency(t *testing.T) {
+	executor := gotaskflow.NewExecutor(16, gotaskflow.WithTagConcurrency("io", 2), gotaskflow.WithTagConcurrency("db", 1),
+		gotaskflow.WithCoalescing())
+	tf := gotaskflow.NewTaskFlow("G")
+
+	var mu sync.Mutex
+	running, peak := make(map[string]int), make(map[string]int)
+	task := func(name string, tags ...string) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() {
+			mu.Lock()
+			for _, tag := range tags {
+				running[tag]++
+				peak[tag] = max(peak[tag], running[tag])
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			for _, tag := range tags {
+				running[tag]--
+			}
+			mu.Unlock()
+		}).Tag(tags...)
+	}
+	for i := 0; i < 6; i++ {
+		tf.Push(task(fmt.Sprint("io_", i), "io"), task(fmt.Sprint("db_", i), "db", "io"), task(fmt.Sprint("cpu_", i), "cpu"))
+	}
+	// coalesced chains still take slots
+	head, first, second := task("head", "cpu"), task("chained_0", "db"), task("chained_1", "db")
+	head.Then(first).Then(second)
+	tf.Push(head, first, second)
+
+	executor.Run(tf).Wait()
+	if peak["io"] > 2 || peak["db"] > 1 || peak["cpu"] < 2 {
+		t.Errorf("unexpected peaks of tags %v", peak)
+	}
+
+	if _, err := gotaskflow.NewExecutorWithOptions(1, gotaskflow.WithTagConcurrency("io", 0)); err == nil {
+		t.Error("expected zero cap rejected")
+	}
+}
+
+func TestExecutorCoalescing(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithCoalescing())
+	tf := gotaskflow.NewTaskFlow("G")
+	res := make([]int, 0)
+	prev := gotaskflow.NewTask("start", func() {})
+	tf.Push(prev)
+	for i := 0; i < 100; i++ {
+		i := i
+		task := gotaskflow.NewTask(fmt.Sprint(i), func() {
+			res = append(res, i)
+		})
+		prev.Precede(task)
+		tf.Push(task)
+		prev = task
+	}
+	executor.Run(tf).Wait()
+	if len(res) != 100 {
+		t.Fatalf("expected 100 tasks to run, got %v", len(res))
+	}
+	for i, v := range res {
+		if i != v {
+			t.Fail()
+		}
+	}
+}
+
+func benchmarkChain(b *testing.B, opts ...gotaskflow.Option) {
+	executor := gotaskflow.NewExecutor(uint(runtime.NumCPU()), opts...)
+	tf := gotaskflow.NewTaskFlow("G")
+	prev := gotaskflow.NewTask("start", func() {})
+	tf.Push(prev)
+	for i := 0; i < 1000; i++ {
+		task := gotaskflow.NewTask(fmt.Sprint(i), func() {})
+		prev.Precede(task)
+		tf.Push(task)
+		prev = task
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		executor.Run(tf).Wait()
+	}
+}
+
+func BenchmarkChain(b *testing.B) {
+	benchmarkChain(b)
+}
+
+func BenchmarkChainCoalescing(b *testing.B) {
+	benchmarkChain(b, gotaskflow.WithCoalescing())
+}
+
+// chanQueue is a WorkQueue on a buffered channel, taking tasks without locking
+type chanQueue chan *gotaskflow.Task
+
+func (q chanQueue) Put(task *gotaskflow.Task) { q <- task }
+func (q chanQueue) Len() int                  { return len(q) }
+func (q chanQueue) PeakAndTake() *gotaskflow.Task {
+	select {
+	case task := <-q:
+		return task
+	default:
+		return nil
+	}
+}
+
+func BenchmarkChainChanQueue(b *testing.B) {
+	benchmarkChain(b, gotaskflow.WithWorkQueue(make(chanQueue, 1<<16)))
+}
+
+// BenchmarkBuildChain reports heap held per node of a built 1M-node chain
+func BenchmarkBuildChain(b *testing.B) {
+	const size = 1000000
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		tf := gotaskflow.NewTaskFlow("G")
+		prev := gotaskflow.NewTask("0", func() {})
+		tf.Push(prev)
+		for j := 1; j < size; j++ {
+			task := gotaskflow.NewTask(strconv.Itoa(j), func() {})
+			prev.Precede(task)
+			tf.Push(task)
+			prev = task
+		}
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/size, "bytes/node")
+		runtime.KeepAlive(tf)
+		b.StartTimer()
+	}
+}
+
+func TestExecutorProgress(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithProgressBuffer(16))
+	ch := executor.Progress()
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {})
+	A.Precede(B)
+	tf.Push(A, B)
+	executor.Run(tf).Wait()
+
+	expected := []gotaskflow.ProgressEvent{
+		{NodeName: "A", GraphName: "G", Event: gotaskflow.NodeStarted},
+		{NodeName: "A", GraphName: "G", Event: gotaskflow.NodeFinished},
+		{NodeName: "B", GraphName: "G", Event: gotaskflow.NodeStarted},
+		{NodeName: "B", GraphName: "G", Event: gotaskflow.NodeFinished},
+		{GraphName: "G", Event: gotaskflow.GraphComplete},
+	}
+	for _, ev := range expected {
+		real := <-ch
+		if real.NodeName != ev.NodeName || real.GraphName != ev.GraphName || real.Event != ev.Event {
+			t.Errorf("expected %v, got %v", ev, real)
+		}
+	}
+
+	executor.Run(tf).Wait()
+	executor.Run(tf).Wait()
+	executor.Run(tf).Wait()
+	executor.Run(tf).Wait()
+	if executor.ProgressDropped() != 4 {
+		t.Errorf("expected 4 dropped events, got %v", executor.ProgressDropped())
+	}
+}
+
+type skipObserver struct {
+	gotaskflow.BaseObserver
+	skip     string
+	started  atomic.Int32
+	finished atomic.Int32
+}
+
+func (o *skipObserver) OnScheduled(task *gotaskflow.Task) bool {
+	return task.Name() == o.skip
+}
+
+func (o *skipObserver) OnStarted(task *gotaskflow.Task) {
+	o.started.Add(1)
+}
+
+func (o *skipObserver) OnFinished(task *gotaskflow.Task, failed bool) {
+	o.finished.Add(1)
+}
+
+func TestExecutorObserverSkip(t *testing.T) {
+	for _, opts := range [][]gotaskflow.Option{{}, {gotaskflow.WithCoalescing()}} {
+		obs := &skipObserver{skip: "B"}
+		executor := gotaskflow.NewExecutor(4, append(opts, gotaskflow.WithObserver(obs))...)
+		tf := gotaskflow.NewTaskFlow("G")
+		ran := make([]string, 0)
+		A, B, C :=
+			gotaskflow.NewTask("A", func() {
+				ran = append(ran, "A")
+			}),
+			gotaskflow.NewTask("B", func() {
+				ran = append(ran, "B")
+			}),
+			gotaskflow.NewTask("C", func() {
+				ran = append(ran, "C")
+			})
+		A.Precede(B)
+		B.Precede(C)
+		tf.Push(A, B, C)
+		executor.Run(tf).Wait()
+
+		if fmt.Sprint(ran) != "[A C]" {
+			t.Errorf("expected B to be skipped, got %v", ran)
+		}
+		if obs.started.Load() != 2 || obs.finished.Load() != 2 {
+			t.Errorf("expected 2 tasks observed, got %v started, %v finished", obs.started.Load(), obs.finished.Load())
+		}
+	}
+}
+
+func TestExecutorHandleRelease(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithHandleRelease())
+	collected := make(chan struct{})
+	tf := gotaskflow.NewTaskFlow("G")
+	func() {
+		data := &[1 << 20]byte{}
+		runtime.SetFinalizer(data, func(*[1 << 20]byte) {
+			close(collected)
+		})
+		A := gotaskflow.NewTask("A", func() {
+			data[0] = 1
+		})
+		sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+			sf.Push(gotaskflow.NewTask("B", func() {
+				data[1] = 1
+			}))
+		})
+		A.Precede(sub)
+		tf.Push(A, sub)
+	}()
+	executor.Run(tf).Wait()
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case <-collected:
+			runtime.KeepAlive(tf)
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("data captured by finished tasks is not collected")
+	runtime.KeepAlive(tf)
+}
+
+func TestExecutorDrain(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	if err := executor.Drain(); err != nil {
+		t.Fatal(err)
+	}
+
+	tf := gotaskflow.NewTaskFlow("G")
+	release, started := make(chan struct{}), make(chan struct{}, 4)
+	var finished atomic.Int32
+	for i := 0; i < 4; i++ {
+		tf.Push(gotaskflow.NewTask(fmt.Sprint(i), func() {
+			started <- struct{}{}
+			<-release
+			finished.Add(1)
+		}))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		executor.Run(tf).Wait()
+		close(done)
+	}()
+	<-started
+
+	drained := make(chan error)
+	go func() {
+		drained <- executor.Drain()
+	}()
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain waits for running tasks")
+	}
+	if finished.Load() != 0 {
+		t.Errorf("expected running tasks not finished, got %v", finished.Load())
+	}
+
+	close(release)
+	<-done
+}
+
+func TestExecutorLIFO(t *testing.T) {
+	executor := gotaskflow.NewExecutor(1, gotaskflow.WithSchedulePolicy(gotaskflow.LIFO))
+	tf := gotaskflow.NewTaskFlow("G")
+	var order []string
+	record := func(name string) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() { order = append(order, name) })
+	}
+
+	a1, a2, b1, b2 := record("a1"), record("a2"), record("b1"), record("b2")
+	high, low := record("high").Priority(gotaskflow.HIGH), record("low").Priority(gotaskflow.LOW)
+	a1.Precede(a2)
+	b1.Precede(b2)
+	tf.Push(a1, a2, b1, b2, high, low)
+	executor.Run(tf).Wait()
+
+	pos := make(map[string]int)
+	for i, name := range order {
+		pos[name] = i
+	}
+	if len(order) != 6 || pos["a1"] > pos["a2"] || pos["b1"] > pos["b2"] {
+		t.Errorf("unexpected order %v", order)
+	}
+}
+
+func TestExecutorResetProfile(t *testing.T) {
+	executor := gotaskflow.NewExecutor(2)
+	run := func(name string) {
+		tf := gotaskflow.NewTaskFlow(name)
+		tf.Push(gotaskflow.NewTask(name+"_task", func() {}))
+		executor.Run(tf).Wait()
+	}
+
+	run("old")
+	executor.ResetProfile()
+	run("new")
+
+	var buf bytes.Buffer
+	if err := executor.Profile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "old_task") || !strings.Contains(buf.String(), "new_task") {
+		t.Errorf("unexpected profile %v", buf.String())
+	}
+}
+
+func TestExecutorSpanLabeler(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	tf := gotaskflow.NewTaskFlow("G")
+	// shards cannot share name fetch since task names are unique in a graph
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected shared task name to panic")
+			}
+		}()
+		gotaskflow.NewTaskFlow("shared").Push(gotaskflow.NewTask("fetch", func() {}), gotaskflow.NewTask("fetch", func() {}))
+	}()
+	for _, shard := range []int{3, 7} {
+		shard := shard
+		tf.Push(gotaskflow.NewTask(fmt.Sprintf("fetch_%d", shard), func() {}).WithSpanLabeler(func(t *gotaskflow.Task) string {
+			return fmt.Sprintf("%s[shard=%d]", strings.Split(t.Name(), "_")[0], shard)
+		}))
+	}
+	executor.Run(tf).Wait()
+
+	var buf bytes.Buffer
+	if err := executor.Profile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "fetch[shard=3]") || !strings.Contains(buf.String(), "fetch[shard=7]") {
+		t.Errorf("expected labeled spans, got %v", buf.String())
+	}
+
+	// a panicking labeler falls back to task name, for every type of task
+	executor = gotaskflow.NewExecutor(4)
+	tf = gotaskflow.NewTaskFlow("G")
+	broken := func(t *gotaskflow.Task) string { panic("broken labeler") }
+	var ran atomic.Int32
+	cond := gotaskflow.NewCondition("cond", func() uint { ran.Add(1); return 0 }).WithSpanLabeler(broken)
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("inner", func() { ran.Add(1) }))
+	}).WithSpanLabeler(broken)
+	static := gotaskflow.NewTask("static", func() { ran.Add(1) }).WithSpanLabeler(broken)
+	cond.Precede(sub)
+	tf.Push(cond, sub, static)
+	if err := gotaskflow.WaitAll(executor.RunAsync(tf)); err != nil || ran.Load() != 3 {
+		t.Errorf("expected all tasks run, got %v runs, err %v", ran.Load(), err)
+	}
+	buf.Reset()
+	if err := executor.Profile(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cond", "sub", "static"} {
+		if !strings.Contains(buf.String(), ","+name) {
+			t.Errorf("expected span of %v named by task, got %v", name, buf.String())
+		}
+	}
+}
+
+func TestExecutorRunAsync(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	flow := func(name string, d time.Duration) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow(name)
+		tf.Push(gotaskflow.NewTask(name, func() { time.Sleep(d) }))
+		return tf
+	}
+
+	slow, fast := executor.RunAsync(flow("slow", 100*time.Millisecond)), executor.RunAsync(flow("fast", time.Millisecond))
+	select {
+	case <-fast.Done():
+	case <-slow.Done():
+		t.Fatal("expected fast flow to finish first")
+	}
+	<-slow.Done()
+	if slow.Err() != nil || fast.Err() != nil {
+		t.Errorf("unexpected errors %v %v", slow.Err(), fast.Err())
+	}
+
+	tf := gotaskflow.NewTaskFlow("panic")
+	tf.Push(gotaskflow.NewTask("boom", func() { panic("boom") }))
+	h := executor.RunAsync(tf)
+	select {
+	case <-h.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("panicked flow never done")
+	}
+	if h.Err() == nil || !strings.Contains(h.Err().Error(), "boom") {
+		t.Errorf("expected panic error, got %v", h.Err())
+	}
+	if !h.Canceled() || !tf.Canceled() {
+		t.Error("expected run failed by panic canceled")
+	}
+
+	ok := gotaskflow.NewTaskFlow("ok")
+	ok.Push(gotaskflow.NewTask("A", func() {}))
+	executor.Run(ok).Wait()
+	if ok.Canceled() {
+		t.Error("unexpected canceled of normal run")
+	}
+}
+
+func TestExecutorWaitAllAny(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	speculative := func(name string, d time.Duration, ran *atomic.Bool) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow(name)
+		A := gotaskflow.NewTask("A", func() { time.Sleep(d) })
+		B := gotaskflow.NewTask("B", func() { ran.Store(true) })
+		A.Precede(B)
+		tf.Push(A, B)
+		return tf
+	}
+
+	var fastRan, slowRan atomic.Bool
+	slow := executor.RunAsync(speculative("slow", 100*time.Millisecond, &slowRan))
+	fast := executor.RunAsync(speculative("fast", time.Millisecond, &fastRan))
+	if winner := gotaskflow.WaitAny(slow, fast); winner != 1 {
+		t.Fatalf("expected fast flow to win, got %v", winner)
+	}
+	if err := gotaskflow.WaitAll(slow, fast); !errors.Is(err, gotaskflow.ErrCanceled) {
+		t.Errorf("expected loser canceled, got %v", err)
+	}
+	if !fastRan.Load() || slowRan.Load() {
+		t.Errorf("expected loser not to continue, got %v %v", fastRan.Load(), slowRan.Load())
+	}
+	if !slow.Canceled() || fast.Canceled() {
+		t.Errorf("expected only loser canceled, got %v %v", slow.Canceled(), fast.Canceled())
+	}
+
+	if gotaskflow.WaitAny() != -1 || gotaskflow.WaitAll() != nil {
+		t.Errorf("unexpected result of no handles")
+	}
+}
+
+func TestExecutorCancelBeforeStart(t *testing.T) {
+	// the second run waits for the first one, so that it is canceled before it starts
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithRunPolicy(gotaskflow.RunSerialize))
+	blocked, release := make(chan struct{}), make(chan struct{})
+	first := gotaskflow.NewTaskFlow("first")
+	first.Push(gotaskflow.NewTask("block", func() {
+		close(blocked)
+		<-release
+	}))
+	var ran atomic.Bool
+	second := gotaskflow.NewTaskFlow("second")
+	second.Push(gotaskflow.NewTask("A", func() { ran.Store(true) }))
+
+	hf := executor.RunAsync(first)
+	<-blocked
+	hs := executor.RunAsync(second)
+	hs.Cancel()
+	close(release)
+	if err := gotaskflow.WaitAll(hf, hs); !errors.Is(err, gotaskflow.ErrCanceled) {
+		t.Errorf("expected second run canceled, got %v", err)
+	}
+	if ran.Load() || !hs.Canceled() || hf.Canceled() {
+		t.Errorf("expected second run canceled before any task ran, got ran %v, canceled %v", ran.Load(), hs.Canceled())
+	}
+
+	// cancel is kept by the handle only, running taskflow again is not affected
+	executor.Run(second).Wait()
+	if !ran.Load() || second.Canceled() {
+		t.Errorf("expected later run not canceled")
+	}
+}
+
+func TestExecutorCooperativeCancel(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	tf := gotaskflow.NewTaskFlow("G")
+	exited := make(chan struct{})
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewControlledTask("loop", func(tc gotaskflow.TaskControl) {
+			defer close(exited)
+			for !tc.Canceled() {
+				time.Sleep(time.Millisecond)
+			}
+		}))
+	})
+	boom := gotaskflow.NewTask("boom", func() {
+		time.Sleep(10 * time.Millisecond)
+		panic("boom")
+	})
+	tf.Push(sub, boom)
+
+	h := executor.RunAsync(tf)
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("looping handler never noticed cancellation")
+	}
+	<-h.Done()
+	if h.Err() == nil {
+		t.Errorf("expected panic error")
+	}
+}
+
+func TestExecutorStrictCounting(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithStrictCounting())
+	tf := gotaskflow.NewTaskFlow("G")
+	i := 0
+	init := gotaskflow.NewTask("init", func() {})
+	cond := gotaskflow.NewCondition("while i < 5", func() uint {
+		if i < 5 {
+			return 0
+		}
+		return 1
+	})
+	body := gotaskflow.NewTask("body", func() { i++ })
+	back := gotaskflow.NewCondition("back", func() uint { return 0 })
+	done := gotaskflow.NewTask("done", func() {})
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		A, B := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {})
+		A.Precede(B)
+		sf.Push(A, B)
+	})
+	init.Precede(cond)
+	cond.Precede(body, done)
+	body.Precede(back)
+	back.Precede(cond)
+	done.Precede(sub)
+	tf.Push(init, cond, body, back, done, sub)
+
+	for run := 0; run < 2; run++ {
+		i = 0
+		if h := executor.RunAsync(tf); gotaskflow.WaitAll(h) != nil {
+			t.Fatalf("unexpected imbalance %v", h.Err())
+		}
+	}
+}
+
+func TestExecutorSubflowMaxConcurrency(t *testing.T) {
+	executor := gotaskflow.NewExecutor(16)
+	tf := gotaskflow.NewTaskFlow("G")
+	capped := func(name string, limit uint, running, maxRunning, done *atomic.Int32) *gotaskflow.Task {
+		return gotaskflow.NewSubflow(name, func(sf *gotaskflow.Subflow) {
+			sf.WithMaxConcurrency(limit)
+			for i := 0; i < 24; i++ {
+				sf.Push(gotaskflow.NewTask(fmt.Sprintf("%v_%d", name, i), func() {
+					n := running.Add(1)
+					for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+					}
+					time.Sleep(5 * time.Millisecond)
+					running.Add(-1)
+					done.Add(1)
+				}))
+			}
+		})
+	}
+	var narrowRunning, narrowMax, narrowDone, wideRunning, wideMax, wideDone atomic.Int32
+	tf.Push(capped("narrow", 2, &narrowRunning, &narrowMax, &narrowDone), capped("wide", 8, &wideRunning, &wideMax, &wideDone))
+	executor.Run(tf).Wait()
+
+	if narrowDone.Load() != 24 || wideDone.Load() != 24 {
+		t.Errorf("expected all tasks done, got %v and %v", narrowDone.Load(), wideDone.Load())
+	}
+	if m := narrowMax.Load(); m < 1 || m > 2 {
+		t.Errorf("expected at most 2 tasks of narrow running, got %v", m)
+	}
+	if m := wideMax.Load(); m <= 2 || m > 8 {
+		t.Errorf("expected 3 to 8 tasks of wide running, got %v", m)
+	}
+}
+
+func TestExecutorSubmitOnce(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	var runs atomic.Int32
+	release := make(chan struct{})
+	tf.Push(gotaskflow.NewTask("A", func() {
+		runs.Add(1)
+		<-release
+	}))
+
+	h := executor.SubmitOnce(tf)
+	if h == nil {
+		t.Fatalf("expected first submit to run")
+	}
+	for i := 0; i < 3; i++ {
+		if executor.SubmitOnce(tf) != nil {
+			t.Errorf("expected submit while running to be dropped")
+		}
+	}
+	close(release)
+	<-h.Done()
+	if h.Err() != nil || runs.Load() != 1 {
+		t.Errorf("unexpected err %v or runs %v", h.Err(), runs.Load())
+	}
+
+	h = executor.SubmitOnce(tf)
+	if h == nil {
+		t.Fatalf("expected submit after completion to run")
+	}
+	<-h.Done()
+	if runs.Load() != 2 {
+		t.Errorf("expected 2 runs, got %v", runs.Load())
+	}
+}
+
+type runIDObserver struct {
+	gotaskflow.BaseObserver
+	runs sync.Map // task name -> run id
+}
+
+func (o *runIDObserver) OnFinished(task *gotaskflow.Task, failed bool) {
+	o.runs.Store(task.Name(), task.RunID())
+}
+
+func TestExecutorRunID(t *testing.T) {
+	obs := &runIDObserver{}
+	executor := gotaskflow.NewExecutor(8, gotaskflow.WithObserver(obs))
+	newFlow := func(prefix string) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow("G")
+		prev := gotaskflow.NewTask(prefix+"_0", func() { time.Sleep(time.Millisecond) })
+		tf.Push(prev)
+		for i := 1; i < 5; i++ {
+			i := i
+			task := gotaskflow.NewTask(fmt.Sprintf("%v_%d", prefix, i), func() { time.Sleep(time.Millisecond) })
+			sub := gotaskflow.NewSubflow(fmt.Sprintf("%v_sub_%d", prefix, i), func(sf *gotaskflow.Subflow) {
+				sf.Push(gotaskflow.NewTask(fmt.Sprintf("%v_inner_%d", prefix, i), func() {}))
+			})
+			prev.Precede(task, sub)
+			tf.Push(task, sub)
+			prev = task
+		}
+		return tf
+	}
+
+	a, b := newFlow("left"), newFlow("right")
+	ha := executor.RunAsync(a, gotaskflow.WithRunID(100))
+	hb := executor.RunAsync(b)
+	if err := gotaskflow.WaitAll(ha, hb); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ha.RunID() != 100 || hb.RunID() == 0 || hb.RunID() == 100 || b.RunID() != hb.RunID() {
+		t.Fatalf("unexpected run ids %v and %v", ha.RunID(), hb.RunID())
+	}
+
+	for _, h := range []*gotaskflow.RunHandle{ha, hb} {
+		spans := executor.Spans(h.RunID())
+		if len(spans) != 13 {
+			t.Errorf("expected 13 spans of run %v, got %v", h.RunID(), len(spans))
+		}
+		prefix := "left_"
+		if h == hb {
+			prefix = "right_"
+		}
+		for _, s := range spans {
+			if s.RunID != h.RunID() || !strings.HasPrefix(s.Name, prefix) {
+				t.Errorf("span %v of run %v mixed into run %v", s.Name, s.RunID, h.RunID())
+			}
+		}
+	}
+	if n := len(executor.Spans()); n != 26 {
+		t.Errorf("expected 26 spans in total, got %v", n)
+	}
+
+	var buf bytes.Buffer
+	if err := executor.Profile(&buf, ha.RunID()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(buf.String(), "right_") {
+		t.Errorf("unexpected spans of other run in profile %v", buf.String())
+	}
+	buf.Reset()
+	if err := executor.ProfileRun(hb.RunID(), &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(buf.String(), "left_") || !strings.Contains(buf.String(), "right_inner_") {
+		t.Errorf("unexpected profile of run %v: %v", hb.RunID(), buf.String())
+	}
+	if err := executor.ProfileRun(12345, &buf); err == nil || !strings.Contains(err.Error(), "no profile of run 12345") {
+		t.Errorf("expected error of unknown run, got %v", err)
+	}
+
+	obs.runs.Range(func(name, run any) bool {
+		if expected := map[bool]uint64{true: ha.RunID(), false: hb.RunID()}[strings.HasPrefix(name.(string), "left_")]; run != expected {
+			t.Errorf("observer got run %v of %v, expected %v", run, name, expected)
+		}
+		return true
+	})
+}
+
+func TestExecutorProfileSampling(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithProfileSampleInterval(time.Millisecond))
+	tf := gotaskflow.NewTaskFlow("G")
+	slow := gotaskflow.NewTask("slow", func() { time.Sleep(40 * time.Millisecond) })
+	sf := gotaskflow.NewSubflow("sf", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("inner", func() { time.Sleep(40 * time.Millisecond) }))
+	})
+	tf.Push(slow, sf)
+	for i := 0; i < 100; i++ {
+		tf.Push(gotaskflow.NewTask(fmt.Sprintf("tiny_%d", i), func() {}))
+	}
+	executor.Run(tf).Wait()
+
+	if spans := executor.Spans(); len(spans) != 0 {
+		t.Errorf("expected no spans in sampling mode, got %v", len(spans))
+	}
+	var buf bytes.Buffer
+	if err := executor.Profile(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	costs := make(map[string]int)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var cost int
+		path, us, _ := strings.Cut(line, " ")
+		fmt.Sscan(us, &cost)
+		costs[path] = cost
+	}
+	// sampled time is approximate, while tiny tasks are mostly missed
+	if costs["static,slow"] < 10000 || costs["subflow,sf;static,inner"] < 10000 {
+		t.Errorf("unexpected sampled profile %v", buf.String())
+	}
+}
+
+func TestExecutorOptions(t *testing.T) {
+	run := func(executor gotaskflow.Executor) string {
+		tf := gotaskflow.NewTaskFlow("G")
+		tf.Push(gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() { panic("boom") }))
+		executor.Run(tf).Wait()
+		var buf bytes.Buffer
+		if err := executor.Profile(&buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return buf.String()
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		executor, err := gotaskflow.NewExecutorWithOptions(4, gotaskflow.WithLogger(io.Discard))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if profile := run(executor); !strings.Contains(profile, "static,A") {
+			t.Errorf("expected profiling on by default, got %q", profile)
+		}
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		var first, last bytes.Buffer
+		executor, err := gotaskflow.NewExecutorWithOptions(4,
+			gotaskflow.WithProfiling(false), gotaskflow.WithLogger(&first),
+			gotaskflow.WithProfiling(true), gotaskflow.WithLogger(&last))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if profile := run(executor); !strings.Contains(profile, "static,A") {
+			t.Errorf("expected later WithProfiling to win, got %q", profile)
+		}
+		if first.Len() != 0 || !strings.Contains(last.String(), "[recovered] static B") {
+			t.Errorf("expected later WithLogger to win, got %q and %q", first.String(), last.String())
+		}
+	})
+
+	t.Run("profiling off", func(t *testing.T) {
+		executor := gotaskflow.NewExecutor(4, gotaskflow.WithProfiling(false), gotaskflow.WithLogger(io.Discard))
+		if profile := run(executor); profile != "" {
+			t.Errorf("expected empty profile, got %q", profile)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if e, err := gotaskflow.NewExecutorWithOptions(0); err == nil || e != nil {
+			t.Errorf("expected error of zero concurrency")
+		}
+		if _, err := gotaskflow.NewExecutorWithOptions(1 << 30); err == nil || !strings.Contains(err.Error(), "exceeds") {
+			t.Errorf("expected error of absurd concurrency, got %v", err)
+		}
+		if e, err := gotaskflow.NewExecutorWithOptions(uint(runtime.NumCPU())); err != nil || e == nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		// NewExecutor keeps accepting large concurrency as at baseline, such as examples/simple
+		if e := gotaskflow.NewExecutor(1 << 30); e == nil {
+			t.Errorf("expected executor of large concurrency")
+		}
+		_, err := gotaskflow.NewExecutorWithOptions(4, gotaskflow.WithStaticConcurrency(0), gotaskflow.WithLogger(nil), gotaskflow.WithMaxSubflowDepth(0),
+			gotaskflow.WithMaxQueueDepth(0))
+		if err == nil || !strings.Contains(err.Error(), "task type concurrency") || !strings.Contains(err.Error(), "logger") ||
+			!strings.Contains(err.Error(), "subflow depth") || !strings.Contains(err.Error(), "queue depth") {
+			t.Errorf("expected errors of all invalid options, got %v", err)
+		}
+		_, err = gotaskflow.NewExecutorWithOptions(4, gotaskflow.WithPriorityAging(0.1), gotaskflow.WithSchedulePolicy(gotaskflow.LIFO))
+		if err == nil || !strings.Contains(err.Error(), "conflicts") {
+			t.Errorf("expected error of conflicting options, got %v", err)
+		}
+
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected NewExecutor to panic")
+			}
+		}()
+		gotaskflow.NewExecutor(4, gotaskflow.WithStaticConcurrency(0))
+	})
+}
+
+func TestExecutorSubflowTimeout(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	var after, next, noticed atomic.Bool
+	sf := gotaskflow.NewSubflow("sf", func(sf *gotaskflow.Subflow) {
+		slow := gotaskflow.NewTask("slow", func() { time.Sleep(50 * time.Millisecond) })
+		poll := gotaskflow.NewControlledTask("poll", func(tc gotaskflow.TaskControl) {
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				if tc.Canceled() {
+					noticed.Store(true)
+					return
+				}
+			}
+		})
+		then := gotaskflow.NewTask("after", func() { after.Store(true) })
+		slow.Precede(then)
+		sf.Push(slow, then, poll)
+	}).WithTimeout(10 * time.Millisecond)
+	following := gotaskflow.NewTask("next", func() { next.Store(true) })
+	sf.Precede(following)
+	tf.Push(sf, following)
+
+	begin := time.Now()
+	h := executor.RunAsync(tf)
+	<-h.Done()
+	if !errors.Is(h.Err(), gotaskflow.ErrTimeout) {
+		t.Errorf("expected timeout, got %v", h.Err())
+	}
+	if !noticed.Load() || after.Load() || next.Load() {
+		t.Errorf("expected subflow canceled, got noticed %v, after %v, next %v", noticed.Load(), after.Load(), next.Load())
+	}
+	if cost := time.Since(begin); cost > 500*time.Millisecond {
+		t.Errorf("expected subflow canceled early, took %v", cost)
+	}
+}
+
+func TestExecutorDeadline(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	var ran, noticed atomic.Int32
+	slow := gotaskflow.NewTask("slow", func() {
+		ran.Add(1)
+		time.Sleep(50 * time.Millisecond)
+	})
+	after := gotaskflow.NewTask("after", func() { ran.Add(1) })
+	sf := gotaskflow.NewSubflow("sf", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewControlledTask("poll", func(tc gotaskflow.TaskControl) {
+			for deadline := time.Now().Add(300 * time.Millisecond); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				if tc.Canceled() {
+					noticed.Add(1)
+					return
+				}
+			}
+		}))
+	})
+	slow.Precede(after)
+	tf.Push(slow, after, sf)
+
+	tf.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	h := executor.RunAsync(tf)
+	<-h.Done()
+	if !errors.Is(h.Err(), gotaskflow.ErrDeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", h.Err())
+	}
+	if ran.Load() != 1 || noticed.Load() != 1 {
+		t.Errorf("expected only slow to run and subflow canceled, got ran %v, noticed %v", ran.Load(), noticed.Load())
+	}
+
+	// a passed deadline cancels run right away
+	h = executor.RunAsync(tf)
+	<-h.Done()
+	if !errors.Is(h.Err(), gotaskflow.ErrDeadlineExceeded) || ran.Load() != 1 {
+		t.Errorf("expected nothing to run, got err %v, ran %v", h.Err(), ran.Load())
+	}
+
+	tf.SetDeadline(time.Time{})
+	h = executor.RunAsync(tf)
+	<-h.Done()
+	if h.Err() != nil || ran.Load() != 3 {
+		t.Errorf("expected run without deadline, got err %v, ran %v", h.Err(), ran.Load())
+	}
+}
+
+func TestExecutorWorkerLocalStorage(t *testing.T) {
+	type conn struct{ id int32 }
+	var created atomic.Int32
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithWorkerLocalStorage(func() *conn {
+		return &conn{id: created.Add(1)}
+	}))
+	if _, ok := gotaskflow.WorkerLocal[*conn](); ok {
+		t.Errorf("expected no value outside of tasks")
+	}
+
+	tf := gotaskflow.NewTaskFlow("G")
+	var arrived sync.WaitGroup
+	arrived.Add(4)
+	seen := make([]*conn, 4)
+	for i := 0; i < 4; i++ {
+		i := i
+		tf.Push(gotaskflow.NewTask(fmt.Sprintf("task_%d", i), func() {
+			c, ok := gotaskflow.WorkerLocal[*conn]()
+			if !ok {
+				t.Errorf("expected value of worker")
+			}
+			if _, ok := gotaskflow.WorkerLocal[string](); ok {
+				t.Errorf("expected no value of type without storage")
+			}
+			seen[i] = c
+			// all tasks hold their values at once
+			arrived.Done()
+			arrived.Wait()
+		}))
+	}
+	executor.Run(tf).Wait()
+
+	for i := range seen {
+		for j := i + 1; j < len(seen); j++ {
+			if seen[i] == seen[j] {
+				t.Errorf("tasks running at once share value %v", seen[i].id)
+			}
+		}
+	}
+	if created.Load() != 4 {
+		t.Errorf("expected 4 values created, got %v", created.Load())
+	}
+}
+
+func TestExecutorAffinity(t *testing.T) {
+	executor := gotaskflow.NewExecutor(8, gotaskflow.WithAffinityThreadLock())
+	tf := gotaskflow.NewTaskFlow("G")
+	var mu sync.Mutex
+	markers := make(map[int]struct{})
+	var running, maxRunning atomic.Int32
+	for i := 0; i < 20; i++ {
+		tf.Push(gotaskflow.NewTask(fmt.Sprintf("affine_%d", i), func() {
+			n := running.Add(1)
+			for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+			}
+			mu.Lock()
+			markers[utils.GoroutineID()] = struct{}{}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+		}).WithAffinity("clib"))
+		tf.Push(gotaskflow.NewTask(fmt.Sprintf("plain_%d", i), func() { time.Sleep(time.Millisecond) }))
+	}
+	executor.Run(tf).Wait()
+
+	if len(markers) != 1 || maxRunning.Load() != 1 {
+		t.Fatalf("expected affine tasks to run one by one on one goroutine, got goroutines %v, max running %v", markers, maxRunning.Load())
+	}
+
+	executor.Close()
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	for id := range markers {
+		if bytes.Contains(buf, []byte(fmt.Sprintf("goroutine %d [", id))) {
+			t.Errorf("expected affinity goroutine %v to stop after Close", id)
+		}
+	}
+}
+
+func TestExecutorAffinityID(t *testing.T) {
+	executor := gotaskflow.NewExecutor(8)
+	defer executor.Close()
+	tf := gotaskflow.NewTaskFlow("G")
+	var mu sync.Mutex
+	goroutines := make(map[string]map[int]struct{})
+	affine := func(name, group string) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if goroutines[group] == nil {
+				goroutines[group] = make(map[int]struct{})
+			}
+			goroutines[group][utils.GoroutineID()] = struct{}{}
+		})
+	}
+	for i := 0; i < 10; i++ {
+		tf.Push(affine(fmt.Sprintf("id1_%d", i), "id 1").Affinity(1))
+		tf.Push(affine(fmt.Sprintf("id2_%d", i), "id 2").Affinity(2))
+		// numbered ids do not share goroutines with keys of the same text
+		tf.Push(affine(fmt.Sprintf("key1_%d", i), "key 1").WithAffinity("1"))
+	}
+	executor.Run(tf).Wait()
+
+	seen := make(map[int]string)
+	for group, ids := range goroutines {
+		if len(ids) != 1 {
+			t.Fatalf("expected tasks of %v on one goroutine, got %v", group, ids)
+		}
+		for id := range ids {
+			if other, ok := seen[id]; ok {
+				t.Errorf("expected %v and %v on different goroutines", group, other)
+			}
+			seen[id] = group
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected 3 affinity goroutines, got %v", goroutines)
+	}
+}
+
+func TestExecutorErrorTask(t *testing.T) {
+	var logs bytes.Buffer
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithLogger(&logs))
+	errBoom := errors.New("boom")
+	tf := gotaskflow.NewTaskFlow("G")
+	var ran []string
+	var mu sync.Mutex
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, name)
+	}
+	ok := gotaskflow.NewErrorTask("ok", func() error {
+		record("ok")
+		return nil
+	})
+	fail := gotaskflow.NewErrorTask("fail", func() error {
+		record("fail")
+		return errBoom
+	})
+	after := gotaskflow.NewTask("after", func() { record("after") })
+	ok.Precede(fail)
+	fail.Precede(after)
+	tf.Push(ok, fail, after)
+
+	h := executor.RunAsync(tf)
+	<-h.Done()
+	if !errors.Is(h.Err(), errBoom) {
+		t.Errorf("expected error of task, got %v", h.Err())
+	}
+	if fmt.Sprint(ran) != "[ok fail]" {
+		t.Errorf("expected successors of failed task canceled, got %v", ran)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("expected no panic report, got %v", logs.String())
+	}
+}
+
+func TestExecutorRunningTasks(t *testing.T) {
+	executor := gotaskflow.NewExecutor(2, gotaskflow.WithTaskTracking())
+	tf := gotaskflow.NewTaskFlow("G")
+	release := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		tf.Push(gotaskflow.NewTask(fmt.Sprint("slow_", i), func() { <-release }))
+	}
+
+	h := executor.RunAsync(tf)
+	var running, queued []gotaskflow.TaskInfo
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		if running, queued = executor.RunningTasks(), executor.QueuedTasks(); len(running) == 2 && len(queued) == 2 {
+			break
+		}
+	}
+	close(release)
+	<-h.Done()
+
+	if len(running) != 2 || len(queued) != 2 {
+		t.Fatalf("expected 2 running and 2 queued tasks, got %v and %v", running, queued)
+	}
+	for _, info := range append(running, queued...) {
+		if !strings.HasPrefix(info.Name, "slow_") || info.Graph != "G" || info.RunID != h.RunID() || info.Since.IsZero() {
+			t.Errorf("unexpected task info %+v", info)
+		}
+	}
+	if len(executor.RunningTasks()) != 0 || len(executor.QueuedTasks()) != 0 {
+		t.Errorf("expected no task left, got %v and %v", executor.RunningTasks(), executor.QueuedTasks())
+	}
+
+	// not tracked by default
+	untracked := gotaskflow.NewExecutor(2)
+	release = make(chan struct{})
+	h = untracked.RunAsync(tf)
+	time.Sleep(10 * time.Millisecond)
+	running, queued = untracked.RunningTasks(), untracked.QueuedTasks()
+	close(release)
+	<-h.Done()
+	if running == nil || len(running) != 0 || len(queued) != 0 {
+		t.Errorf("expected no tasks listed without tracking, got %v and %v", running, queued)
+	}
+}
+
+type deadlineObserver struct {
+	gotaskflow.BaseObserver
+	warned sync.Map // task name -> soft deadline
+}
+
+func (o *deadlineObserver) OnSoftDeadline(task *gotaskflow.Task, d time.Duration) {
+	o.warned.Store(task.Name(), d)
+}
+
+// finishObserver implements Observer without BaseObserver nor OnSoftDeadline, as before soft deadlines
+type finishObserver struct{ finished atomic.Int32 }
+
+func (o *finishObserver) OnScheduled(task *gotaskflow.Task) bool        { return false }
+func (o *finishObserver) OnStarted(task *gotaskflow.Task)               {}
+func (o *finishObserver) OnFinished(task *gotaskflow.Task, failed bool) { o.finished.Add(1) }
+
+func TestExecutorSoftDeadline(t *testing.T) {
+	var logs bytes.Buffer
+	obs, plain := &deadlineObserver{}, &finishObserver{}
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithObserver(obs), gotaskflow.WithObserver(plain), gotaskflow.WithLogger(&logs))
+	tf := gotaskflow.NewTaskFlow("G")
+	slow := gotaskflow.NewTask("slow", func() { time.Sleep(30 * time.Millisecond) }).WithSoftDeadline(5 * time.Millisecond)
+	fast := gotaskflow.NewTask("fast", func() {}).WithSoftDeadline(time.Second)
+	sf := gotaskflow.NewSubflow("sf", func(sf *gotaskflow.Subflow) {
+		sf.AddTask("inner", func() { time.Sleep(30 * time.Millisecond) })
+	}).WithSoftDeadline(5 * time.Millisecond)
+	slow.Precede(fast)
+	tf.Push(slow, fast, sf)
+
+	h := executor.RunAsync(tf)
+	<-h.Done()
+	if h.Err() != nil {
+		t.Fatalf("expected soft deadline not to fail run, got %v", h.Err())
+	}
+
+	for _, name := range []string{"slow", "sf"} {
+		if d, ok := obs.warned.Load(name); !ok || d != 5*time.Millisecond {
+			t.Errorf("expected %v warned, got %v", name, d)
+		}
+		if !strings.Contains(logs.String(), fmt.Sprintf("%v in graph G, run %d, runs longer than 5ms", name, h.RunID())) {
+			t.Errorf("expected warning of %v logged, got %v", name, logs.String())
+		}
+	}
+	if _, ok := obs.warned.Load("fast"); ok {
+		t.Error("fast is warned")
+	}
+	if n := plain.finished.Load(); n != 4 {
+		t.Errorf("expected observer without OnSoftDeadline notified of 4 tasks, got %v", n)
+	}
+	for _, s := range executor.Spans(h.RunID()) {
+		if over := s.Name == "slow" || s.Name == "sf"; s.OverDeadline != over {
+			t.Errorf("expected span %v over deadline %v", s.Name, over)
+		}
+	}
+}
+
+func TestExecutorNodeStats(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	tf := gotaskflow.NewTaskFlow("G")
+	runs := 0
+	fetch := gotaskflow.NewErrorTask("fetch", func() error {
+		if runs++; runs%2 == 0 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	work := gotaskflow.NewTask("work", func() { time.Sleep(2 * time.Millisecond) })
+	work.Precede(fetch)
+	tf.Push(fetch, work)
+
+	for i := 0; i < 10; i++ {
+		executor.Run(tf).Wait()
+		executor.ResetProfile()
+	}
+
+	if stats := executor.NodeStats("fetch"); stats.RunCount != 10 || stats.Failures != 5 {
+		t.Errorf("unexpected stats of fetch %+v", stats)
+	}
+	if stats := executor.NodeStats("work"); stats.RunCount != 10 || stats.Failures != 0 || stats.TotalDuration < 20*time.Millisecond {
+		t.Errorf("unexpected stats of work %+v", stats)
+	}
+	if stats := executor.NodeStats("unknown"); stats != (gotaskflow.NodeRunStats{}) {
+		t.Errorf("expected no stats, got %+v", stats)
+	}
+}
+
+func TestExecutorProfileFiltered(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	tf := gotaskflow.NewTaskFlow("G")
+	db := gotaskflow.NewTask("query", func() {}).WithLabel("database")
+	io := gotaskflow.NewTask("read", func() {}).WithLabel("io")
+	plain := gotaskflow.NewTask("plain", func() {})
+	sf := gotaskflow.NewSubflow("sf", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("insert", func() {}).WithLabel("database"))
+	})
+	tf.Push(db, io, plain, sf)
+	executor.Run(tf).Wait()
+
+	var buf bytes.Buffer
+	if err := executor.ProfileFiltered(&buf, "database"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "static,query") || !strings.Contains(out, "subflow,sf,") || !strings.Contains(out, "static,insert") {
+		t.Errorf("expected database tasks in profile, got %v", out)
+	}
+	if strings.Contains(out, "read") || strings.Contains(out, "plain") {
+		t.Errorf("expected other tasks filtered out, got %v", out)
+	}
+
+	buf.Reset()
+	if err := executor.ProfileFiltered(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var all bytes.Buffer
+	executor.Profile(&all)
+	if buf.String() != all.String() {
+		t.Errorf("expected the whole profile without labels, got %v", buf.String())
+	}
+}
+
+func TestExecutorRunFrom(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	tf := gotaskflow.NewTaskFlow("G")
+	mu := &sync.Mutex{}
+	ran := make([]string, 0)
+	task := func(name string) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() {
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+		})
+	}
+	// A -> B -> D -> E, C -> D, F unrelated, barrier X arrived at by E
+	a, b, c, d, e, f, x := task("A"), task("B"), task("C"), task("D"), task("E"), task("F"), task("X")
+	gotaskflow.Chain(a, b, d, e)
+	c.Precede(d)
+	e.Arrive(x)
+	tf.Push(a, b, c, d, e, f, x)
+
+	executor.RunFrom(tf, b).Wait()
+	slices.Sort(ran)
+	if !slices.Equal(ran, []string{"B", "D", "E", "X"}) {
+		t.Errorf("unexpected tasks run %v", ran)
+	}
+
+	ran = ran[:0]
+	executor.Run(tf).Wait()
+	if len(ran) != 7 {
+		t.Errorf("expected a full run after partial run, got %v", ran)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic of task not in taskflow")
+		}
+	}()
+	executor.RunFrom(tf, task("other"))
+}
+
+func TestBenchmark(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	tf := gotaskflow.NewTaskFlow("G")
+	delay := 2 * time.Millisecond
+	fast := gotaskflow.NewTask("fast", func() {})
+	slow := gotaskflow.NewTask("slow", func() { time.Sleep(delay) })
+	fast.Precede(slow)
+	tf.Push(fast, slow)
+
+	base, err := gotaskflow.Benchmark(executor, tf, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Iterations != 5 || base.Concurrency != 4 || len(base.Nodes) != 2 || base.Nodes[1].Name != "slow" {
+		t.Fatalf("unexpected report %+v", base)
+	}
+	if n := base.Nodes[1]; n.Runs != 5 || n.Min < delay || base.Wall.Min < delay {
+		t.Errorf("unexpected durations %+v, wall %+v", n, base.Wall)
+	}
+	if base.Utilization < 0 || base.Utilization > 1 {
+		t.Errorf("unexpected utilization %v", base.Utilization)
+	}
+	if !strings.Contains(base.String(), "slow (5 runs)") {
+		t.Errorf("unexpected text report %v", base)
+	}
+	data, err := json.Marshal(base)
+	if err != nil || !bytes.Contains(data, []byte(`"name":"slow"`)) {
+		t.Errorf("unexpected json %s, %v", data, err)
+	}
+
+	delay = 20 * time.Millisecond
+	cur, err := gotaskflow.Benchmark(executor, tf, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	regs := cur.Compare(base, 0.5)
+	if !slices.ContainsFunc(regs, func(r gotaskflow.Regression) bool { return r.Name == "slow" && r.Slower > 2 }) {
+		t.Errorf("expected regression of slow, got %v", regs)
+	}
+
+	if _, err := gotaskflow.Benchmark(executor, tf, 0); err == nil {
+		t.Error("expected error of zero iterations")
+	}
+	tf.Push(gotaskflow.NewErrorTask("broken", func() error { return errors.New("broken") }))
+	if _, err := gotaskflow.Benchmark(executor, tf, 3); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected error of failed iteration, got %v", err)
+	}
+}
+
+func TestExecutorMaxSubflowDepth(t *testing.T) {
+	executor := gotaskflow.NewExecutor(8, gotaskflow.WithMaxSubflowDepth(3))
+	tf := gotaskflow.NewTaskFlow("G")
+	var built atomic.Int32
+	var build func(sf *gotaskflow.Subflow)
+	build = func(sf *gotaskflow.Subflow) {
+		built.Add(1)
+		// builder recursing endlessly by mistake
+		sf.Push(gotaskflow.NewSubflow(fmt.Sprintf("sf_%d", built.Load()), build))
+	}
+	after := gotaskflow.NewTask("after", func() { t.Error("task after failed subflow should not run") })
+	sf := gotaskflow.NewSubflow("sf_0", build)
+	sf.Precede(after)
+	tf.Push(sf, after)
+
+	h := executor.RunAsync(tf)
+	<-h.Done()
+	if err := h.Err(); !errors.Is(err, gotaskflow.ErrSubflowTooDeep) || !strings.Contains(err.Error(), "depth 4") {
+		t.Errorf("expected error of too deep subflow, got %v", err)
+	}
+	if n := built.Load(); n != 3 {
+		t.Errorf("expected 3 subflows built, got %v", n)
+	}
+}
+
+func TestExecutorMaxQueueDepth(t *testing.T) {
+	for _, opt := range []gotaskflow.Option{gotaskflow.WithSchedulePolicy(gotaskflow.FIFO), gotaskflow.WithPriorityAging(0.1), gotaskflow.WithFairScheduling()} {
+		executor := gotaskflow.NewExecutor(2, opt, gotaskflow.WithMaxQueueDepth(4))
+		chain := gotaskflow.NewTaskFlow("chain")
+		var prev *gotaskflow.Task
+		for i := 0; i < 10; i++ {
+			task := gotaskflow.NewTask(fmt.Sprint(i), func() {})
+			prev = prev.Then(task)
+			chain.Push(task)
+		}
+		h := executor.RunAsync(chain)
+		<-h.Done()
+		if err := h.Err(); err != nil {
+			t.Errorf("unexpected error of chain %v", err)
+		}
+
+		// all released at once
+		wide := gotaskflow.NewTaskFlow("wide")
+		var ran atomic.Int32
+		for i := 0; i < 10; i++ {
+			wide.Push(gotaskflow.NewTask(fmt.Sprint(i), func() { ran.Add(1) }))
+		}
+		h = executor.RunAsync(wide)
+		<-h.Done()
+		if err := h.Err(); !errors.Is(err, gotaskflow.ErrQueueFull) || ran.Load() > 4 {
+			t.Errorf("expected error of full queue, got %v with %v tasks run", err, ran.Load())
+		}
+		if s := executor.Stats(); s.Queued != 0 || s.InFlight != 0 {
+			t.Errorf("expected drained executor, got %+v", s)
+		}
+	}
+}
+
+func TestExecutorSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithSlog(logger), gotaskflow.WithStackPolicy(gotaskflow.StackNone))
+	tf := gotaskflow.NewTaskFlow("G")
+	greet := gotaskflow.NewControlledTask("greet", func(tc gotaskflow.TaskControl) {
+		tc.Logger().Info("hello")
+	})
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("boom", func() { panic("exploded") }))
+	})
+	greet.Precede(sub)
+	tf.Push(greet, sub)
+
+	h := executor.RunAsync(tf, gotaskflow.WithRunID(7))
+	<-h.Done()
+	records := make([]map[string]any, 0)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := make(map[string]any)
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("unexpected log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %v", buf.String())
+	}
+	if r := records[0]; r["msg"] != "hello" || r["task"] != "greet" || r["graph"] != "G" || r["run"] != float64(7) {
+		t.Errorf("unexpected record of task %v", r)
+	}
+	if r := records[1]; r["msg"] != "recovered panic" || r["level"] != "ERROR" || r["task"] != "boom" || r["graph"] != "sub" || r["panic"] != "exploded" {
+		t.Errorf("unexpected record of panic %v", r)
+	}
+
+	if _, err := gotaskflow.NewExecutorWithOptions(4, gotaskflow.WithSlog(nil)); err == nil {
+		t.Error("expected error of nil slog logger")
+	}
+}
+
+func TestTaskCallbacks(t *testing.T) {
+	var buf bytes.Buffer
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithLogger(&buf))
+	tf := gotaskflow.NewTaskFlow("G")
+	var mu sync.Mutex
+	events := make([]string, 0)
+	record := func(e string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	upload := gotaskflow.NewTask("upload", func() {}).
+		OnSuccess(func() { panic("callback broken") }).
+		OnSuccess(func() { record("uploaded") }).
+		OnFail(func(any) { record("upload failed") })
+	next := gotaskflow.NewTask("next", func() { record("next") })
+	upload.Precede(next)
+	tf.Push(upload, next)
+	h := executor.RunAsync(tf)
+	<-h.Done()
+	if h.Err() != nil || fmt.Sprint(events) != "[uploaded next]" {
+		t.Errorf("expected callbacks before successors and contained panic, got %v and %v", events, h.Err())
+	}
+	if !strings.Contains(buf.String(), "callback upload") || !strings.Contains(buf.String(), "callback broken") {
+		t.Errorf("expected panic of callback logged, got %v", buf.String())
+	}
+
+	events = events[:0]
+	// failing one cancels the other if run together
+	for _, task := range []*gotaskflow.Task{
+		gotaskflow.NewTask("boom", func() { panic("exploded") }).
+			OnSuccess(func() { record("boom succeeded") }).
+			OnFail(func(r any) { record(fmt.Sprintf("boom failed: %v", r)) }),
+		gotaskflow.NewErrorTask("err", func() error { return errors.New("bad input") }).
+			OnFail(func(r any) { record(fmt.Sprintf("err failed: %v", r)) }),
+	} {
+		tf = gotaskflow.NewTaskFlow("G")
+		tf.Push(task)
+		executor.Run(tf).Wait()
+	}
+	if fmt.Sprint(events) != "[boom failed: exploded err failed: bad input]" {
+		t.Errorf("unexpected callbacks of failures %v", events)
+	}
+}
+
+func TestExecutorDisallowedPackages(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithDisallowedPackages("runtime"))
+	tf := gotaskflow.NewTaskFlow("G")
+	var ran, after atomic.Bool
+	ok := gotaskflow.NewTask("ok", func() { ran.Store(true) })
+	gc := gotaskflow.NewTask("gc", runtime.GC)
+	succ := gotaskflow.NewTask("succ", func() { after.Store(true) })
+	gc.Precede(succ)
+	tf.Push(ok, gc, succ)
+
+	h := executor.RunAsync(tf)
+	<-h.Done()
+	if err := h.Err(); !errors.Is(err, gotaskflow.ErrDisallowedPackage) || !strings.Contains(err.Error(), "gc is defined in package runtime") {
+		t.Errorf("expected gc rejected, got %v", err)
+	}
+	if !ran.Load() || after.Load() {
+		t.Errorf("expected approved task run and successor of rejected one not, got %v %v", ran.Load(), after.Load())
+	}
+
+	// subpackages are disallowed too, and conditions are checked
+	executor = gotaskflow.NewExecutor(4, gotaskflow.WithDisallowedPackages("github.com/noneback"))
+	tf = gotaskflow.NewTaskFlow("G")
+	tf.Push(gotaskflow.NewCondition("cond", func() uint { return 0 }))
+	if err := gotaskflow.WaitAll(executor.RunAsync(tf)); !errors.Is(err, gotaskflow.ErrDisallowedPackage) {
+		t.Errorf("expected condition of test package rejected, got %v", err)
+	}
+
+	// funcs wrapped by constructors are checked, rather than their wrappers in gotaskflow
+	wrapped := map[string]*gotaskflow.Task{
+		"controlled":            gotaskflow.NewControlledTask("controlled", func(tc gotaskflow.TaskControl) {}),
+		"runtime condition":     gotaskflow.NewRuntimeCondition("runtime condition", func(rt *gotaskflow.Runtime) uint { return 0 }),
+		"canceling condition":   gotaskflow.NewCancelingCondition("canceling condition", func() gotaskflow.Choice { return gotaskflow.Choice{} }),
+		"runtime str condition": gotaskflow.NewRuntimeStringCondition("runtime str condition", func(rt *gotaskflow.Runtime) string { return "" }),
+		"typed":                 gotaskflow.NewTypedTask("typed", func(in int) int { return in }).Task,
+		"plain, for comparison": gotaskflow.NewTask("plain, for comparison", func() {}),
+	}
+	executor = gotaskflow.NewExecutor(4, gotaskflow.WithDisallowedPackages("github.com/noneback/go-taskflow_test"))
+	for name, task := range wrapped {
+		tf = gotaskflow.NewTaskFlow(name)
+		tf.Push(task)
+		if err := gotaskflow.WaitAll(executor.RunAsync(tf)); !errors.Is(err, gotaskflow.ErrDisallowedPackage) || !strings.Contains(err.Error(), "go-taskflow_test") {
+			t.Errorf("expected %v of test package rejected, got %v", name, err)
+		}
+	}
+
+	if _, err := gotaskflow.NewExecutorWithOptions(1, gotaskflow.WithDisallowedPackages("")); err == nil {
+		t.Error("expected empty package rejected")
+	}
+}
+
+func TestExecutorRunAll(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	var done atomic.Int32
+	flow := func(name string, f func()) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow(name)
+		tf.Push(gotaskflow.NewTask(name, func() {
+			time.Sleep(10 * time.Millisecond)
+			f()
+			done.Add(1)
+		}))
+		return tf
+	}
+	tfs := []*gotaskflow.TaskFlow{
+		flow("A", func() {}),
+		flow("B", func() { panic("B failed") }),
+		flow("C", func() {}),
+		gotaskflow.NewTaskFlow("empty"),
+	}
+
+	executor.RunAll(tfs...)
+	if done.Load() != 0 {
+		t.Error("expected RunAll to return before taskflows are done")
+	}
+	executor.Wait()
+	if done.Load() != 2 {
+		t.Errorf("expected all taskflows done after Wait, got %v", done.Load())
+	}
+	errs := executor.Errors()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "taskflow B -> ") || !strings.Contains(errs[0].Error(), "B failed") {
+		t.Errorf("expected failure of B tagged with its name, got %v", errs)
+	}
+
+	executor.RunAll(tfs[0]).Wait()
+	if errs := executor.Errors(); len(errs) != 0 {
+		t.Errorf("expected no errors of latest batch, got %v", errs)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic of taskflow given twice")
+		}
+	}()
+	executor.RunAll(tfs[0], tfs[0])
+}
+
+func TestExecutorWorkQueue(t *testing.T) {
+	q := make(chanQueue, 1<<10)
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithWorkQueue(q), gotaskflow.WithMaxQueueDepth(1<<10))
+	tf := gotaskflow.NewTaskFlow("G")
+	var cnt atomic.Int32
+	root := gotaskflow.NewTask("root", func() {})
+	tf.Push(root)
+	for i := 0; i < 100; i++ {
+		task := gotaskflow.NewTask(fmt.Sprint(i), func() { cnt.Add(1) })
+		root.Precede(task)
+		tf.Push(task)
+	}
+	executor.Run(tf).Wait()
+	if cnt.Load() != 100 || q.Len() != 0 {
+		t.Errorf("expected all tasks run through custom queue, got %v, %v left", cnt.Load(), q.Len())
+	}
+	if err := executor.Drain(); err != nil {
+		t.Errorf("unexpected drain error %v", err)
+	}
+
+	for _, opts := range [][]gotaskflow.Option{
+		{gotaskflow.WithWorkQueue(nil)},
+		{gotaskflow.WithWorkQueue(q), gotaskflow.WithPriorityAging(0.1)},
+		{gotaskflow.WithFairScheduling(), gotaskflow.WithWorkQueue(q)},
+	} {
+		if _, err := gotaskflow.NewExecutorWithOptions(4, opts...); err == nil {
+			t.Errorf("expected invalid options %v", opts)
+		}
+	}
+}
+
+func TestExecutorFakeClock(t *testing.T) {
+	clk := taskflowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	executor := gotaskflow.NewExecutor(1, gotaskflow.WithClock(clk))
+	// tasks stand for work by advancing clock: A 10ms -> {B 30ms, C 20ms} -> D
+	work := func(name string, d time.Duration) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() { clk.Advance(d) })
+	}
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B, C, D := work("A", 10*time.Millisecond), work("B", 30*time.Millisecond), work("C", 20*time.Millisecond), work("D", 0)
+	A.Precede(B, C)
+	D.Succeed(B, C)
+	tf.Push(A, B, C, D)
+	executor.Run(tf).Wait()
+
+	costs := make(map[string]time.Duration)
+	for _, s := range executor.Spans() {
+		costs[s.Name] = s.Cost
+	}
+	expected := map[string]time.Duration{"A": 10 * time.Millisecond, "B": 30 * time.Millisecond, "C": 20 * time.Millisecond, "D": 0}
+	if fmt.Sprint(costs) != fmt.Sprint(expected) {
+		t.Errorf("expected costs %v, got %v", expected, costs)
+	}
+
+	var buf bytes.Buffer
+	if err := executor.ProfileTimeline(&buf, gotaskflow.TimelineTextByLayer); err != nil {
+		t.Fatal(err)
+	}
+	golden, err := os.ReadFile(filepath.Join("testdata", "timeline_by_layer.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(golden) {
+		t.Errorf("timeline differs from golden file:\n%s", buf.String())
+	}
+
+	// timeouts and soft deadlines fire by clock, rather than by real time
+	tf = gotaskflow.NewTaskFlow("slow")
+	slow := work("slow", 2*time.Second).WithTimeout(time.Second)
+	tf.Push(slow)
+	h := executor.RunAsync(tf)
+	<-h.Done()
+	if !errors.Is(h.Err(), gotaskflow.ErrTimeout) {
+		t.Errorf("expected timeout by fake clock, got %v", h.Err())
+	}
+}
+
+func TestExecutorRunPolicy(t *testing.T) {
+	// B is run from another goroutine while A is running
+	run := func(policy gotaskflow.RunPolicy) (overlapped bool, first, second error) {
+		executor := gotaskflow.NewExecutor(4, gotaskflow.WithRunPolicy(policy))
+		var running, maxRunning atomic.Int32
+		started, submitted := make(chan struct{}), make(chan struct{})
+		flow := func(name string, f func()) *gotaskflow.TaskFlow {
+			tf := gotaskflow.NewTaskFlow(name)
+			tf.Push(gotaskflow.NewTask(name, func() {
+				cur := running.Add(1)
+				for old := maxRunning.Load(); cur > old && !maxRunning.CompareAndSwap(old, cur); old = maxRunning.Load() {
+				}
+				f()
+				running.Add(-1)
+			}))
+			return tf
+		}
+		a := flow("A", func() {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+		})
+		b := flow("B", func() {})
+
+		ha := executor.RunAsync(a)
+		<-started
+		var hb *gotaskflow.RunHandle
+		go func() {
+			defer close(submitted)
+			hb = executor.RunAsync(b)
+		}()
+		<-submitted
+		<-ha.Done()
+		<-hb.Done()
+		return maxRunning.Load() > 1, ha.Err(), hb.Err()
+	}
+
+	if overlapped, a, b := run(gotaskflow.RunAllow); !overlapped || a != nil || b != nil {
+		t.Errorf("expected taskflows overlapped under RunAllow, got overlapped %v, errors %v, %v", overlapped, a, b)
+	}
+	if overlapped, a, b := run(gotaskflow.RunSerialize); overlapped || a != nil || b != nil {
+		t.Errorf("expected taskflows one after another under RunSerialize, got overlapped %v, errors %v, %v", overlapped, a, b)
+	}
+	if overlapped, a, b := run(gotaskflow.RunReject); overlapped || a != nil || !errors.Is(b, gotaskflow.ErrExecutorBusy) {
+		t.Errorf("expected second taskflow rejected under RunReject, got overlapped %v, errors %v, %v", overlapped, a, b)
+	}
+
+	if _, err := gotaskflow.NewExecutorWithOptions(4, gotaskflow.WithRunPolicy(gotaskflow.RunPolicy(7))); err == nil {
+		t.Error("expected invalid run policy rejected")
+	}
+}
+
+func TestExecutorUnfinished(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	names := func(tasks []*gotaskflow.Task) []string {
+		res := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			res = append(res, task.Name())
+		}
+		return res
+	}
+
+	tf := gotaskflow.NewTaskFlow("G")
+	start := gotaskflow.NewTask("start", func() {})
+	cond := gotaskflow.NewCondition("cond", func() uint { return 0 })
+	taken := gotaskflow.NewSubflow("taken", func(sf *gotaskflow.Subflow) {
+		inner := gotaskflow.NewCondition("inner", func() uint { return 1 })
+		left, right := gotaskflow.NewTask("left", func() {}), gotaskflow.NewTask("right", func() {})
+		inner.Precede(left, right)
+		sf.Push(inner, left, right)
+	})
+	pruned := gotaskflow.NewTask("pruned", func() {})
+	after := gotaskflow.NewTask("after", func() {})
+	start.Precede(cond)
+	cond.Precede(taken, pruned)
+	pruned.Precede(after)
+	tf.Push(start, cond, taken, pruned, after)
+
+	failed := gotaskflow.NewTaskFlow("F")
+	boom := gotaskflow.NewTask("boom", func() { panic("boom") })
+	next := gotaskflow.NewTask("next", func() {})
+	boom.Precede(next)
+	failed.Push(boom, next)
+
+	executor.Run(tf)
+	executor.RunAsync(failed)
+	if got := executor.Unfinished(); len(got) != 0 {
+		t.Errorf("expected nothing reported before Wait, got %v", names(got))
+	}
+	executor.Wait()
+	got := names(executor.Unfinished())
+	slices.Sort(got)
+	if want := []string{"after", "boom", "left", "next", "pruned"}; !slices.Equal(got, want) {
+		t.Errorf("expected unfinished %v, got %v", want, got)
+	}
+
+	executor.Run(tf).Wait()
+	if got := names(executor.Unfinished()); !slices.Equal(got, []string{"left", "pruned", "after"}) {
+		t.Errorf("expected only runs until latest Wait reported, got %v", got)
+	}
+}
+
+func TestExecutorErrorPolicy(t *testing.T) {
+	errFirst, errLate := errors.New("first"), errors.New("late")
+	// first fails in a subflow, while slow waits for the run to be canceled and late fails a bit after first
+	run := func(policy gotaskflow.ErrorPolicy) (err error, waited time.Duration, cause error, after bool) {
+		executor := gotaskflow.NewExecutor(4, gotaskflow.WithErrorPolicy(policy))
+		tf := gotaskflow.NewTaskFlow("G")
+		var ran atomic.Bool
+		sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+			first := gotaskflow.NewErrorTask("first", func() error {
+				time.Sleep(10 * time.Millisecond)
+				return errFirst
+			})
+			next := gotaskflow.NewTask("next", func() { ran.Store(true) })
+			first.Precede(next)
+			sf.Push(first, next)
+		})
+		slow := gotaskflow.NewControlledTask("slow", func(tc gotaskflow.TaskControl) {
+			begin := time.Now()
+			select {
+			case <-tc.Context().Done():
+				cause = context.Cause(tc.Context())
+			case <-time.After(time.Second):
+			}
+			waited = time.Since(begin)
+		})
+		late := gotaskflow.NewErrorTask("late", func() error {
+			time.Sleep(50 * time.Millisecond)
+			return errLate
+		})
+		tf.Push(sub, slow, late)
+		executor.Run(tf)
+		return executor.WaitErr(), waited, cause, ran.Load()
+	}
+
+	err, waited, cause, after := run(gotaskflow.ErrorsFirst)
+	if !errors.Is(err, errFirst) || errors.Is(err, errLate) || !strings.HasPrefix(err.Error(), "taskflow G -> ") {
+		t.Errorf("expected only first failure reported, got %v", err)
+	}
+	if waited >= time.Second || !errors.Is(cause, errFirst) || after {
+		t.Errorf("expected run canceled by first failure promptly, got waited %v, cause %v, successor ran %v", waited, cause, after)
+	}
+
+	err, waited, cause, _ = run(gotaskflow.ErrorsJoined)
+	if !errors.Is(err, errFirst) || !errors.Is(err, errLate) || strings.Index(err.Error(), "first") > strings.Index(err.Error(), "late") {
+		t.Errorf("expected first failure followed by later one, got %v", err)
+	}
+	if waited >= time.Second || !errors.Is(cause, errFirst) {
+		t.Errorf("expected run canceled by first failure, got waited %v, cause %v", waited, cause)
+	}
+
+	// failed subflow leaves its parent running, until late fails in it
+	err, _, cause, after = run(gotaskflow.ErrorsPerGraph)
+	if !errors.Is(err, errFirst) || errors.Is(err, errLate) || !errors.Is(cause, errLate) || after {
+		t.Errorf("expected only subflow canceled by first, got %v, cause %v, successor ran %v", err, cause, after)
+	}
+
+	if _, err := gotaskflow.NewExecutorWithOptions(4, gotaskflow.WithErrorPolicy(gotaskflow.ErrorPolicy(7))); err == nil {
+		t.Error("expected invalid error policy rejected")
+	}
+}
+
+func TestExecutorRunOnce(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	errRefresh := errors.New("refresh failed")
+	var runs atomic.Int32
+	started := make(chan struct{}, 1)
+	tf := gotaskflow.NewTaskFlow("refresh")
+	tf.Push(gotaskflow.NewErrorTask("load", func() error {
+		runs.Add(1)
+		started <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+		return errRefresh
+	}))
+
+	errs := make([]error, 8)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs[0] = executor.RunOnce(tf)
+	}()
+	<-started
+	for i := 1; i < len(errs); i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = executor.RunOnce(tf)
+		}()
+	}
+	wg.Wait()
+	if runs.Load() != 1 {
+		t.Errorf("expected callers arriving during the run to share it, got %v runs", runs.Load())
+	}
+	for i, err := range errs {
+		if !errors.Is(err, errRefresh) {
+			t.Errorf("expected caller %v to get failure of the shared run, got %v", i, err)
+		}
+	}
+
+	if err := executor.RunOnce(tf); !errors.Is(err, errRefresh) || runs.Load() != 2 {
+		t.Errorf("expected a later call to run again, got %v after %v runs", err, runs.Load())
+	}
+}
+
+func TestExecutorSingleflightKey(t *testing.T) {
+	executor := gotaskflow.NewExecutor(4)
+	var refreshes, done atomic.Int32
+	started := make(chan struct{})
+	flow := func(name string) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow(name)
+		refresh := gotaskflow.NewTask("refresh token", func() {
+			if refreshes.Add(1) == 1 {
+				close(started)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}).WithSingleflightKey("token")
+		use := gotaskflow.NewTask("use token", func() { done.Add(1) })
+		refresh.Precede(use)
+		tf.Push(refresh, use)
+		return tf
+	}
+
+	first := executor.RunAsync(flow("A"))
+	<-started
+	second := executor.RunAsync(flow("B"))
+	if err := gotaskflow.WaitAll(first, second); err != nil {
+		t.Fatalf("unexpected failure %v", err)
+	}
+	if refreshes.Load() != 1 || done.Load() != 2 {
+		t.Errorf("expected shared handle run once and both flows done, got %v runs, %v done", refreshes.Load(), done.Load())
+	}
+
+	// the key is free once its run is done, and failure is shared
+	errRefresh := errors.New("refresh failed")
+	release := make(chan struct{})
+	failing := func(name string) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow(name)
+		tf.Push(gotaskflow.NewErrorTask("refresh token", func() error {
+			refreshes.Add(1)
+			<-release
+			return errRefresh
+		}).WithSingleflightKey("token"))
+		return tf
+	}
+	first = executor.RunAsync(failing("A"))
+	for refreshes.Load() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	second = executor.RunAsync(failing("B"))
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	<-first.Done()
+	<-second.Done()
+	if refreshes.Load() != 2 || !errors.Is(first.Err(), errRefresh) || !errors.Is(second.Err(), errRefresh) {
+		t.Errorf("expected failure of shared run in both flows, got %v runs, errors %v, %v", refreshes.Load(), first.Err(), second.Err())
+	}
+}
+
+func TestExecutorPauseResume(t *testing.T) {
+	executor := gotaskflow.NewExecutor(2)
+	var ran atomic.Int32
+	paused := make(chan struct{})
+	tf := gotaskflow.NewTaskFlow("batch")
+	prev := gotaskflow.NewTask("step_0", func() {
+		executor.Pause()
+		close(paused)
+		time.Sleep(10 * time.Millisecond) // running task finishes while paused
+		ran.Add(1)
+	})
+	tf.Push(prev)
+	for i := 1; i < 10; i++ {
+		step := gotaskflow.NewTask(fmt.Sprintf("step_%d", i), func() { ran.Add(1) })
+		prev.Precede(step)
+		tf.Push(step)
+		prev = step
+	}
+	for i := 0; i < 4; i++ {
+		side := gotaskflow.NewTask(fmt.Sprintf("side_%d", i), func() { ran.Add(1) })
+		tf.Push(side)
+	}
+
+	h := executor.RunAsync(tf)
+	<-paused
+	time.Sleep(50 * time.Millisecond)
+	held := ran.Load()
+	if held >= 14 {
+		t.Errorf("expected queued tasks held while paused, %v tasks ran", held)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ran.Load() != held {
+		t.Errorf("expected no tasks dispatched while paused, got %v more", ran.Load()-held)
+	}
+	select {
+	case <-h.Done():
+		t.Fatal("expected paused run not to finish")
+	default:
+	}
+
+	executor.Resume()
+	if err := gotaskflow.WaitAll(h); err != nil || ran.Load() != 14 {
+		t.Errorf("expected run to continue once resumed, got %v after %v tasks", err, ran.Load())
+	}
+	executor.Resume() // no-op when not paused
+}
+
+func TestExecutorDryRun(t *testing.T) {
+	var buf bytes.Buffer
+	executor := gotaskflow.NewExecutor(4, gotaskflow.WithLogger(&buf))
+	var ran atomic.Int32
+	task := func(name string) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() { ran.Add(1) })
+	}
+	tf := gotaskflow.NewTaskFlow("G")
+	init, body, done := task("init"), task("body"), task("done")
+	back := gotaskflow.NewCondition("back", func() uint {
+		ran.Add(1)
+		return 0
+	})
+	i := 0
+	cond := gotaskflow.NewCondition("cond", func() uint {
+		ran.Add(1)
+		if i++; i < 3 {
+			return 0
+		}
+		return 1
+	})
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		ran.Add(1)
+		sf.Push(task("inner"))
+	})
+	init.Precede(cond)
+	cond.Precede(body, done)
+	body.Precede(back)
+	back.Precede(cond)
+	done.Precede(sub)
+	tf.Push(init, cond, body, back, done, sub)
+
+	if err := executor.DryRun(tf); err != nil {
+		t.Errorf("unexpected failure %v", err)
+	}
+	if ran.Load() != 0 {
+		t.Errorf("expected no handle run, %v ran", ran.Load())
+	}
+	order := tf.TopologicalOrder()
+	if !slices.Equal(order, []string{"init", "cond", "body", "done", "back", "sub"}) {
+		t.Errorf("unexpected order %v", order)
+	}
+	if got := buf.String(); !strings.Contains(got, "[dry run] taskflow G layer 2: body, done\n") ||
+		strings.Count(got, "[dry run]") != 4 {
+		t.Errorf("expected layers logged in topological order, got %q", got)
+	}
+
+	// a real run is not affected
+	if err := gotaskflow.WaitAll(executor.RunAsync(tf)); err != nil || ran.Load() != 11 {
+		t.Errorf("expected real run after dry run, got %v after %v handles", err, ran.Load())
+	}
+
+	broken := gotaskflow.NewTaskFlow("broken")
+	A, B, C := task("A"), task("B"), task("C")
+	A.Precede(B)
+	B.Precede(A)
+	broken.Push(A, B, C, gotaskflow.NewCondition("dangling", func() uint { return 0 }))
+	err := executor.DryRun(broken)
+	if err == nil || !strings.Contains(err.Error(), "cycle without condition") || !strings.Contains(err.Error(), "[A B] in broken are never run") ||
+		!strings.Contains(err.Error(), "dangling") {
+		t.Errorf("expected cycle, unreachable tasks and dangling condition reported, got %v", err)
+	}
+}
+
+func TestExecutorProfileSampleRate(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	for i := 0; i < 1000; i++ {
+		tf.Push(gotaskflow.NewTask(fmt.Sprint(i), func() {}))
+	}
+	spans := func(rate float64) int {
+		executor := gotaskflow.NewExecutor(4, gotaskflow.WithProfileSampleRate(rate))
+		executor.Run(tf).Wait()
+		if got := executor.Stats().ProfileSampleRate; got != rate {
+			t.Errorf("expected sample rate %v in stats, got %v", rate, got)
+		}
+		return len(executor.Spans())
+	}
+
+	if n := spans(1); n != 1000 {
+		t.Errorf("expected every span recorded, got %v", n)
+	}
+	if n := spans(0.1); n < 30 || n > 200 {
+		t.Errorf("expected about 100 spans recorded, got %v", n)
+	}
+	if n := spans(0); n != 0 {
+		t.Errorf("expected no span recorded, got %v", n)
+	}
+	if stats := gotaskflow.NewExecutor(1).Stats(); stats.ProfileSampleRate != 1 {
+		t.Errorf("expected every span recorded by default, got rate %v", stats.ProfileSampleRate)
+	}
+
+	for _, rate := range []float64{-0.1, 1.5, math.NaN()} {
+		if _, err := gotaskflow.NewExecutorWithOptions(1, gotaskflow.WithProfileSampleRate(rate)); err == nil {
+			t.Errorf("expected rate %v rejected", rate)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package gotaskflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrorPolicy decides how far the first failure of a run reaches, and which failures are reported, see WithErrorPolicy
+type ErrorPolicy int
+
+const (
+	ErrorsPerGraph ErrorPolicy = iota // a failure cancels the graph it happens in, a failed subflow leaves its parent running
+	ErrorsFirst                       // the first failure cancels the whole run like errgroup, later ones are dropped
+	ErrorsJoined                      // like ErrorsFirst, while later failures of tasks still running are joined after the first
+)
+
+// runContext is the context of a graph in current run, canceled with the failure as cause
+type runContext struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+}
+
+// startContext gives g a new context for current run, derived from the one of graph it is nested in
+func (g *eGraph) startContext() {
+	parent := context.Background()
+	if g.parent != nil {
+		if rc := g.parent.runCtx.Load(); rc != nil {
+			parent = rc.ctx
+		}
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	g.runCtx.Store(&runContext{ctx: ctx, cancel: cancel})
+}
+
+// stopContext cancels context of g with cause, the first cause wins
+func (g *eGraph) stopContext(cause error) {
+	if rc := g.runCtx.Load(); rc != nil {
+		rc.cancel(cause)
+	}
+}
+
+// context returns context of g in current run, or of latest run once it is done
+func (g *eGraph) context() context.Context {
+	if rc := g.runCtx.Load(); rc != nil {
+		return rc.ctx
+	}
+	return context.Background()
+}
+
+// addLater keeps err failing g after its first failure, if run of g joins failures
+func (g *eGraph) addLater(err error) {
+	if g.root().errPolicy != ErrorsJoined {
+		return
+	}
+	g.laterMu.Lock()
+	g.later = append(g.later, err)
+	g.laterMu.Unlock()
+}
+
+// Context returns context of the run, canceled once the taskflow running task, or a subflow holding it, is canceled,
+// by Cancel, a failure or a deadline. context.Cause reports the failure, so handles can pass it to calls they make.
+// It is canceled as well when the run is done.
+func (rt *Runtime) Context() context.Context {
+	return rt.node.g.context()
+}
+
+// WithErrorPolicy decides how far the first failure of a run reaches, ErrorsPerGraph by default.
+// ErrorsFirst and ErrorsJoined mirror errgroup.WithContext: a failure in any task, subflows included, cancels the run
+// and its context, no more tasks are scheduled, and running handles notice it by Runtime.Context or Runtime.Canceled.
+func WithErrorPolicy(p ErrorPolicy) Option {
+	return func(e *innerExecutorImpl) {
+		if p < ErrorsPerGraph || p > ErrorsJoined {
+			e.invalid("invalid error policy %v", p)
+			return
+		}
+		e.errPolicy = p
+	}
+}
+
+// WaitErr blocks like Wait, then returns failures of taskflows whose runs ended until then, joined and each tagged
+// with taskflow name. A run reports its first failure, followed by later ones under ErrorsJoined.
+func (e *innerExecutorImpl) WaitErr() error {
+	e.Wait()
+	e.mu.Lock()
+	graphs := slices.Clone(e.settled)
+	e.mu.Unlock()
+
+	errs := make([]error, 0)
+	seen := make(map[*eGraph]struct{}, len(graphs))
+	for _, g := range graphs {
+		if _, ok := seen[g]; ok {
+			continue
+		}
+		seen[g] = struct{}{}
+		if err := g.err(); err != nil {
+			errs = append(errs, fmt.Errorf("taskflow %v -> %w", g.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,150 @@
+package gotaskflow
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/noneback/go-taskflow/utils"
+)
+
+// fairQueue interleaves graphs sharing the executor, so that a subflow with many ready tasks does not starve
+// its parent and sibling subflows. Each graph has its own queue, and the graph with fewest in-flight tasks is
+// taken from first, graphs in equal standing are taken in turn. Nodes of a graph are taken in order.
+// Since pool queues whatever is submitted to it, nodes are only taken while pool has idle workers, see saturated.
+type fairQueue struct {
+	queues  map[*eGraph][]*innerNode
+	graphs  []*eGraph // graphs with queued nodes, in turn
+	next    int       // index of graph whose turn is next
+	size    int
+	running map[*eGraph]int // in-flight tasks by graph, subflow tasks are not counted, whose tasks count for their own graph
+	busy    int             // jobs submitted to pool and not finished
+	cap     int             // workers of pool
+	mu      *sync.Mutex
+	empty   *sync.Cond
+}
+
+func newFairQueue(cap uint) *fairQueue {
+	mu := &sync.Mutex{}
+	return &fairQueue{
+		queues:  make(map[*eGraph][]*innerNode),
+		running: make(map[*eGraph]int),
+		cap:     int(cap),
+		mu:      mu,
+		empty:   sync.NewCond(mu),
+	}
+}
+
+func (q *fairQueue) Put(node *innerNode) {
+	q.PutWithLimit(node, 0)
+}
+
+func (q *fairQueue) PutWithLimit(node *innerNode, limit int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if limit > 0 && q.size >= limit {
+		return utils.ErrQueueFull
+	}
+	if _, ok := q.queues[node.g]; !ok {
+		q.graphs = append(q.graphs, node.g)
+	}
+	q.queues[node.g] = append(q.queues[node.g], node)
+	q.size++
+	return nil
+}
+
+// PeakAndTake takes the first node of the graph with fewest in-flight tasks, the one whose turn comes first among equals.
+// It returns nil if queue is empty, as loops of all graphs with queued nodes are woken at once, see finish.
+func (q *fairQueue) PeakAndTake() *innerNode {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.size == 0 {
+		return nil
+	}
+
+	best := -1
+	for i := range q.graphs {
+		idx := (q.next + i) % len(q.graphs)
+		if best < 0 || q.running[q.graphs[idx]] < q.running[q.graphs[best]] {
+			best = idx
+		}
+	}
+	g := q.graphs[best]
+	node := q.queues[g][0]
+	q.queues[g][0] = nil
+	q.queues[g] = q.queues[g][1:]
+	q.size--
+
+	q.next = best + 1
+	if len(q.queues[g]) == 0 {
+		delete(q.queues, g)
+		q.graphs = slices.Delete(q.graphs, best, best+1)
+		q.next = best
+	}
+	if len(q.graphs) > 0 {
+		q.next %= len(q.graphs)
+	} else {
+		q.next = 0
+	}
+	if q.size == 0 {
+		q.empty.Broadcast()
+	}
+	return node
+}
+
+func (q *fairQueue) Len() int32 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int32(q.size)
+}
+
+// WaitEmpty blocks until queue is empty
+func (q *fairQueue) WaitEmpty() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.size != 0 {
+		q.empty.Wait()
+	}
+}
+
+// saturated reports whether every worker of pool is busy, then nodes are left queued to be taken in turn later
+func (q *fairQueue) saturated() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.busy >= q.cap
+}
+
+// track wraps submit to pool, counting jobs of node as in flight until they finish
+func (q *fairQueue) track(node *innerNode, submit func(job func(worker int))) func(job func(worker int)) {
+	return func(job func(worker int)) {
+		q.mu.Lock()
+		q.busy++
+		if node.Typ != nodeSubflow {
+			q.running[node.g]++
+		}
+		q.mu.Unlock()
+
+		submit(func(worker int) {
+			defer q.finish(node)
+			job(worker)
+		})
+	}
+}
+
+// finish stops counting job of node, and wakes graphs with queued nodes, as any of their loops may take them now
+func (q *fairQueue) finish(node *innerNode) {
+	q.mu.Lock()
+	q.busy--
+	if node.Typ != nodeSubflow {
+		if q.running[node.g]--; q.running[node.g] == 0 {
+			delete(q.running, node.g)
+		}
+	}
+	graphs := slices.Clone(q.graphs)
+	q.mu.Unlock()
+
+	for _, g := range graphs {
+		g.scheCond.L.Lock()
+		g.scheCond.Broadcast()
+		g.scheCond.L.Unlock()
+	}
+}
@@ -0,0 +1,93 @@
+package gotaskflow
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFairQueue(t *testing.T) {
+	q := newFairQueue(2)
+	put := func(g *eGraph, names ...string) {
+		for _, name := range names {
+			n := newNode(name)
+			g.push(n)
+			q.Put(n)
+		}
+	}
+	jobs := make([]func(), 0)
+	take := func() *innerNode {
+		n := q.PeakAndTake()
+		// keep it in flight until its job is run
+		q.track(n, func(job func(worker int)) {
+			jobs = append(jobs, func() { job(0) })
+		})(func(worker int) {})
+		return n
+	}
+	heavy, light := newGraph("heavy"), newGraph("light")
+	put(heavy, "h1", "h2", "h3")
+	put(light, "l1", "l2")
+
+	// graph with fewer in-flight tasks goes first
+	got := make([]string, 0)
+	for q.Len() > 0 {
+		got = append(got, take().name)
+	}
+	expected := []string{"h1", "l1", "h2", "l2", "h3"}
+	if !slices.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if !q.saturated() {
+		t.Error("expected saturated pool")
+	}
+	if q.PeakAndTake() != nil {
+		t.Error("expected nil from empty queue")
+	}
+
+	for _, job := range jobs[:4] { // all but h3
+		job()
+	}
+	if q.saturated() {
+		t.Error("expected idle workers")
+	}
+	put(heavy, "h4")
+	put(light, "l3")
+	if n := q.PeakAndTake(); n.name != "l3" {
+		t.Errorf("expected graph with fewer running tasks first, got %v", n.name)
+	}
+}
+
+func TestExecutorFairScheduling(t *testing.T) {
+	if _, err := NewExecutorWithOptions(2, WithFairScheduling(), WithPriorityAging(0.5)); err == nil {
+		t.Error("expected conflict with priority aging")
+	}
+
+	executor := NewExecutor(2, WithFairScheduling())
+	tf := NewTaskFlow("G")
+	var heavy atomic.Int32
+	var seen atomic.Int32 // tasks of subflow finished when light branch runs
+	sf := NewSubflow("heavy", func(sf *Subflow) {
+		for i := 0; i < 50; i++ {
+			sf.Push(NewTask(fmt.Sprint(i), func() {
+				time.Sleep(time.Millisecond)
+				heavy.Add(1)
+			}))
+		}
+	})
+	start := NewTask("start", func() {})
+	wait := NewTask("wait", func() { time.Sleep(5 * time.Millisecond) })
+	light := NewTask("light", func() { seen.Store(heavy.Load()) })
+	start.Precede(sf, wait)
+	wait.Precede(light)
+	tf.Push(start, sf, wait, light)
+
+	executor.Run(tf).Wait()
+	if heavy.Load() != 50 {
+		t.Fatalf("expected 50 subflow tasks, got %v", heavy.Load())
+	}
+	if seen.Load() == 50 {
+		t.Error("light branch is starved by subflow")
+	}
+}
@@ -1,6 +1,10 @@
 package gotaskflow
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
 
 var builder = flowBuilder{}
 
@@ -8,19 +12,117 @@ type flowBuilder struct{}
 
 // Condition Wrapper
 type Condition struct {
-	handle func() uint
-	mapper map[uint]*innerNode
+	handle        func() uint
+	mapper        map[uint]*innerNode
+	keyHandle     func() string // set for string condition, which picks successor by key instead of index
+	stringMapper  map[string]*innerNode
+	choices       []*innerNode // successors chosen in current run, in order
+	looping       bool         // condition can reach itself, so its untaken branches may be taken later
+	canceling     []uint       // branches to cancel returned along with latest choice, see NewCancelingCondition
+	probabilities []float64    // weights of branches in simulated runs, see Task.WithProbabilities
+	mu            *sync.Mutex
+}
+
+func (c *Condition) record(n *innerNode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.choices = append(c.choices, n)
+}
+
+func (c *Condition) resetChoices() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.choices = c.choices[:0]
+}
+
+// history returns labels of chosen successors in current run, in order
+func (c *Condition) history() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]string, 0, len(c.choices))
+	for _, n := range c.choices {
+		res = append(res, c.choiceOf(n))
+	}
+	return res
+}
+
+// taken reports whether successor n is chosen in current run
+func (c *Condition) taken(n *innerNode) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Contains(c.choices, n)
+}
+
+// choiceOf returns the index or key selecting successor n
+func (c *Condition) choiceOf(n *innerNode) string {
+	if c.keyHandle != nil {
+		for key, v := range c.stringMapper {
+			if v == n {
+				return key
+			}
+		}
+		return ""
+	}
+	for idx, v := range c.mapper {
+		if v == n {
+			return fmt.Sprintf("%d", idx)
+		}
+	}
+	return ""
+}
+
+// branch reports whether n is already a successor selected by index
+func (c *Condition) branch(n *innerNode) bool {
+	for _, v := range c.mapper {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// label returns the choice leading to successor n, used by visualizer
+func (c *Condition) label(idx int, n *innerNode) string {
+	if c.keyHandle == nil {
+		return fmt.Sprintf("%d", idx)
+	}
+	return c.choiceOf(n)
 }
 
 // Static Wrapper
 type Static struct {
-	handle func()
+	handle any         // func() or func() error
+	gate   func() bool // handle runs only if it holds once task is ready, see NewConditionalTask
 }
 
 // Subflow Wrapper
 type Subflow struct {
-	handle func(sf *Subflow)
-	g      *eGraph
+	handle  func(sf *Subflow)
+	g       *eGraph
+	pending any // set by SetResult, published once subflow graph finished
+	result  any
+	mu      *sync.Mutex
+	async   bool // handle runs off pool, see Task.WithAsyncBuild
+}
+
+// SetResult sets the value returned by Result of subflow task, once subflow finished.
+// It can be called in subflow handle or in tasks of subflow, the last call wins.
+func (sf *Subflow) SetResult(v any) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.pending = v
+}
+
+func (sf *Subflow) publish() {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.result = sf.pending
+}
+
+func (sf *Subflow) getResult() any {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.result
 }
 
 // only for visualizer
@@ -39,15 +141,60 @@ func (sf *Subflow) instancelize() (err error) {
 	return nil
 }
 
-// Push pushs all tasks into subflow
+// WithMaxConcurrency caps how many tasks of subflow run at once, on top of executor concurrency.
+// A nested subflow task holds one slot while its own tasks run, which are capped by its own limit.
+func (sf *Subflow) WithMaxConcurrency(n uint) *Subflow {
+	if n == 0 {
+		panic("subflow concurrency cannot be zero")
+	}
+	sf.g.limiter = newLimiter(n)
+	return sf
+}
+
+// Push pushs all tasks into subflow, it panics if a task name is already taken, see TryPush
 func (sf *Subflow) Push(tasks ...*Task) {
 	for _, task := range tasks {
 		sf.g.push(task.node)
 	}
 }
 
-func (fb *flowBuilder) NewStatic(name string, f func()) *innerNode {
+// TryPush is Push returning an error instead of panicking if a task name is already taken, in which case no task is pushed
+func (sf *Subflow) TryPush(tasks ...*Task) error {
+	return sf.g.tryPush(nodesOf(tasks)...)
+}
+
+// AddTask creates a static task and pushes it into subflow
+func (sf *Subflow) AddTask(name string, f func()) *Task {
+	task := NewTask(name, f)
+	sf.Push(task)
+	return task
+}
+
+// FindTask returns task named name pushed into subflow
+func (sf *Subflow) FindTask(name string) (*Task, bool) {
+	node, ok := sf.g.FindNode(name)
+	if !ok {
+		return nil, false
+	}
+	return &Task{node: node}, true
+}
+
+// Precede makes b depend on a, both of which must be pushed into subflow.
+// Tasks of sibling subflows are refused even under the same parent: each subflow builds its graph when its handle runs
+// and drains it on its own, so a sibling task may be unbuilt, already finished, or in a graph that has drained,
+// and an edge to it would never be released. Precede the subflows in their parent, or build the tasks in one subflow.
+func (sf *Subflow) Precede(a, b *Task) {
+	for _, task := range []*Task{a, b} {
+		if task.node.g != sf.g {
+			panic(fmt.Sprintf("task %v is not in subflow %v, precede subflows in their parent instead", task.node.name, sf.g.name))
+		}
+	}
+	a.Precede(b)
+}
+
+func (fb *flowBuilder) NewStatic(name string, f any) *innerNode {
 	node := newNode(name)
+	node.origin = f
 	node.ptr = &Static{
 		handle: f,
 	}
@@ -57,9 +204,11 @@ func (fb *flowBuilder) NewStatic(name string, f func()) *innerNode {
 
 func (fb *flowBuilder) NewSubflow(name string, f func(sf *Subflow)) *innerNode {
 	node := newNode(name)
+	node.origin = f
 	node.ptr = &Subflow{
 		handle: f,
 		g:      newGraph(name),
+		mu:     &sync.Mutex{},
 	}
 	node.Typ = nodeSubflow
 	return node
@@ -67,9 +216,24 @@ func (fb *flowBuilder) NewSubflow(name string, f func(sf *Subflow)) *innerNode {
 
 func (fb *flowBuilder) NewCondition(name string, f func() uint) *innerNode {
 	node := newNode(name)
+	node.origin = f
 	node.ptr = &Condition{
 		handle: f,
 		mapper: make(map[uint]*innerNode),
+		mu:     &sync.Mutex{},
+	}
+	node.Typ = nodeCondition
+	return node
+}
+
+func (fb *flowBuilder) NewStringCondition(name string, f func() string) *innerNode {
+	node := newNode(name)
+	node.origin = f
+	node.ptr = &Condition{
+		keyHandle:    f,
+		mapper:       make(map[uint]*innerNode),
+		stringMapper: make(map[string]*innerNode),
+		mu:           &sync.Mutex{},
 	}
 	node.Typ = nodeCondition
 	return node
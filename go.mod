@@ -5,6 +5,7 @@ go 1.21.6
 require (
 	github.com/eapache/queue/v2 v2.0.0-20230407133247-75960ed334e4
 	github.com/goccy/go-graphviz v0.1.3
+	google.golang.org/protobuf v1.36.5
 )
 
 require (
@@ -1,8 +1,14 @@
 package gotaskflow
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/noneback/go-taskflow/utils"
 )
@@ -10,20 +16,52 @@ import (
 type eGraph struct { // execution graph
 	name          string
 	nodes         []*innerNode
-	joinCounter   *utils.RC    // 引用计数，用于跟踪未完成任务数
-	entries       []*innerNode // 入口节点(无前置依赖)
-	scheCond      *sync.Cond   // 调度条件变量
+	nameIndex     map[string]*innerNode // nodes by name, see FindNode
+	joinCounter   *utils.RC             // 引用计数，用于跟踪未完成任务数
+	entries       []*innerNode          // 入口节点(无前置依赖)
+	scheCond      *sync.Cond            // 调度条件变量
 	instancelized bool
-	canceled      atomic.Bool // only changes when task in graph panic
+	canceled      atomic.Bool         // changes when task in graph panic or graph is canceled
+	parent        *eGraph             // graph holding the subflow node, nil for taskflow
+	skipTags      map[string]struct{} // nodes carrying any of them are skipped in this run
+	limiter       *limiter            // caps how many nodes of this graph run at once, nil means no cap
+	rnd           *rand.Rand          // shuffles ready nodes when not nil, see TaskFlow.RandomizeOrder
+	rndMu         *sync.Mutex
+	failure       atomic.Pointer[error] // first panic of its tasks, or of tasks in its subflows, in current run
+	beforeRun     []func()              // hooks of taskflow, never set for subflow
+	afterRun      []func(err error)
+	store         atomic.Pointer[sync.Map] // values shared by tasks in current run, see Runtime.Set
+	runID         atomic.Uint64            // id of current or latest run, subflows take the one of their parent
+	span          *span                    // span of subflow task running this graph, nil for taskflow
+	deadline      time.Time                // run is failed once passed, zero means none, see TaskFlow.SetDeadline
+	costs         map[*innerNode]nodeCost  // costs of nodes finished in current or latest run, see TaskFlow.CriticalPath
+	costsMu       *sync.Mutex
+	slog          *slog.Logger               // of executor running it, nil for subflow, see Runtime.Logger
+	from          *innerNode                 // sole entry of a partial run, nil runs all nodes, see Executor.RunFrom
+	handle        *RunHandle                 // of current run if started by RunAsync, never set for subflow
+	scope         map[*innerNode]struct{}    // nodes reachable from from in current run, nil for a full run
+	runCtx        atomic.Pointer[runContext] // context of current or latest run, see Runtime.Context
+	errPolicy     ErrorPolicy                // of executor running it, never set for subflow
+	later         []error                    // failures after the first one in current run, see ErrorsJoined
+	laterMu       *sync.Mutex
+	history       *nodeStats // runs of its nodes over all runs, never reset, see TaskFlow.SlowestNodes
 }
 
 func newGraph(name string) *eGraph {
-	return &eGraph{
+	g := &eGraph{
 		name:        name,
 		nodes:       make([]*innerNode, 0),
+		nameIndex:   make(map[string]*innerNode),
 		scheCond:    sync.NewCond(&sync.Mutex{}),
 		joinCounter: utils.NewRC(),
+		rndMu:       &sync.Mutex{},
+		costs:       make(map[*innerNode]nodeCost),
+		costsMu:     &sync.Mutex{},
+		laterMu:     &sync.Mutex{},
+		history:     newNodeStats(),
 	}
+	g.store.Store(&sync.Map{})
+	return g
 }
 
 func (g *eGraph) JoinCounter() int {
@@ -31,28 +69,310 @@ func (g *eGraph) JoinCounter() int {
 }
 
 func (g *eGraph) reset() {
+	g.canceled.Store(false)
+	g.failure.Store(nil)
+	g.laterMu.Lock()
+	g.later = nil
+	g.laterMu.Unlock()
+	g.store.Store(&sync.Map{})
 	g.joinCounter.Set(0)
 	g.entries = g.entries[:0]
+	g.costsMu.Lock()
+	clear(g.costs)
+	g.costsMu.Unlock()
 	for _, n := range g.nodes {
 		n.joinCounter.Set(0)
+		if cond, ok := n.ptr.(*Condition); ok {
+			cond.resetChoices()
+		}
 	}
 }
 
+// push adds nodes into g, panics if name of any is taken in g, see tryPush
 func (g *eGraph) push(n ...*innerNode) {
+	if err := g.tryPush(n...); err != nil {
+		panic(err.Error())
+	}
+}
+
+// tryPush adds nodes into g, returns an error if name of any is taken in g, in which case none is added
+func (g *eGraph) tryPush(n ...*innerNode) error {
+	added := make(map[string]struct{}, len(n))
+	for _, node := range n {
+		_, dup := added[node.name]
+		if _, ok := g.nameIndex[node.name]; ok || dup {
+			return fmt.Errorf("task %v already exists in graph %v", node.name, g.name)
+		}
+		added[node.name] = struct{}{}
+	}
+
 	g.nodes = append(g.nodes, n...)
 	for _, node := range n {
+		g.nameIndex[node.name] = node
 		node.g = g
+		if p, ok := node.ptr.(*Subflow); ok {
+			p.g.parent = g
+		}
+	}
+	return nil
+}
+
+// truncate drops nodes pushed after the first size ones
+func (g *eGraph) truncate(size int) {
+	for _, node := range g.nodes[size:] {
+		delete(g.nameIndex, node.name)
 	}
+	g.nodes = g.nodes[:size]
+}
+
+// rename changes name of node in g, panics if name is taken by another node
+func (g *eGraph) rename(node *innerNode, name string) {
+	if n, ok := g.nameIndex[name]; ok && n != node {
+		panic(fmt.Sprintf("task %v already exists in graph %v", name, g.name))
+	}
+	delete(g.nameIndex, node.name)
+	node.name = name
+	g.nameIndex[name] = node
+}
+
+// FindNode returns node named name in g
+func (g *eGraph) FindNode(name string) (*innerNode, bool) {
+	node, ok := g.nameIndex[name]
+	return node, ok
+}
+
+// cancel stops scheduling nodes of g and its running subflows
+func (g *eGraph) cancel() {
+	g.canceled.Store(true)
+	g.stopContext(ErrCanceled)
+	for _, node := range g.nodes {
+		if p, ok := node.ptr.(*Subflow); ok && p.g.instancelized {
+			p.g.cancel()
+		}
+	}
+	g.scheCond.Broadcast()
+}
+
+// fail cancels g, or the whole run unless errors are per graph, and records err as failure of g and graphs
+// it is nested in, unless they already failed
+func (g *eGraph) fail(err error) {
+	halted := g
+	if root := g.root(); root.errPolicy != ErrorsPerGraph {
+		// nested graphs see it by isCanceled
+		halted = root
+	}
+	halted.canceled.Store(true)
+	halted.stopContext(err)
+	for cur := g; cur != nil; cur = cur.parent {
+		if !cur.failure.CompareAndSwap(nil, &err) {
+			cur.addLater(err)
+		}
+	}
+}
+
+// err returns the first failure, joined with later ones under ErrorsJoined, or ErrCanceled if g is canceled
+func (g *eGraph) err() error {
+	if err := g.failure.Load(); err != nil {
+		g.laterMu.Lock()
+		defer g.laterMu.Unlock()
+		if len(g.later) > 0 {
+			return errors.Join(append([]error{*err}, g.later...)...)
+		}
+		return *err
+	}
+	if g.canceled.Load() {
+		return ErrCanceled
+	}
+	return nil
+}
+
+// isCanceled reports whether g or any graph it is nested in is canceled
+func (g *eGraph) isCanceled() bool {
+	for cur := g; cur != nil; cur = cur.parent {
+		if cur.canceled.Load() {
+			return true
+		}
+	}
+	return false
 }
 
 func (g *eGraph) setup() {
 	g.reset()
+	g.startContext()
+	g.markLoops()
+	g.scope = nil
+	if g.from != nil {
+		g.scope = reach(g.from)
+	}
 
 	for _, node := range g.nodes {
 		node.setup()
 
-		if len(node.dependents) == 0 {
+		if g.scope != nil {
+			// unrelated nodes are left idle, never released by nodes in scope
+			if node == g.from {
+				g.entries = append(g.entries, node)
+			}
+			continue
+		}
+		if len(node.dependents) == 0 && node.JoinCounter() == 0 {
 			g.entries = append(g.entries, node)
 		}
 	}
 }
+
+// randomize makes the graph shuffle its ready nodes with a rand seeded by seed
+func (g *eGraph) randomize(seed int64) {
+	g.rndMu.Lock()
+	defer g.rndMu.Unlock()
+	g.rnd = rand.New(rand.NewSource(seed))
+}
+
+// shuffle permutes nodes in place if the graph is randomized, returns whether it did
+func (g *eGraph) shuffle(nodes []*innerNode) bool {
+	g.rndMu.Lock()
+	defer g.rndMu.Unlock()
+	if g.rnd == nil {
+		return false
+	}
+	g.rnd.Shuffle(len(nodes), func(i, j int) {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	})
+	return true
+}
+
+// inheritOrder randomizes g as well if parent is randomized, so subflows follow their parent flow
+func (g *eGraph) inheritOrder(parent *eGraph) {
+	parent.rndMu.Lock()
+	if parent.rnd == nil {
+		parent.rndMu.Unlock()
+		return
+	}
+	seed := parent.rnd.Int63()
+	parent.rndMu.Unlock()
+	g.randomize(seed)
+}
+
+// depth returns how many graphs g is nested in, 0 for taskflow
+func (g *eGraph) depth() int {
+	d := 0
+	for cur := g.parent; cur != nil; cur = cur.parent {
+		d++
+	}
+	return d
+}
+
+// walk visits nodes of g and its instancelized subflows in depth first order
+func (g *eGraph) walk(visit func(n *innerNode)) {
+	for _, node := range g.nodes {
+		visit(node)
+		if p, ok := node.ptr.(*Subflow); ok && p.g.instancelized {
+			p.g.walk(visit)
+		}
+	}
+}
+
+// markReentrant marks nodes reachable from condition nodes, as they may run again in a loop
+func (g *eGraph) markReentrant() {
+	var visit func(n *innerNode)
+	visit = func(n *innerNode) {
+		for _, succ := range n.successors {
+			if !succ.reentrant {
+				succ.reentrant = true
+				visit(succ)
+			}
+		}
+	}
+	for _, n := range g.nodes {
+		if n.Typ == nodeCondition {
+			visit(n)
+		}
+	}
+}
+
+// markLoops finds out condition nodes which can reach themselves
+func (g *eGraph) markLoops() {
+	for _, n := range g.nodes {
+		if cond, ok := n.ptr.(*Condition); ok {
+			cond.looping = reachable(n.successors, n)
+		}
+	}
+}
+
+// reachable reports whether target can be reached from nodes
+func reachable(nodes []*innerNode, target *innerNode) bool {
+	visited := make(map[*innerNode]struct{})
+	stack := slices.Clone(nodes)
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == target {
+			return true
+		}
+		if _, ok := visited[n]; ok {
+			continue
+		}
+		visited[n] = struct{}{}
+		stack = append(stack, n.successors...)
+	}
+	return false
+}
+
+// reach returns nodes reachable from n through successors and barriers arrived at, n included
+func reach(n *innerNode) map[*innerNode]struct{} {
+	visited := make(map[*innerNode]struct{})
+	stack := []*innerNode{n}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := visited[n]; ok {
+			continue
+		}
+		visited[n] = struct{}{}
+		stack = append(stack, n.successors...)
+		stack = append(stack, n.arrives...)
+	}
+	return visited
+}
+
+// runHooks runs before hooks, or after hooks with the result of run if after.
+// A panic in hook is reported by rep, and fails g.
+func (g *eGraph) runHooks(rep reporter, after bool) {
+	run := func(name string, hook func()) {
+		defer func() {
+			if r := recover(); r != nil {
+				g.fail(reportRecovered(rep, "hook", name, g.name, g.runID.Load(), r, -1))
+			}
+		}()
+		hook()
+	}
+
+	if !after {
+		for _, hook := range g.beforeRun {
+			run("before_run", hook)
+		}
+		return
+	}
+	err := g.err()
+	for _, hook := range g.afterRun {
+		run("after_run", func() { hook(err) })
+	}
+}
+
+// checkBalance reports counters left unbalanced by a finished run of g
+func (g *eGraph) checkBalance() error {
+	errs := make([]error, 0)
+	if cnt := g.JoinCounter(); cnt != 0 {
+		errs = append(errs, fmt.Errorf("graph %v has %v unfinished nodes", g.name, cnt))
+	}
+	for _, n := range g.nodes {
+		if cnt, pending := n.JoinCounter(), n.pending(); cnt > pending {
+			errs = append(errs, fmt.Errorf("join counter of %v in graph %v is %v, more than its %v dependents", n.name, g.name, cnt, pending))
+		}
+		// nodes queued before cancel are never run
+		if state := n.state.Load(); state == kNodeStateRunning || state == kNodeStateWaiting && !g.isCanceled() {
+			errs = append(errs, fmt.Errorf("node %v in graph %v is not finished", n.name, g.name))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package gotaskflow
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphCheckBalance(t *testing.T) {
+	g := newGraph("G")
+	A, B := newNode("A"), newNode("B")
+	A.precede(B)
+	g.push(A, B)
+	g.setup()
+	if err := g.checkBalance(); err != nil {
+		t.Fatal(err)
+	}
+
+	B.joinCounter.Increase()
+	A.state.Store(kNodeStateRunning)
+	err := g.checkBalance()
+	if err == nil || !strings.Contains(err.Error(), "join counter of B in graph G is 2") || !strings.Contains(err.Error(), "node A in graph G is not finished") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestGraphFindNode(t *testing.T) {
+	g := newGraph("G")
+	A, B := newNode("A"), newNode("B")
+	g.push(A, B)
+	if n, ok := g.FindNode("B"); !ok || n != B {
+		t.Errorf("expected B, got %v", n)
+	}
+	if _, ok := g.FindNode("C"); ok {
+		t.Error("found unknown node")
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(r.(string), "task A already exists in graph G") {
+				t.Errorf("unexpected panic %v", r)
+			}
+		}()
+		g.push(newNode("C"), newNode("A"))
+	}()
+	if _, ok := g.FindNode("C"); ok || len(g.nodes) != 2 {
+		t.Error("nodes are pushed despite duplicate")
+	}
+
+	g.truncate(1)
+	if _, ok := g.FindNode("B"); ok {
+		t.Error("found truncated node")
+	}
+}
+
+func TestSubflowFinishesAfterGraph(t *testing.T) {
+	executor := NewExecutor(4)
+	tf := NewTaskFlow("G")
+	var sub *Task
+	states := make(chan int32, 1)
+	sub = NewSubflow("sub", func(sf *Subflow) {
+		sf.Push(NewTask("child", func() {
+			time.Sleep(10 * time.Millisecond)
+			states <- sub.node.state.Load()
+		}))
+	})
+	tf.Push(sub)
+	executor.Run(tf).Wait()
+	if state := <-states; state != kNodeStateRunning {
+		t.Errorf("expected subflow running while its graph runs, got %v", stateNames[state])
+	}
+	if state := sub.node.state.Load(); state != kNodeStateFinished {
+		t.Errorf("expected subflow finished, got %v", stateNames[state])
+	}
+}
+
+func TestNodeStateTransitions(t *testing.T) {
+	g := newGraph("G")
+	A := newNode("A")
+	g.push(A)
+	g.setup()
+
+	for _, to := range []int32{kNodeStateWaiting, kNodeStateRunning, kNodeStateFinished, kNodeStateWaiting} {
+		if err := A.transitionState(A.state.Load(), to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := A.transitionState(kNodeStateWaiting, kNodeStateFinished); err == nil || !strings.Contains(err.Error(), "waiting -> finished") {
+		t.Errorf("expected illegal transition, got %v", err)
+	}
+	if err := A.transitionState(kNodeStateRunning, kNodeStateFinished); err == nil || !strings.Contains(err.Error(), "but it is waiting") {
+		t.Errorf("expected transition from stale state to fail, got %v", err)
+	}
+	if A.state.Load() != kNodeStateWaiting {
+		t.Errorf("expected state kept by failed transitions, got %v", stateNames[A.state.Load()])
+	}
+
+	// logged and made anyway by default
+	var buf strings.Builder
+	e := NewExecutor(1, WithLogger(&buf)).(*innerExecutorImpl)
+	A.state.Store(kNodeStateRunning)
+	e.transition(A, kNodeStateWaiting)
+	if A.state.Load() != kNodeStateWaiting || !strings.Contains(buf.String(), "running -> waiting") {
+		t.Errorf("expected illegal transition logged and made, got %v, %q", stateNames[A.state.Load()], buf.String())
+	}
+
+	strict := NewExecutor(1, WithLogger(&buf), WithStrictStateTransitions()).(*innerExecutorImpl)
+	defer func() {
+		if r := recover(); r == nil || A.state.Load() != kNodeStateRunning {
+			t.Errorf("expected illegal transition to panic keeping state, got %v", r)
+		}
+	}()
+	A.state.Store(kNodeStateRunning)
+	strict.transition(A, kNodeStateIdle)
+}
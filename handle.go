@@ -0,0 +1,106 @@
+package gotaskflow
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// RunHandle tracks a taskflow run started by RunAsync
+type RunHandle struct {
+	done     chan struct{}
+	err      error
+	canceled bool
+	g        *eGraph
+	run      uint64
+	started  bool // graph of the run is set up, so that canceling it sticks
+	pending  bool // canceled before started
+	mu       *sync.Mutex
+}
+
+func newRunHandle(g *eGraph, run uint64) *RunHandle {
+	return &RunHandle{done: make(chan struct{}), g: g, run: run, mu: &sync.Mutex{}}
+}
+
+// start is called once graph of the run is set up, it applies Cancel called before
+func (h *RunHandle) start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.started = true
+	if h.pending {
+		h.g.cancel()
+	}
+}
+
+// RunID returns id of the run, see WithRunID
+func (h *RunHandle) RunID() uint64 {
+	return h.run
+}
+
+// Done returns a channel closed when all scheduled tasks of taskflow finished, including when it is canceled
+func (h *RunHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Err returns the first panic of tasks, or ErrCanceled if taskflow is canceled. It is valid after Done is closed
+func (h *RunHandle) Err() error {
+	return h.err
+}
+
+// Canceled reports whether the run was canceled, by Cancel or by a failed task, so that its work can be rolled back.
+// Unlike TaskFlow.Canceled, it stays with the run when taskflow runs again. It is valid after Done is closed
+func (h *RunHandle) Canceled() bool {
+	return h.canceled
+}
+
+// Cancel stop scheduling tasks of the run, running tasks are not interrupted.
+// A run canceled before it started is canceled as soon as it starts, so none of its tasks run.
+// It is a no-op after Done is closed.
+func (h *RunHandle) Cancel() {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.started {
+		// run resets graph when it starts, which would clear the cancel
+		h.pending = true
+		return
+	}
+	h.g.cancel()
+}
+
+// WaitAll blocks until all runs are done, returns errors of them joined
+func WaitAll(handles ...*RunHandle) error {
+	errs := make([]error, 0)
+	for _, h := range handles {
+		<-h.Done()
+		if err := h.Err(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WaitAny blocks until one of runs is done, cancels the others and returns index of the done one.
+// It returns -1 if handles is empty.
+func WaitAny(handles ...*RunHandle) int {
+	if len(handles) == 0 {
+		return -1
+	}
+
+	cases := make([]reflect.SelectCase, 0, len(handles))
+	for _, h := range handles {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(h.Done())})
+	}
+	winner, _, _ := reflect.Select(cases)
+	for i, h := range handles {
+		if i != winner {
+			h.Cancel()
+		}
+	}
+	return winner
+}
@@ -0,0 +1,213 @@
+package gotaskflow
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Layers partitions nodes by topological depth: layer 0 holds entries, and a node is in the layer after
+// the deepest of its dependents. It fails on cycle, including loops made by conditions, returning the layers found.
+func (g *eGraph) Layers() ([][]*innerNode, error) {
+	indegree := make(map[*innerNode]int, len(g.nodes))
+	for _, n := range g.nodes {
+		indegree[n] = len(n.dependents)
+	}
+
+	layers := make([][]*innerNode, 0)
+	cur := make([]*innerNode, 0)
+	for _, n := range g.nodes {
+		if indegree[n] == 0 {
+			cur = append(cur, n)
+		}
+	}
+
+	layered := 0
+	for len(cur) > 0 {
+		layers = append(layers, cur)
+		layered += len(cur)
+		next := make([]*innerNode, 0)
+		for _, n := range cur {
+			for _, succ := range n.successors {
+				if indegree[succ]--; indegree[succ] == 0 {
+					next = append(next, succ)
+				}
+			}
+		}
+		cur = next
+	}
+
+	if layered != len(g.nodes) {
+		cycle := make([]string, 0)
+		for _, n := range g.nodes {
+			if indegree[n] > 0 {
+				cycle = append(cycle, n.name)
+			}
+		}
+		return layers, fmt.Errorf("graph %v has cycle among %v", g.name, cycle)
+	}
+	return layers, nil
+}
+
+// rankLayers partitions nodes like Layers, for drawing. Edges closing loops, which are found by depth first search
+// from entries in pushing order, are left out, so that nodes of a condition loop are ranked after the condition
+// entering it rather than failing.
+func (g *eGraph) rankLayers() [][]*innerNode {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	type edge struct{ from, to *innerNode }
+	marks := make(map[*innerNode]int, len(g.nodes))
+	indegree := make(map[*innerNode]int, len(g.nodes))
+	loops := make(map[edge]struct{})
+	var visit func(n *innerNode)
+	visit = func(n *innerNode) {
+		marks[n] = visiting
+		for _, succ := range n.successors {
+			switch marks[succ] {
+			case visiting:
+				loops[edge{n, succ}] = struct{}{}
+				continue
+			case unvisited:
+				visit(succ)
+			}
+			indegree[succ]++
+		}
+		marks[n] = visited
+	}
+	for _, n := range g.nodes {
+		if len(n.dependents) == 0 && marks[n] == unvisited {
+			visit(n)
+		}
+	}
+	// nodes only reachable from a loop without entry
+	for _, n := range g.nodes {
+		if marks[n] == unvisited {
+			visit(n)
+		}
+	}
+
+	layers := make([][]*innerNode, 0)
+	cur := make([]*innerNode, 0)
+	for _, n := range g.nodes {
+		if indegree[n] == 0 {
+			cur = append(cur, n)
+		}
+	}
+	for len(cur) > 0 {
+		layers = append(layers, cur)
+		next := make([]*innerNode, 0)
+		for _, n := range cur {
+			for _, succ := range n.successors {
+				if _, ok := loops[edge{n, succ}]; ok {
+					continue
+				}
+				if indegree[succ]--; indegree[succ] == 0 {
+					next = append(next, succ)
+				}
+			}
+		}
+		cur = next
+	}
+	return layers
+}
+
+// Layers returns names of tasks partitioned by topological depth, see eGraph.Layers.
+// Subflows are single tasks.
+func (tf *TaskFlow) Layers() ([][]string, error) {
+	layers, err := tf.graph.Layers()
+	names := make([][]string, 0, len(layers))
+	for _, layer := range layers {
+		names = append(names, nodeNames(layer))
+	}
+	if err != nil {
+		return names, fmt.Errorf("layers of %v -> %w", tf.name, err)
+	}
+	return names, nil
+}
+
+// MaxWidth returns size of the widest layer, as many nodes as may run at once. Nodes left out of layers
+// by a cycle are counted as one more layer.
+func (g *eGraph) MaxWidth() int {
+	layers, _ := g.Layers()
+	width, layered := 0, 0
+	for _, layer := range layers {
+		width = max(width, len(layer))
+		layered += len(layer)
+	}
+	return max(width, len(g.nodes)-layered)
+}
+
+// MaxParallelism returns how many tasks of tf may run at once, judged by its widest layer, see Layers.
+// It is a property of graph structure, rather than a measure of runs. Subflows are single tasks.
+func (tf *TaskFlow) MaxParallelism() int {
+	return tf.graph.MaxWidth()
+}
+
+// RecommendedConcurrency returns concurrency of executor worth running tf with, which is MaxParallelism
+// bound by number of CPUs, and at least 1. Like MaxParallelism, it is derived from graph structure only.
+func (tf *TaskFlow) RecommendedConcurrency() uint {
+	return uint(max(1, min(runtime.NumCPU(), tf.MaxParallelism())))
+}
+
+// Statistics is structural metrics of a taskflow, see TaskFlow.Statistics.
+// Tasks in subflows count once subflows are instantiated by a run, a subflow not run yet is a single task.
+type Statistics struct {
+	NodeCount      int `json:"node_count"` // tasks, subflow tasks and tasks in them included
+	EdgeCount      int `json:"edge_count"`
+	SubflowCount   int `json:"subflow_count"`
+	ConditionCount int `json:"condition_count"`
+	// MaxDepth is tasks on the longest chain, through which a subflow weighs the longest chain in it.
+	// Tasks on a cycle are left out
+	MaxDepth int `json:"max_depth"`
+	MaxWidth int `json:"max_width"` // widest layer of taskflow or any subflow, see MaxParallelism
+	// CyclicSubgraphs reports cycles made of strong edges in taskflow or any subflow, which Validate rejects
+	CyclicSubgraphs bool `json:"cyclic_subgraphs"`
+}
+
+// Statistics returns structural metrics of tf, such as to log its complexity or to reject huge flows.
+// They are derived from graph structure, rather than measured by runs.
+func (tf *TaskFlow) Statistics() Statistics {
+	s := Statistics{MaxDepth: tf.graph.depthOfChains()}
+	graphs := []*eGraph{tf.graph}
+	tf.graph.walk(func(n *innerNode) {
+		s.NodeCount++
+		s.EdgeCount += len(n.successors)
+		switch p := n.ptr.(type) {
+		case *Subflow:
+			s.SubflowCount++
+			if p.g.instancelized {
+				graphs = append(graphs, p.g)
+			}
+		case *Condition:
+			s.ConditionCount++
+		}
+	})
+	for _, g := range graphs {
+		s.MaxWidth = max(s.MaxWidth, g.MaxWidth())
+		s.CyclicSubgraphs = s.CyclicSubgraphs || g.strongCycle() != nil
+	}
+	return s
+}
+
+// depthOfChains returns tasks on the longest chain of g, an instantiated subflow weighs the longest chain in it
+func (g *eGraph) depthOfChains() int {
+	layers, _ := g.Layers()
+	depth := make(map[*innerNode]int, len(g.nodes))
+	longest := 0
+	for _, layer := range layers {
+		for _, n := range layer {
+			weight := 1
+			if p, ok := n.ptr.(*Subflow); ok && p.g.instancelized {
+				weight = max(1, p.g.depthOfChains())
+			}
+			for _, dep := range n.dependents {
+				depth[n] = max(depth[n], depth[dep])
+			}
+			depth[n] += weight
+			longest = max(longest, depth[n])
+		}
+	}
+	return longest
+}
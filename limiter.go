@@ -0,0 +1,54 @@
+package gotaskflow
+
+import "sync"
+
+// limiter bounds how many jobs run at once. Excess jobs are parked and submitted
+// once a running job finishes, so the dispatcher is never blocked.
+type limiter struct {
+	cap     int
+	running int
+	pending []func(worker int)
+	mu      *sync.Mutex
+}
+
+func newLimiter(cap uint) *limiter {
+	return &limiter{
+		cap:     int(cap),
+		pending: make([]func(worker int), 0),
+		mu:      &sync.Mutex{},
+	}
+}
+
+// do submits job once a slot is available
+func (l *limiter) do(submit func(func(worker int)), job func(worker int)) {
+	l.mu.Lock()
+	if l.running >= l.cap {
+		l.pending = append(l.pending, job)
+		l.mu.Unlock()
+		return
+	}
+	l.running++
+	l.mu.Unlock()
+	submit(l.wrap(submit, job))
+}
+
+func (l *limiter) wrap(submit func(func(worker int)), job func(worker int)) func(worker int) {
+	return func(worker int) {
+		defer l.done(submit)
+		job(worker)
+	}
+}
+
+// done hands the slot over to a parked job, or releases it
+func (l *limiter) done(submit func(func(worker int))) {
+	l.mu.Lock()
+	if len(l.pending) == 0 {
+		l.running--
+		l.mu.Unlock()
+		return
+	}
+	next := l.pending[0]
+	l.pending = l.pending[1:]
+	l.mu.Unlock()
+	submit(l.wrap(submit, next))
+}
@@ -0,0 +1,105 @@
+package gotaskflow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExportMarkdown documents structure of taskflow in Markdown: a Mermaid flowchart, which GitHub renders natively,
+// and a table of tasks with their type, priority, label, tags and dependents. Like Visualize, it builds subflows
+// not built yet, each getting a collapsible section of its own. A subflow failing to build is marked so.
+func (tf *TaskFlow) ExportMarkdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %v\n\n", markdownEscape(tf.name))
+	sb.WriteString("```mermaid\nflowchart LR\n")
+	m := &mermaid{ids: make(map[*innerNode]string)}
+	m.graph(&sb, tf.graph, "  ")
+	sb.WriteString("```\n\n")
+	markdownTable(&sb, tf.graph, 2)
+	return sb.String()
+}
+
+// mermaid writes nodes of graphs as Mermaid flowchart, identified by sequence so that any name can be a label
+type mermaid struct {
+	ids map[*innerNode]string
+}
+
+func (m *mermaid) id(n *innerNode) string {
+	if id, ok := m.ids[n]; ok {
+		return id
+	}
+	id := fmt.Sprintf("n%d", len(m.ids))
+	m.ids[n] = id
+	return id
+}
+
+func (m *mermaid) graph(sb *strings.Builder, g *eGraph, indent string) {
+	for _, n := range g.nodes {
+		label := mermaidEscape(n.name)
+		switch p := n.ptr.(type) {
+		case *Condition:
+			fmt.Fprintf(sb, "%s%s{\"%s\"}\n", indent, m.id(n), label)
+		case *Subflow:
+			if p.instancelize() != nil {
+				fmt.Fprintf(sb, "%s%s[\"%s (failed to build)\"]\n", indent, m.id(n), label)
+				continue
+			}
+			fmt.Fprintf(sb, "%ssubgraph %s [\"%s\"]\n", indent, m.id(n), label)
+			m.graph(sb, p.g, indent+"  ")
+			fmt.Fprintf(sb, "%send\n", indent)
+		default:
+			fmt.Fprintf(sb, "%s%s[\"%s\"]\n", indent, m.id(n), label)
+		}
+	}
+	for _, n := range g.nodes {
+		cond, isCond := n.ptr.(*Condition)
+		for idx, succ := range n.successors {
+			if isCond {
+				fmt.Fprintf(sb, "%s%s -.->|\"%s\"| %s\n", indent, m.id(n), mermaidEscape(cond.label(idx, succ)), m.id(succ))
+			} else {
+				fmt.Fprintf(sb, "%s%s --> %s\n", indent, m.id(n), m.id(succ))
+			}
+		}
+	}
+}
+
+// markdownTable writes a table of tasks of g, then a section of each built subflow under a heading of level
+func markdownTable(sb *strings.Builder, g *eGraph, level int) {
+	fmt.Fprintf(sb, "%s Tasks\n\n", strings.Repeat("#", level))
+	sb.WriteString("| Task | Type | Priority | Label | Tags | Dependents |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, n := range g.nodes {
+		fmt.Fprintf(sb, "| %s | %s | %s | %s | %s | %s |\n", markdownEscape(n.name), n.Typ, priorityName(n.priority),
+			markdownEscape(n.label), markdownEscape(strings.Join(n.tags, ", ")), markdownEscape(strings.Join(nodeNames(n.dependents), ", ")))
+	}
+
+	for _, n := range g.nodes {
+		if p, ok := n.ptr.(*Subflow); ok && p.g.instancelized {
+			fmt.Fprintf(sb, "\n<details>\n<summary>Subflow %s</summary>\n\n", markdownEscape(n.name))
+			markdownTable(sb, p.g, level+1)
+			sb.WriteString("\n</details>\n")
+		}
+	}
+}
+
+func priorityName(p TaskPriority) string {
+	switch p {
+	case HIGH:
+		return "HIGH"
+	case NORMAL:
+		return "NORMAL"
+	case LOW:
+		return "LOW"
+	}
+	return fmt.Sprint(uint(p))
+}
+
+// mermaidEscape makes s safe in a quoted Mermaid label
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// markdownEscape makes s safe in a Markdown table cell
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,73 @@
+package gotaskflow
+
+import "fmt"
+
+// Bridge declares that task From of a taskflow precedes task To of the taskflow merged into it
+type Bridge struct {
+	From string
+	To   string
+}
+
+// Merge moves all tasks of other into tf, and wires them up by bridges.
+// Task names must be unique across both flows, and From of a bridge cannot be a condition.
+// Merged result is validated. On error nothing is changed, otherwise other is left empty,
+// and settings of other such as WithMaxConcurrency are dropped.
+func (tf *TaskFlow) Merge(other *TaskFlow, bridges ...Bridge) error {
+	if other == tf {
+		return fmt.Errorf("merge %v into itself", tf.name)
+	}
+
+	collisions := make([]string, 0)
+	for _, n := range other.graph.nodes {
+		if _, ok := tf.graph.FindNode(n.name); ok {
+			collisions = append(collisions, n.name)
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("merge %v into %v -> name collision %v", other.name, tf.name, collisions)
+	}
+
+	from, to := make([]*innerNode, 0, len(bridges)), make([]*innerNode, 0, len(bridges))
+	for _, b := range bridges {
+		f, ok := tf.graph.FindNode(b.From)
+		if !ok {
+			return fmt.Errorf("merge %v into %v -> bridge from unknown task %v", other.name, tf.name, b.From)
+		}
+		if f.Typ == nodeCondition {
+			return fmt.Errorf("merge %v into %v -> bridge from condition %v", other.name, tf.name, b.From)
+		}
+		t, ok := other.graph.FindNode(b.To)
+		if !ok {
+			return fmt.Errorf("merge %v into %v -> bridge to unknown task %v", other.name, tf.name, b.To)
+		}
+		from, to = append(from, f), append(to, t)
+	}
+
+	size := len(tf.graph.nodes)
+	tf.graph.push(other.graph.nodes...)
+	// edges are only appended by precede, so slices taken before wiring restore them
+	successors, dependents := make([][]*innerNode, len(from)), make([][]*innerNode, len(to))
+	for i := range from {
+		successors[i], dependents[i] = from[i].successors, to[i].dependents
+	}
+	for i := range from {
+		from[i].precede(to[i])
+	}
+
+	if err := tf.Validate(); err != nil {
+		for i := range from {
+			from[i].successors, to[i].dependents = successors[i], dependents[i]
+		}
+		tf.graph.truncate(size)
+		for _, n := range other.graph.nodes {
+			n.g = other.graph
+			if p, ok := n.ptr.(*Subflow); ok {
+				p.g.parent = other.graph
+			}
+		}
+		return fmt.Errorf("merge %v into %v -> %w", other.name, tf.name, err)
+	}
+
+	other.graph = newGraph(other.name)
+	return nil
+}
@@ -1,8 +1,11 @@
 package gotaskflow
 
 import (
+	"fmt"
+	"slices"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/noneback/go-taskflow/utils"
 )
@@ -13,6 +16,7 @@ const (
 	kNodeStateRunning  = int32(2)
 	kNodeStateFinished = int32(3)
 	kNodeStateFailed   = int32(4)
+	kNodeStateSkipped  = int32(6)
 	// kNodeStateCanceled = int32(5)
 )
 
@@ -24,57 +28,166 @@ const (
 	nodeCondition nodeType = "condition" // static
 )
 
+// innerNode is kept compact for huge graphs: fields are grouped by size, and mutex and counter are held by value.
+// Its name is shared by spans recording it rather than copied, see spanName.
 type innerNode struct {
-	name        string
-	successors  []*innerNode
-	dependents  []*innerNode
-	Typ         nodeType
-	ptr         interface{} // 存储具体任务实现
-	rw          *sync.RWMutex
-	state       atomic.Int32 // 任务状态
-	joinCounter *utils.RC    // 入度计数器
-	g           *eGraph
-	priority    TaskPriority
+	name         string
+	successors   []*innerNode
+	dependents   []*innerNode
+	Typ          nodeType
+	ptr          interface{} // 存储具体任务实现
+	g            *eGraph
+	tags         []string
+	labeler      func(t *Task) string
+	origin       any           // func given by user, which handle may wrap, see sandbox
+	affinity     affinityKey   // of dedicated goroutine running it, see Task.WithAffinity
+	flightKey    string        // see Task.WithSingleflightKey
+	label        string        // see Task.WithLabel
+	callbacks    *callbacks    // see Task.OnSuccess and Task.OnFail, nil if none
+	arrivals     []*innerNode  // tasks arriving at it as a barrier, guarded by rw, see Task.Arrive
+	arrives      []*innerNode  // barriers it arrives at
+	timeout      time.Duration // see Task.WithTimeout
+	softDeadline time.Duration // see Task.WithSoftDeadline
+	priority     TaskPriority
+	// priority raised by waiting in queue, see WithPriorityAging. Smaller is higher like priority
+	effectivePriority float64
+	panicked          atomic.Pointer[PanicError] // panic of latest run, see Task.Panic
+	rw                sync.RWMutex
+	joinCounter       utils.RC     // 入度计数器
+	state             atomic.Int32 // 任务状态
+	live              atomic.Bool  // a dependent has run, rather than been skipped, since last setup
+	reentrant         bool         // may run more than once in a run, whose handle must be retained
+	armed             bool         // join counter has been set for a run, so that late arrivals are counted, guarded by rw
+}
+
+// kNodeStateTransitions are legal changes of node state within a run, while setup resets any state to idle.
+// A finished or skipped node is waiting again in a condition loop, and a finished one fails if its timeout
+// is noticed after its handle returned.
+var kNodeStateTransitions = map[int32][]int32{
+	kNodeStateIdle:     {kNodeStateWaiting, kNodeStateSkipped},
+	kNodeStateWaiting:  {kNodeStateRunning, kNodeStateSkipped, kNodeStateFailed},
+	kNodeStateRunning:  {kNodeStateFinished, kNodeStateFailed},
+	kNodeStateFinished: {kNodeStateWaiting, kNodeStateFailed},
+	kNodeStateSkipped:  {kNodeStateWaiting},
+}
+
+// transitionState changes state of n from from to to atomically. It fails without changing state
+// if the transition is illegal, or state of n is not from.
+func (n *innerNode) transitionState(from, to int32) error {
+	if !slices.Contains(kNodeStateTransitions[from], to) {
+		return fmt.Errorf("illegal state transition of %v in graph %v: %v -> %v", n.name, graphName(n), stateNames[from], stateNames[to])
+	}
+	if !n.state.CompareAndSwap(from, to) {
+		return fmt.Errorf("state of %v in graph %v changed concurrently: %v -> %v expected, but it is %v",
+			n.name, graphName(n), stateNames[from], stateNames[to], stateNames[n.state.Load()])
+	}
+	return nil
+}
+
+// spanName returns name of span recording a run of n. It is called before panics of the run are recovered,
+// so a panicking labeler falls back to task name rather than leaving the run unfinished.
+func (n *innerNode) spanName() (name string) {
+	if n.labeler == nil {
+		return n.name
+	}
+	defer func() {
+		if recover() != nil {
+			name = n.name
+		}
+	}()
+	return n.labeler(&Task{node: n})
+}
+
+func (n *innerNode) hasTag(tags map[string]struct{}) bool {
+	for _, tag := range n.tags {
+		if _, ok := tags[tag]; ok {
+			return true
+		}
+	}
+	return false
 }
 
 func (n *innerNode) JoinCounter() int {
 	return n.joinCounter.Value()
 }
 
+// setup prepares n for a run of its graph
 func (n *innerNode) setup() {
 	n.state.Store(kNodeStateIdle)
-	for _, dep := range n.dependents {
-		if dep.Typ == nodeCondition {
-			continue
-		}
+	n.panicked.Store(nil)
+	n.rearm()
+}
 
-		n.joinCounter.Increase()
-	}
+// rearm makes n wait for its strong dependents and arrivals again, which is done after each run of n, keeping its state.
+// Join counter is set rather than increased, as a node in a condition loop is rearmed after each run
+// without being released by its strong dependents again.
+func (n *innerNode) rearm() {
+	n.live.Store(false)
+	n.rw.Lock()
+	defer n.rw.Unlock()
+	n.joinCounter.Set(n.waits())
+	n.armed = true
 }
 
-func (n *innerNode) drop() {
+// drop releases successors, returns the ones whose dependencies are all done.
+// Successors of condition are not counted, which are scheduled by choice.
+func (n *innerNode) drop() []*innerNode {
+	ready := make([]*innerNode, 0, len(n.successors))
 	// release every deps
 	for _, node := range n.successors {
 		if n.Typ != nodeCondition {
-			node.joinCounter.Decrease()
+			node.live.Store(true)
+			if n.release(node) == 0 {
+				ready = append(ready, node)
+			}
 		}
 	}
+	return ready
+}
+
+// release decreases join counter of successor succ, returns the new count
+func (n *innerNode) release(succ *innerNode) int {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(fmt.Sprintf("join counter of %v in graph %v underflows, released by %v -> %v", succ.name, succ.g.name, n.name, r))
+		}
+	}()
+	return succ.joinCounter.Decrease()
 }
 
 // set dependency： V deps on N, V is input node
+// precede adds edge n -> v. Duplicate strong edges are ignored, as each would be counted by join counter of v.
+// Duplicate edges of condition are kept, since successors of condition are indexed by branch.
 func (n *innerNode) precede(v *innerNode) {
+	if n.Typ != nodeCondition && slices.Contains(n.successors, v) {
+		return
+	}
 	n.successors = append(n.successors, v)
 	v.dependents = append(v.dependents, n)
 }
 
 func newNode(name string) *innerNode {
 	return &innerNode{
-		name:        name,
-		state:       atomic.Int32{},
-		successors:  make([]*innerNode, 0),
-		dependents:  make([]*innerNode, 0),
-		rw:          &sync.RWMutex{},
-		priority:    NORMAL,
-		joinCounter: utils.NewRC(),
+		name:       name,
+		successors: make([]*innerNode, 0),
+		dependents: make([]*innerNode, 0),
+		priority:   NORMAL,
+	}
+}
+
+// releaseHandle drops handle of a finished node so that variables captured by it can be collected.
+// Subflow releases its builder and handles of its inner nodes as well. Condition handles are always retained.
+func (n *innerNode) releaseHandle() {
+	if n.reentrant {
+		return
+	}
+	switch p := n.ptr.(type) {
+	case *Static:
+		p.handle, p.gate, n.origin = nil, nil, nil
+	case *Subflow:
+		p.handle, n.origin = nil, nil
+		for _, node := range p.g.nodes {
+			node.releaseHandle()
+		}
 	}
 }
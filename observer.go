@@ -0,0 +1,103 @@
+package gotaskflow
+
+import "time"
+
+// Observer is notified of task lifecycle in executor, register it via WithObserver.
+// Methods are called from worker goroutines concurrently, so they should be cheap and thread safe.
+type Observer interface {
+	// OnScheduled is called right before task is dispatched. Returning true skips the task,
+	// it finishes instantly without running and still releases its successors.
+	OnScheduled(task *Task) (skip bool)
+	OnStarted(task *Task)               // OnStarted is called when task starts running
+	OnFinished(task *Task, failed bool) // OnFinished is called when task finishes, failed if it panics
+}
+
+// SoftDeadlineObserver is an Observer also notified of soft deadlines, which observers registered via WithObserver
+// may implement optionally.
+type SoftDeadlineObserver interface {
+	Observer
+	// OnSoftDeadline is called once task runs longer than its soft deadline d, while it keeps running, see Task.WithSoftDeadline
+	OnSoftDeadline(task *Task, d time.Duration)
+}
+
+// BaseObserver implements Observer doing nothing, embed it to implement only the needed methods
+type BaseObserver struct{}
+
+func (BaseObserver) OnScheduled(task *Task) bool        { return false }
+func (BaseObserver) OnStarted(task *Task)               {}
+func (BaseObserver) OnFinished(task *Task, failed bool) {}
+
+// vetoed asks observers whether node should be skipped
+func (e *innerExecutorImpl) vetoed(node *innerNode) bool {
+	skip := false
+	for _, obs := range e.observers {
+		if obs.OnScheduled(&Task{node: node}) {
+			skip = true
+		}
+	}
+	return skip
+}
+
+// callbacks are attached to a single task, see Task.OnSuccess and Task.OnFail
+type callbacks struct {
+	success []func()
+	fail    []func(recovered any)
+}
+
+// finish publishes end of node, which failed by cause unless it is nil, and runs callbacks of node.
+// A panicking callback is reported and skipped, rather than failing graph of node.
+func (e *innerExecutorImpl) finish(node *innerNode, worker int, cause any) {
+	if cause != nil {
+		e.onNode(node, NodeFailed)
+	} else {
+		e.onNode(node, NodeFinished)
+	}
+	cb := node.callbacks
+	if cb == nil {
+		return
+	}
+	run := func(f func()) {
+		defer func() {
+			if r := recover(); r != nil {
+				reportRecovered(e.reporter(), "callback", node.name, node.g.name, node.g.runID.Load(), r, worker)
+			}
+		}()
+		f()
+	}
+	if cause == nil {
+		for _, f := range cb.success {
+			run(f)
+		}
+		return
+	}
+	for _, f := range cb.fail {
+		run(func() { f(cause) })
+	}
+}
+
+// onNode publishes node event to progress channel and observers
+func (e *innerExecutorImpl) onNode(node *innerNode, typ ProgressEventType) {
+	e.progress.emitNode(node, typ, e.clock.Now())
+	for _, obs := range e.observers {
+		switch typ {
+		case NodeStarted:
+			obs.OnStarted(&Task{node: node})
+		case NodeFinished, NodeFailed:
+			obs.OnFinished(&Task{node: node}, typ == NodeFailed)
+		}
+	}
+}
+
+// transition changes state of node to to, see innerNode.transitionState. An illegal transition is logged
+// and made anyway, or panics with WithStrictStateTransitions.
+func (e *innerExecutorImpl) transition(node *innerNode, to int32) {
+	err := node.transitionState(node.state.Load(), to)
+	if err == nil {
+		return
+	}
+	e.reporter().log("illegal state transition", err.Error(), "task", node.name, "graph", node.g.name, "error", err)
+	if e.strictStates {
+		panic(err.Error())
+	}
+	node.state.Store(to)
+}
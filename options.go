@@ -0,0 +1,358 @@
+package gotaskflow
+
+import (
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Option configures an Executor. Options are applied in order, so a later one wins over an earlier one of the same knob.
+// Invalid options make NewExecutor panic, or NewExecutorWithOptions return an error.
+type Option func(e *innerExecutorImpl)
+
+// WithStaticConcurrency caps how many static tasks run at once, independent of executor concurrency
+func WithStaticConcurrency(n uint) Option {
+	return withTypeConcurrency(nodeStatic, n)
+}
+
+// WithSubflowConcurrency caps how many subflow tasks run at once, independent of executor concurrency.
+// A subflow holds its slot until its inner tasks finish, so nested subflows need a cap bigger than nesting depth.
+func WithSubflowConcurrency(n uint) Option {
+	return withTypeConcurrency(nodeSubflow, n)
+}
+
+// WithConditionConcurrency caps how many condition tasks run at once, independent of executor concurrency
+func WithConditionConcurrency(n uint) Option {
+	return withTypeConcurrency(nodeCondition, n)
+}
+
+func withTypeConcurrency(typ nodeType, n uint) Option {
+	return func(e *innerExecutorImpl) {
+		if n == 0 {
+			e.invalid("task type concurrency cannot be zero")
+			return
+		}
+		e.limits[typ] = newLimiter(n)
+	}
+}
+
+// WithTagConcurrency caps how many tasks carrying tag run at once, like a semaphore named by tag shared by them,
+// independent of executor concurrency. A task carrying several capped tags takes a slot of each.
+func WithTagConcurrency(tag string, n uint) Option {
+	return func(e *innerExecutorImpl) {
+		if tag == "" || n == 0 {
+			e.invalid("tag concurrency needs a tag and a positive cap, got %q and %v", tag, n)
+			return
+		}
+		e.tagLimits[tag] = newLimiter(n)
+	}
+}
+
+// WithCoalescing runs chains of static tasks, each being the only successor of the previous one, in a single pool job.
+// It cuts dispatch overhead of tiny tasks, while spans are still recorded per task.
+// Coalesced tasks skip the work queue, so their priority and type concurrency cap are not applied.
+// Tasks carrying tags capped by WithTagConcurrency are never coalesced.
+func WithCoalescing() Option {
+	return func(e *innerExecutorImpl) {
+		e.coalesce = true
+	}
+}
+
+// WithProgressBuffer sets capacity of the channel returned by Executor.Progress
+func WithProgressBuffer(n uint) Option {
+	return func(e *innerExecutorImpl) {
+		e.progress = newProgress(n)
+	}
+}
+
+// WithObserver registers observers notified of task lifecycle, in registration order
+func WithObserver(obs ...Observer) Option {
+	return func(e *innerExecutorImpl) {
+		e.observers = append(e.observers, obs...)
+	}
+}
+
+// WithHandleRelease drops handles of tasks once they finish, so that large data captured by them can be garbage collected.
+// Tasks which may run again in a condition loop are retained. A taskflow cannot be run again after running with it.
+func WithHandleRelease() Option {
+	return func(e *innerExecutorImpl) {
+		e.releaseHandles = true
+	}
+}
+
+// WithPriorityAging makes queued tasks taken by priority rather than in order, so that priority holds across tasks
+// released at different times. A task passed over for a task of higher priority gains delta of priority,
+// so that low priority tasks are not starved: LOW catches up with a fresh HIGH after being passed over 2/delta times,
+// and goes first since it has been queued longer.
+func WithPriorityAging(delta float64) Option {
+	return func(e *innerExecutorImpl) {
+		if delta <= 0 {
+			e.invalid("priority aging delta must be positive")
+			return
+		}
+		if q := e.agingQueue(); q != nil {
+			q.delta = delta
+		}
+	}
+}
+
+// WithPriorityAgingOverTime makes queued tasks taken by priority like WithPriorityAging, a task gaining one level of
+// priority for every per it has been queued, such as LOW catching up with a fresh HIGH after 2*per.
+// It can be combined with WithPriorityAging. Time is told by the clock of executor, see WithClock.
+func WithPriorityAgingOverTime(per time.Duration) Option {
+	return func(e *innerExecutorImpl) {
+		if per <= 0 {
+			e.invalid("priority aging period must be positive")
+			return
+		}
+		if q := e.agingQueue(); q != nil {
+			q.per = per
+		}
+	}
+}
+
+// agingQueue returns work queue of priority aging, replacing the default one, nil if another queue is set
+func (e *innerExecutorImpl) agingQueue() *agingQueue {
+	switch q := e.wq.(type) {
+	case *agingQueue:
+		return q
+	case *fairQueue:
+		e.invalid("priority aging conflicts with fair scheduling")
+		return nil
+	case *customQueue:
+		e.invalid("priority aging conflicts with custom work queue")
+		return nil
+	}
+	q := newAgingQueue(0, 0)
+	e.wq = q
+	return q
+}
+
+// WithFairScheduling makes graphs sharing the executor, such as a taskflow and its subflows, take turns to
+// dispatch queued tasks, rather than in order they are queued. The graph with fewest running tasks goes first,
+// so that a subflow releasing many tasks at once cannot hold back other branches of its parent.
+// Tasks of a graph are still dispatched in order.
+func WithFairScheduling() Option {
+	return func(e *innerExecutorImpl) {
+		if _, ok := e.wq.(*agingQueue); ok {
+			e.invalid("fair scheduling conflicts with priority aging")
+			return
+		}
+		if _, ok := e.wq.(*customQueue); ok {
+			e.invalid("fair scheduling conflicts with custom work queue")
+			return
+		}
+		e.wq = newFairQueue(e.concurrency)
+	}
+}
+
+// WithTaskTracking keeps tasks being queued and running, listed by Executor.RunningTasks and QueuedTasks.
+// It is off by default, as every task then takes a shared lock when queued, started and finished.
+func WithTaskTracking() Option {
+	return func(e *innerExecutorImpl) {
+		e.activity = newActivity()
+	}
+}
+
+// WithStrictCounting turns on checks of dependency counting, for debugging a flow that hangs or runs tasks twice.
+// Scheduling a task again before it finished panics, and unbalanced counters at the end of a run are reported
+// and fail the run. Join counter underflow always panics, with the tasks involved.
+func WithStrictCounting() Option {
+	return func(e *innerExecutorImpl) {
+		e.strict = true
+	}
+}
+
+// WithStrictStateTransitions panics on illegal changes of task state, such as a running task becoming waiting,
+// for catching scheduling bugs during development. They are logged and made anyway otherwise.
+func WithStrictStateTransitions() Option {
+	return func(e *innerExecutorImpl) {
+		e.strictStates = true
+	}
+}
+
+// WithProfiling turns recording of spans and samples on or off, it is on by default.
+// Profiles are empty when it is off, which saves the cost of recording for flows never profiled.
+func WithProfiling(enabled bool) Option {
+	return func(e *innerExecutorImpl) {
+		e.profiler.disabled = !enabled
+	}
+}
+
+// WithLogger sets where executor writes recovered panics, strict counting reports and dry runs, os.Stdout by default
+func WithLogger(w io.Writer) Option {
+	return func(e *innerExecutorImpl) {
+		if w == nil {
+			e.invalid("logger cannot be nil")
+			return
+		}
+		e.logger = w
+	}
+}
+
+// WithSlog makes executor log recovered panics and diagnostics into l as structured records, instead of writing
+// them into logger, see WithLogger. l is also what tasks get by Runtime.Logger.
+func WithSlog(l *slog.Logger) Option {
+	return func(e *innerExecutorImpl) {
+		if l == nil {
+			e.invalid("slog logger cannot be nil")
+			return
+		}
+		e.slog = l
+	}
+}
+
+// WithProfileSampleInterval records which tasks are running every interval, instead of a span per task,
+// so that profiling flows of many tiny tasks is cheap. Profile writes an approximate flame graph of the samples,
+// while spans, critical path and timeline are left empty. Zero means exact spans, the default.
+func WithProfileSampleInterval(interval time.Duration) Option {
+	return func(e *innerExecutorImpl) {
+		if interval < 0 {
+			e.invalid("profile sample interval cannot be negative")
+			return
+		}
+		if interval == 0 {
+			e.sampler, e.profiler.sampling = nil, false
+			return
+		}
+		e.sampler = newSampler(interval, e.concurrency, e.profiler)
+		e.profiler.sampling = true
+	}
+}
+
+// WithProfileSampleRate records each span with probability rate, from 0 to 1, so that profiling taskflows run
+// millions of times keeps memory bound. Profiles, spans, critical path and timeline are built from the sample,
+// see ExecutorStats.ProfileSampleRate. It is 1 by default, recording every span.
+func WithProfileSampleRate(rate float64) Option {
+	return func(e *innerExecutorImpl) {
+		if !(rate >= 0 && rate <= 1) {
+			e.invalid("profile sample rate %v is out of [0, 1]", rate)
+			return
+		}
+		e.profiler.rate = rate
+	}
+}
+
+// WithAffinityThreadLock locks dedicated goroutines of task affinity to their OS threads, see Task.WithAffinity
+func WithAffinityThreadLock() Option {
+	return func(e *innerExecutorImpl) {
+		e.lockThreads = true
+	}
+}
+
+// SchedulePolicy decides which of queued tasks is dispatched first.
+// Tasks released together are always dispatched in priority order.
+type SchedulePolicy int
+
+const (
+	FIFO SchedulePolicy = iota // tasks released earlier go first, breadth-first
+	LIFO                       // tasks released later go first, which finishes one chain before starting another
+)
+
+// WithSchedulePolicy sets the order of dispatching queued tasks, FIFO by default
+func WithSchedulePolicy(p SchedulePolicy) Option {
+	return func(e *innerExecutorImpl) {
+		e.policy = p
+	}
+}
+
+// WithRunPolicy decides what Run does while executor is running another taskflow, RunAllow by default.
+// RunSerialize suits an executor shared by requests which must not interleave, and RunReject catches one shared by mistake.
+// Runs of RunAsync waiting their turn are waited for by Wait as well.
+func WithRunPolicy(p RunPolicy) Option {
+	return func(e *innerExecutorImpl) {
+		if p < RunAllow || p > RunReject {
+			e.invalid("invalid run policy %v", p)
+			return
+		}
+		e.runGate = newRunGate(p)
+	}
+}
+
+// WithStackPolicy decides how much stack is captured when a task or hook panics, for the report written into logger
+// and for PanicError. It is StackFull by default.
+func WithStackPolicy(policy StackPolicy) Option {
+	return func(e *innerExecutorImpl) {
+		if policy < StackFull {
+			e.invalid("invalid stack policy %v", policy)
+			return
+		}
+		e.stack = policy
+	}
+}
+
+// kDefaultMaxSubflowDepth bounds nesting of subflows by default, see WithMaxSubflowDepth
+const kDefaultMaxSubflowDepth = 64
+
+// WithMaxSubflowDepth bounds how deep subflows can be nested, 64 by default. A subflow nested deeper is not built:
+// it fails with ErrSubflowTooDeep and cancels the run, which stops a builder recursing endlessly by mistake.
+func WithMaxSubflowDepth(depth int) Option {
+	return func(e *innerExecutorImpl) {
+		if depth <= 0 {
+			e.invalid("max subflow depth must be positive, got %v", depth)
+			return
+		}
+		e.maxDepth = depth
+	}
+}
+
+// WithMaxQueueDepth bounds how many tasks wait in work queue, no bound by default. A task scheduled while queue
+// is full fails its run with ErrQueueFull, which stops a runaway graph before it runs out of memory.
+// Scheduling fails rather than blocks, as tasks are scheduled by the same goroutines which drain queue.
+func WithMaxQueueDepth(depth uint) Option {
+	return func(e *innerExecutorImpl) {
+		if depth == 0 {
+			e.invalid("max queue depth cannot be zero")
+			return
+		}
+		e.maxQueue = int(depth)
+	}
+}
+
+// WithDisallowedPackages rejects tasks whose handles are defined in any of pkgs or their subpackages, such as "os/exec",
+// failing their runs with ErrDisallowedPackage, for taskflows built by user code loaded as plugins.
+// It is a best-effort check of where a handle is defined, found by runtime.FuncForPC, rather than a sandbox:
+// a handle calling into a disallowed package, or wrapped by a closure of an allowed one, passes.
+func WithDisallowedPackages(pkgs ...string) Option {
+	return func(e *innerExecutorImpl) {
+		for _, pkg := range pkgs {
+			if pkg == "" {
+				e.invalid("disallowed package cannot be empty")
+				return
+			}
+		}
+		e.disallowed = append(e.disallowed, pkgs...)
+	}
+}
+
+// WithWorkQueue replaces the default FIFO work queue with q, such as a lock-free or sharded one, to try other ways
+// of ordering scheduled tasks. It conflicts with WithPriorityAging and WithFairScheduling, which bring queues of their own.
+func WithWorkQueue(q WorkQueue) Option {
+	return func(e *innerExecutorImpl) {
+		switch e.wq.(type) {
+		case *agingQueue:
+			e.invalid("custom work queue conflicts with priority aging")
+			return
+		case *fairQueue:
+			e.invalid("custom work queue conflicts with fair scheduling")
+			return
+		}
+		if q == nil {
+			e.invalid("work queue cannot be nil")
+			return
+		}
+		e.wq = newCustomQueue(q)
+	}
+}
+
+// WithClock makes executor tell time by c rather than by the real clock, such as a fake clock advanced by tests,
+// so that durations of spans, timeouts and deadlines are deterministic, see taskflowtest.FakeClock
+func WithClock(c Clock) Option {
+	return func(e *innerExecutorImpl) {
+		if c == nil {
+			e.invalid("clock cannot be nil")
+			return
+		}
+		e.clock = c
+	}
+}
@@ -0,0 +1,134 @@
+package gotaskflow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	panicMu               = &sync.Mutex{}
+	panicOutput io.Writer = os.Stdout // default logger of executor
+)
+
+// StackPolicy decides how much stack of a recovered panic is captured, see WithStackPolicy
+type StackPolicy int
+
+const (
+	StackNone StackPolicy = 0  // no stack is captured
+	StackFull StackPolicy = -1 // whole stack is captured, which is the default
+)
+
+// StackTop captures at most n frames of stack, starting from the one which panics
+func StackTop(n uint) StackPolicy {
+	return StackPolicy(n)
+}
+
+// PanicError is the failure of a task or hook which panics, keeping what is recovered and captured stack.
+// It is what RunHandle.Err wraps, and what Task.Panic returns.
+type PanicError struct {
+	Kind  string // type of task, or "hook"
+	Name  string
+	Value any    // recovered value
+	Stack string // captured by StackPolicy of executor
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v %v panic: %v", e.Kind, e.Name, e.Value)
+}
+
+// reporter is where executor reports panics and diagnostics, see WithLogger, WithSlog and WithStackPolicy
+type reporter struct {
+	w      io.Writer
+	slog   *slog.Logger // used instead of w if set
+	policy StackPolicy
+	clock  Clock
+}
+
+func (e *innerExecutorImpl) reporter() reporter {
+	return reporter{w: e.logger, slog: e.slog, policy: e.stack, clock: e.clock}
+}
+
+// log writes line tagged by tag into w, or a warning of tag with attrs into slog logger if set
+func (rep reporter) log(tag, line string, attrs ...any) {
+	if rep.slog != nil {
+		rep.slog.Warn(tag, attrs...)
+		return
+	}
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	fmt.Fprintf(rep.w, "[%s] %s\n", tag, line)
+}
+
+// reportPanic prints a recovered panic of node as one delimited block, so that concurrent reports do not interleave,
+// and retains it on node. It must be called in the deferred func recovering the panic, so that stack belongs to the panicking goroutine.
+func reportPanic(rep reporter, node *innerNode, r any, worker int) *PanicError {
+	pe := reportRecovered(rep, string(node.Typ), node.name, node.g.name, node.g.runID.Load(), r, worker)
+	node.panicked.Store(pe)
+	return pe
+}
+
+// reportRecovered prints a recovered panic, or logs it as an error with its stack if slog logger is set, see reportPanic.
+// worker is -1 if it is not run by pool
+func reportRecovered(rep reporter, kind, name, graph string, run uint64, r any, worker int) *PanicError {
+	stack := debug.Stack()
+	pe := &PanicError{Kind: kind, Name: name, Value: r, Stack: trimStack(stack, rep.policy)}
+	if rep.slog != nil {
+		rep.slog.Error("recovered panic", "kind", kind, "task", name, "graph", graph, "run", run, "worker", worker,
+			"panic", fmt.Sprint(r), "stack", pe.Stack)
+		return pe
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "===== [recovered] %s %s of graph %s, goroutine %d, worker %d, run %d, at %s =====\n",
+		kind, name, graph, goroutineID(stack), worker, run, rep.clock.Now().Format(time.RFC3339Nano))
+	fmt.Fprintf(&buf, "panic: %v\n%s", r, pe.Stack)
+	fmt.Fprintf(&buf, "===== end of %s =====\n", name)
+
+	panicMu.Lock()
+	defer panicMu.Unlock()
+	rep.w.Write(buf.Bytes())
+	return pe
+}
+
+// trimStack keeps frames of stack by policy. Frames of recovering are dropped for StackTop, which are the ones up to
+// the call of panic. Each frame takes two lines, the call and its location, following the line of goroutine.
+func trimStack(stack []byte, policy StackPolicy) string {
+	switch {
+	case policy == StackNone:
+		return ""
+	case policy < 0:
+		return string(stack)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(stack), "\n"), "\n")
+	head, frames := lines[0], lines[1:]
+	for i := 0; i+1 < len(frames); i += 2 {
+		if strings.HasPrefix(frames[i], "panic(") {
+			frames = frames[i+2:]
+			break
+		}
+	}
+	frames = frames[:min(len(frames), 2*int(policy))]
+	return strings.Join(append([]string{head}, frames...), "\n") + "\n"
+}
+
+// goroutineID parses id of goroutine from the first line of its stack, "goroutine 1 [running]:"
+func goroutineID(stack []byte) int {
+	line, _, _ := bytes.Cut(stack, []byte("\n"))
+	fields := bytes.Fields(line)
+	if len(fields) < 2 {
+		return -1
+	}
+	id, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return -1
+	}
+	return id
+}
@@ -0,0 +1,89 @@
+package gotaskflow
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReportPanic(t *testing.T) {
+	var buf bytes.Buffer
+	executor := NewExecutor(4, WithLogger(&buf))
+	tf := NewTaskFlow("G")
+	for i := 0; i < 8; i++ {
+		tf.Push(NewTask(fmt.Sprintf("task_%d", i), func() {
+			panic("boom")
+		}))
+	}
+	executor.Run(tf).Wait()
+
+	var current string
+	blocks := 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		switch {
+		case strings.HasPrefix(line, "===== [recovered]"):
+			if current != "" {
+				t.Fatalf("report of %v interleaved", current)
+			}
+			if !strings.Contains(line, "of graph G, goroutine ") || !strings.Contains(line, ", run 1, at ") {
+				t.Errorf("unexpected header %v", line)
+			}
+			current = strings.Fields(line)[3]
+		case strings.HasPrefix(line, "===== end of "):
+			if name := strings.Fields(line)[3]; name != current {
+				t.Fatalf("report of %v ended as %v", current, name)
+			}
+			current = ""
+			blocks++
+		}
+	}
+	// tasks dispatched after the first panic are canceled
+	if blocks == 0 || current != "" {
+		t.Errorf("unexpected reports %v", buf.String())
+	}
+}
+
+func TestStackPolicy(t *testing.T) {
+	for _, policy := range []StackPolicy{StackNone, StackTop(2), StackFull} {
+		var buf bytes.Buffer
+		executor := NewExecutor(4, WithLogger(&buf), WithStackPolicy(policy))
+		tf := NewTaskFlow("G")
+		task := NewTask("task", func() { panic("boom") })
+		tf.Push(task)
+		h := executor.RunAsync(tf)
+		<-h.Done()
+
+		var pe *PanicError
+		if !errors.As(h.Err(), &pe) || pe.Value != "boom" || pe.Error() != "static task panic: boom" {
+			t.Fatalf("unexpected error %v", h.Err())
+		}
+		if task.Panic() != pe {
+			t.Errorf("expected panic retained on task")
+		}
+		if !strings.Contains(buf.String(), "panic: boom\n"+pe.Stack+"===== end of task") {
+			t.Errorf("expected captured stack reported, got %v", buf.String())
+		}
+
+		lines := strings.Split(strings.TrimSpace(pe.Stack), "\n")
+		switch policy {
+		case StackNone:
+			if pe.Stack != "" {
+				t.Errorf("expected no stack, got %v", pe.Stack)
+			}
+		case StackFull:
+			if !strings.Contains(pe.Stack, "runtime/debug.Stack") {
+				t.Errorf("expected full stack, got %v", pe.Stack)
+			}
+		default:
+			if len(lines) != 5 || !strings.HasPrefix(lines[0], "goroutine ") || !strings.Contains(lines[1], "TestStackPolicy.func") {
+				t.Errorf("expected top 2 frames from panicking call, got %v", pe.Stack)
+			}
+		}
+	}
+
+	if _, err := NewExecutorWithOptions(4, WithStackPolicy(-2)); err == nil {
+		t.Error("expected invalid stack policy")
+	}
+}
@@ -0,0 +1,50 @@
+package gotaskflow
+
+import "sync"
+
+// loops keeps graphs whose scheduling loop is running, so that Resume can wake them up
+type loops struct {
+	graphs map[*eGraph]struct{}
+	mu     *sync.Mutex
+}
+
+func newLoops() *loops {
+	return &loops{graphs: make(map[*eGraph]struct{}), mu: &sync.Mutex{}}
+}
+
+func (l *loops) enter(g *eGraph) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.graphs[g] = struct{}{}
+}
+
+func (l *loops) leave(g *eGraph) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.graphs, g)
+}
+
+// wake wakes up loops waiting on their condition, holding its lock so that no loop misses it between check and wait
+func (l *loops) wake() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for g := range l.graphs {
+		g.scheCond.L.Lock()
+		g.scheCond.Broadcast()
+		g.scheCond.L.Unlock()
+	}
+}
+
+// Pause stops dispatching queued tasks of all taskflows, including ones run later, until Resume. Running tasks
+// finish, and tasks they release are queued rather than run, so a paused run keeps its progress. Nothing is canceled,
+// and a taskflow canceled meanwhile drains its queued tasks once resumed.
+func (e *innerExecutorImpl) Pause() {
+	e.paused.Store(true)
+}
+
+// Resume dispatches queued tasks again after Pause
+func (e *innerExecutorImpl) Resume() {
+	if e.paused.CompareAndSwap(true, false) {
+		e.loops.wake()
+	}
+}
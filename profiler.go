@@ -1,8 +1,11 @@
 package gotaskflow
 
 import (
+	"cmp"
 	"fmt"
 	"io"
+	"math/rand"
+	"slices"
 	"sync"
 	"time"
 
@@ -10,30 +13,81 @@ import (
 )
 
 type profiler struct {
-	spans map[attr]*span
+	spans    map[attr]*span
+	samples  map[sampleKey]time.Duration // sampled time of each task path
+	sampling bool                        // spans are not recorded, see WithProfileSampleInterval
+	disabled bool                        // neither spans nor samples are recorded, see WithProfiling
+	rate     float64                     // fraction of spans recorded, see WithProfileSampleRate
+	rnd      *rand.Rand                  // decides which spans are recorded, guarded by mu
 
 	mu *sync.Mutex
 }
 
+type sampleKey struct {
+	run   uint64
+	path  string
+	label string // of sampled task, see Task.WithLabel
+}
+
 func newProfiler() *profiler {
 	return &profiler{
-		spans: make(map[attr]*span),
-		mu:    &sync.Mutex{},
+		spans:   make(map[attr]*span),
+		samples: make(map[sampleKey]time.Duration),
+		rate:    1,
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		mu:      &sync.Mutex{},
 	}
 }
 
 func (t *profiler) AddSpan(s *span) {
+	if t.sampling || t.disabled {
+		return
+	}
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	if t.rate < 1 && t.rnd.Float64() >= t.rate {
+		return
+	}
 	if span, ok := t.spans[s.extra]; ok {
 		s.cost += span.cost
+		s.overDeadline = s.overDeadline || span.overDeadline
 	}
 	t.spans[s.extra] = s
 }
 
+// addSample records that n is seen running for d, under the path of subflows holding it
+func (t *profiler) addSample(n *innerNode, d time.Duration) {
+	if t.disabled {
+		return
+	}
+	path := fmt.Sprintf("%s,%s", n.Typ, n.name)
+	for g := n.g; g.parent != nil; g = g.parent {
+		path = fmt.Sprintf("%s,%s;%s", nodeSubflow, g.name, path)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples[sampleKey{run: n.g.runID.Load(), path: path, label: n.label}] += d
+}
+
+// flagOverDeadline flags recorded span s, see span.overDeadline
+func (t *profiler) flagOverDeadline(s *span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s.overDeadline = true
+}
+
+// reset drops all recorded spans and samples
+func (t *profiler) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = make(map[attr]*span)
+	t.samples = make(map[sampleKey]time.Duration)
+}
+
 type attr struct {
 	typ  nodeType
 	name string
+	run  uint64 // id of run, so that runs are recorded apart
 }
 
 type span struct {
@@ -41,18 +95,61 @@ type span struct {
 	begin  time.Time
 	cost   time.Duration
 	parent *span
+	node   *innerNode
+	worker int    // id of pool worker running the node
+	label  string // of node when it ran, see Task.WithLabel
+	// node ran over its soft deadline, in any of the runs merged into it, see Task.WithSoftDeadline
+	overDeadline bool
 }
 
 func (s *span) String() string {
 	return fmt.Sprintf("%s,%s,cost %v", s.extra.typ, s.extra.name, utils.NormalizeDuration(s.cost))
 }
 
-func (t *profiler) draw(w io.Writer) error {
+// spansOf returns recorded spans of runs, or all of them if runs is empty
+func (t *profiler) spansOf(runs []uint64) []*span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]*span, 0, len(t.spans))
+	for _, s := range t.spans {
+		if len(runs) == 0 || slices.Contains(runs, s.extra.run) {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// recorded reports whether any span or sample of run is recorded
+func (t *profiler) recorded(run uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for a := range t.spans {
+		if a.run == run {
+			return true
+		}
+	}
+	for k := range t.samples {
+		if k.run == run {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *profiler) draw(w io.Writer, runs ...uint64) error {
+	return t.drawFiltered(w, nil, runs)
+}
+
+// drawFiltered writes spans and samples of tasks labeled by any of labels, or of all tasks if labels is empty.
+// Subflows holding them are kept in their paths whatever labels subflows have.
+func (t *profiler) drawFiltered(w io.Writer, labels []string, runs []uint64) error {
 	// compact spans base on name
+	spans := t.spansOf(runs)
+	sortSpans(spans)
 
-	for _, s := range t.spans {
+	for _, s := range spans {
 		path := ""
-		if s.extra.typ != nodeSubflow {
+		if s.extra.typ != nodeSubflow && labeled(s.label, labels) {
 			path = s.String()
 			cur := s
 
@@ -68,5 +165,61 @@ func (t *profiler) draw(w io.Writer) error {
 		}
 
 	}
+	return t.drawSamples(w, labels, runs)
+}
+
+// labeled reports whether label is one of labels, or labels is empty
+func labeled(label string, labels []string) bool {
+	return len(labels) == 0 || slices.Contains(labels, label)
+}
+
+// drawSamples writes sampled paths of runs, or of all runs if runs is empty, in the format of spans.
+// Only samples of tasks labeled by any of labels are written, unless labels is empty.
+func (t *profiler) drawSamples(w io.Writer, labels []string, runs []uint64) error {
+	t.mu.Lock()
+	keys := make([]sampleKey, 0, len(t.samples))
+	for k := range t.samples {
+		if (len(runs) == 0 || slices.Contains(runs, k.run)) && labeled(k.label, labels) {
+			keys = append(keys, k)
+		}
+	}
+	costs := make([]time.Duration, 0, len(keys))
+	slices.SortFunc(keys, func(a, b sampleKey) int {
+		if c := cmp.Compare(a.path, b.path); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.run, b.run)
+	})
+	for _, k := range keys {
+		costs = append(costs, t.samples[k])
+	}
+	t.mu.Unlock()
+
+	for i, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s %v\n", k.path, costs[i].Microseconds()); err != nil {
+			return fmt.Errorf("write profile -> %w", err)
+		}
+	}
 	return nil
 }
+
+// sortSpans orders spans by begin time, then by depth and name, so that output is stable for the same spans
+func sortSpans(spans []*span) {
+	slices.SortFunc(spans, func(a, b *span) int {
+		if c := a.begin.Compare(b.begin); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.depth(), b.depth()); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.extra.name, b.extra.name)
+	})
+}
+
+func (s *span) depth() int {
+	d := 0
+	for cur := s.parent; cur != nil; cur = cur.parent {
+		d++
+	}
+	return d
+}
@@ -2,6 +2,8 @@ package gotaskflow
 
 import (
 	"bytes"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -90,3 +92,116 @@ func TestProfilerDraw(t *testing.T) {
 		t.Errorf("expected output: %v\ngot: %v", expectedOutput, output)
 	}
 }
+
+func TestProfilerCriticalPath(t *testing.T) {
+	profiler := newProfiler()
+	now := time.Now()
+	g := newGraph("G")
+	A, B, C, D, S, S1 := newNode("A"), newNode("B"), newNode("C"), newNode("D"), builder.NewSubflow("S", nil), newNode("S1")
+	A.precede(B)
+	A.precede(C)
+	B.precede(D)
+	C.precede(D)
+	D.precede(S)
+	g.push(A, B, C, D, S)
+	S.ptr.(*Subflow).g.push(S1)
+
+	add := func(n *innerNode, begin, cost time.Duration, parent *span) *span {
+		s := &span{extra: attr{typ: nodeStatic, name: n.name}, begin: now.Add(begin), cost: cost, parent: parent, node: n}
+		profiler.AddSpan(s)
+		return s
+	}
+	add(A, 0, time.Millisecond, nil)
+	add(B, time.Millisecond, 10*time.Millisecond, nil)
+	add(C, time.Millisecond, 2*time.Millisecond, nil)
+	add(D, 11*time.Millisecond, time.Millisecond, nil)
+	sub := add(S, 12*time.Millisecond, time.Millisecond, nil)
+	add(S1, 13*time.Millisecond, 5*time.Millisecond, sub)
+
+	names := func(infos []SpanInfo) []string {
+		res := make([]string, 0, len(infos))
+		for _, info := range infos {
+			res = append(res, info.Name)
+		}
+		return res
+	}
+
+	if got := names(profiler.criticalPath(true)); !slices.Equal(got, []string{"A", "B", "D", "S", "S1"}) {
+		t.Errorf("unexpected critical path %v", got)
+	}
+	if got := names(profiler.criticalPath(false)); !slices.Equal(got, []string{"A", "B", "D", "S"}) {
+		t.Errorf("unexpected critical path %v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCriticalPath(&buf, profiler.criticalPath(true)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), "total 18ms\n") {
+		t.Errorf("unexpected output %v", buf.String())
+	}
+}
+
+func TestProfilerTimeline(t *testing.T) {
+	profiler := newProfiler()
+	now := time.Now()
+	add := func(name string, worker int, begin, cost time.Duration) {
+		profiler.AddSpan(&span{extra: attr{typ: nodeStatic, name: name}, begin: now.Add(begin), cost: cost, worker: worker})
+	}
+	add("A", 0, 0, 40*time.Millisecond)
+	add("B", 1, 0, 20*time.Millisecond)
+	add("C", 0, 40*time.Millisecond, 40*time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := profiler.timeline(&buf, TimelineText); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "timeline 80ms, 2 workers\n" +
+		"worker 0   |" + strings.Repeat("█", 80) + "|\n" +
+		"  static,A +0ns cost 40ms\n" +
+		"  static,C +40ms cost 40ms\n" +
+		"worker 1   |" + strings.Repeat("█", 20) + strings.Repeat("·", 60) + "|\n" +
+		"  static,B +0ns cost 20ms\n"
+	if buf.String() != expected {
+		t.Errorf("expected output:\n%v\ngot:\n%v", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := profiler.timeline(&buf, TimelineSVG); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "<svg") || strings.Count(buf.String(), "<rect") != 3 {
+		t.Errorf("unexpected svg %v", buf.String())
+	}
+}
+
+func TestProfilerTimelineByLayer(t *testing.T) {
+	g := newGraph("G")
+	A, B, C := builder.NewStatic("A", func() {}), builder.NewStatic("B", func() {}), builder.NewStatic("C", func() {})
+	A.precede(B)
+	A.precede(C)
+	g.push(A, B, C)
+
+	profiler := newProfiler()
+	now := time.Now()
+	add := func(node *innerNode, worker int, begin, cost time.Duration) {
+		profiler.AddSpan(&span{extra: attr{typ: nodeStatic, name: node.name}, begin: now.Add(begin), cost: cost, worker: worker, node: node})
+	}
+	add(A, 0, 0, 40*time.Millisecond)
+	add(B, 0, 40*time.Millisecond, 40*time.Millisecond)
+	add(C, 1, 40*time.Millisecond, 20*time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := profiler.timeline(&buf, TimelineTextByLayer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "timeline 80ms, 2 layers\n" +
+		"layer 0   |" + strings.Repeat("█", 40) + strings.Repeat("·", 40) + "|\n" +
+		"  static,A +0ns cost 40ms\n" +
+		"layer 1   |" + strings.Repeat("·", 40) + strings.Repeat("█", 40) + "|\n" +
+		"  static,B +40ms cost 40ms\n" +
+		"  static,C +40ms cost 20ms\n"
+	if buf.String() != expected {
+		t.Errorf("expected output:\n%v\ngot:\n%v", expected, buf.String())
+	}
+}
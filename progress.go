@@ -0,0 +1,92 @@
+package gotaskflow
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const kDefaultProgressBuffer = 1024
+
+// ProgressEventType is the kind of a progress event
+type ProgressEventType int
+
+const (
+	NodeStarted   ProgressEventType = iota // task starts running
+	NodeFinished                           // task finishes
+	NodeFailed                             // task panics
+	GraphComplete                          // all scheduled tasks of taskflow or subflow are done
+)
+
+func (t ProgressEventType) String() string {
+	switch t {
+	case NodeStarted:
+		return "started"
+	case NodeFinished:
+		return "finished"
+	case NodeFailed:
+		return "failed"
+	case GraphComplete:
+		return "complete"
+	default:
+		return "unknown"
+	}
+}
+
+// ProgressEvent reports progress of a task or a graph, NodeName is empty for GraphComplete
+type ProgressEvent struct {
+	NodeName  string
+	GraphName string
+	Event     ProgressEventType
+	Timestamp time.Time
+	RunID     uint64
+}
+
+// progress publishes events to a channel created on first subscription, without ever blocking tasks
+type progress struct {
+	capacity uint
+	ch       atomic.Pointer[chan ProgressEvent]
+	once     *sync.Once
+	dropped  atomic.Uint64
+}
+
+func newProgress(capacity uint) *progress {
+	return &progress{
+		capacity: capacity,
+		once:     &sync.Once{},
+	}
+}
+
+func (p *progress) channel() <-chan ProgressEvent {
+	p.once.Do(func() {
+		ch := make(chan ProgressEvent, p.capacity)
+		p.ch.Store(&ch)
+	})
+	return *p.ch.Load()
+}
+
+func (p *progress) emit(ev ProgressEvent) {
+	ch := p.ch.Load()
+	if ch == nil {
+		return
+	}
+	select {
+	case *ch <- ev:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+func (p *progress) emitNode(node *innerNode, typ ProgressEventType, at time.Time) {
+	if p.ch.Load() == nil {
+		return
+	}
+	p.emit(ProgressEvent{NodeName: node.name, GraphName: node.g.name, Event: typ, Timestamp: at, RunID: node.g.runID.Load()})
+}
+
+func (p *progress) emitGraph(g *eGraph, at time.Time) {
+	if p.ch.Load() == nil {
+		return
+	}
+	p.emit(ProgressEvent{GraphName: g.name, Event: GraphComplete, Timestamp: at, RunID: g.runID.Load()})
+}
@@ -0,0 +1,200 @@
+package gotaskflow
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	taskflowpb "github.com/noneback/go-taskflow/proto/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+//go:generate protoc -I proto --go_out=. --go_opt=module=github.com/noneback/go-taskflow proto/taskflow.proto
+
+// kProtoVersion is the version of proto/taskflow.proto schema
+const kProtoVersion = 1
+
+// Registry provides handles of tasks by name when unmarshaling taskflow, as handles cannot be serialized
+type Registry struct {
+	Statics          map[string]func()
+	Conditions       map[string]func() uint
+	StringConditions map[string]func() string
+	Subflows         map[string]func(sf *Subflow)
+}
+
+// MarshalProto serializes topology of taskflow in the format of proto/taskflow.proto.
+// Handles are not serialized, see UnmarshalProto.
+func (tf *TaskFlow) MarshalProto() ([]byte, error) {
+	pb, err := tf.graph.toProto()
+	if err != nil {
+		return nil, fmt.Errorf("marshal %v -> %w", tf.name, err)
+	}
+	b, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %v -> %w", tf.name, err)
+	}
+	return b, nil
+}
+
+// UnmarshalProto replaces tasks of taskflow with the ones serialized by MarshalProto, taking handles from registry by task name.
+// Serialized subflow graphs are informational, subflows are built by their handles when run.
+func (tf *TaskFlow) UnmarshalProto(data []byte, registry Registry) error {
+	pb := &taskflowpb.Graph{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		return fmt.Errorf("unmarshal -> %w", err)
+	}
+	if pb.Version != kProtoVersion {
+		return fmt.Errorf("unmarshal -> unsupported version %v", pb.Version)
+	}
+
+	g, err := graphFromProto(pb, registry)
+	if err != nil {
+		return fmt.Errorf("unmarshal %v -> %w", pb.Name, err)
+	}
+	tf.name, tf.graph = pb.Name, g
+	return nil
+}
+
+func (g *eGraph) toProto() (*taskflowpb.Graph, error) {
+	pb := &taskflowpb.Graph{Version: kProtoVersion, Name: g.name}
+	index := make(map[*innerNode]uint32, len(g.nodes))
+	for i, n := range g.nodes {
+		index[n] = uint32(i)
+	}
+
+	for _, n := range g.nodes {
+		node := &taskflowpb.Node{Name: n.name, Priority: uint32(n.priority), Tags: n.tags}
+		switch p := n.ptr.(type) {
+		case *Static:
+			node.Type = taskflowpb.NodeType_NODE_TYPE_STATIC
+		case *Condition:
+			node.Type = taskflowpb.NodeType_NODE_TYPE_CONDITION
+			if p.keyHandle != nil {
+				node.Type = taskflowpb.NodeType_NODE_TYPE_STRING_CONDITION
+			}
+		case *Subflow:
+			node.Type = taskflowpb.NodeType_NODE_TYPE_SUBFLOW
+			if p.g.instancelized {
+				sub, err := p.g.toProto()
+				if err != nil {
+					return nil, fmt.Errorf("subflow %v -> %w", n.name, err)
+				}
+				node.Subflow = sub
+			}
+		}
+		pb.Nodes = append(pb.Nodes, node)
+	}
+
+	for _, n := range g.nodes {
+		from := index[n]
+		edges := make([]*taskflowpb.Edge, 0, len(n.successors))
+		to := func(succ *innerNode) (uint32, error) {
+			i, ok := index[succ]
+			if !ok {
+				return 0, fmt.Errorf("successor %v of %v is not in graph", succ.name, n.name)
+			}
+			return i, nil
+		}
+
+		cond, ok := n.ptr.(*Condition)
+		switch {
+		case !ok:
+			for _, succ := range n.successors {
+				i, err := to(succ)
+				if err != nil {
+					return nil, err
+				}
+				edges = append(edges, &taskflowpb.Edge{From: from, To: i})
+			}
+		case cond.keyHandle != nil:
+			for key, succ := range cond.stringMapper {
+				i, err := to(succ)
+				if err != nil {
+					return nil, err
+				}
+				edges = append(edges, &taskflowpb.Edge{From: from, To: i, Conditional: true, Key: key})
+			}
+		default:
+			for branch, succ := range cond.mapper {
+				i, err := to(succ)
+				if err != nil {
+					return nil, err
+				}
+				edges = append(edges, &taskflowpb.Edge{From: from, To: i, Conditional: true, Branch: uint32(branch)})
+			}
+		}
+		// mappers are unordered
+		slices.SortFunc(edges, func(a, b *taskflowpb.Edge) int {
+			if c := cmp.Compare(a.Branch, b.Branch); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.Key, b.Key)
+		})
+		pb.Edges = append(pb.Edges, edges...)
+	}
+	return pb, nil
+}
+
+func graphFromProto(pb *taskflowpb.Graph, registry Registry) (*eGraph, error) {
+	g := newGraph(pb.Name)
+	nodes := make([]*innerNode, 0, len(pb.Nodes))
+	for _, n := range pb.Nodes {
+		name := n.Name
+		if _, ok := g.FindNode(name); ok {
+			return nil, fmt.Errorf("duplicate task %v", name)
+		}
+		var node *innerNode
+		switch n.Type {
+		case taskflowpb.NodeType_NODE_TYPE_STATIC:
+			f, ok := registry.Statics[name]
+			if !ok {
+				return nil, fmt.Errorf("no static handle of %v", name)
+			}
+			node = builder.NewStatic(name, f)
+		case taskflowpb.NodeType_NODE_TYPE_CONDITION:
+			f, ok := registry.Conditions[name]
+			if !ok {
+				return nil, fmt.Errorf("no condition handle of %v", name)
+			}
+			node = builder.NewCondition(name, f)
+		case taskflowpb.NodeType_NODE_TYPE_STRING_CONDITION:
+			f, ok := registry.StringConditions[name]
+			if !ok {
+				return nil, fmt.Errorf("no string condition handle of %v", name)
+			}
+			node = builder.NewStringCondition(name, f)
+		case taskflowpb.NodeType_NODE_TYPE_SUBFLOW:
+			f, ok := registry.Subflows[name]
+			if !ok {
+				return nil, fmt.Errorf("no subflow handle of %v", name)
+			}
+			node = builder.NewSubflow(name, f)
+		default:
+			return nil, fmt.Errorf("unknown type %v of %v", n.Type, name)
+		}
+		node.priority = TaskPriority(n.Priority)
+		node.tags = n.Tags
+		g.push(node)
+		nodes = append(nodes, node)
+	}
+
+	for _, e := range pb.Edges {
+		if e.From >= uint32(len(nodes)) || e.To >= uint32(len(nodes)) {
+			return nil, fmt.Errorf("edge %v -> %v out of range", e.From, e.To)
+		}
+		from, to := nodes[e.From], nodes[e.To]
+		cond, ok := from.ptr.(*Condition)
+		if ok != e.Conditional {
+			return nil, fmt.Errorf("edge %v -> %v mismatches type of %v", from.name, to.name, from.name)
+		}
+		if ok {
+			if cond.keyHandle != nil {
+				cond.stringMapper[e.Key] = to
+			} else {
+				cond.mapper[uint(e.Branch)] = to
+			}
+		}
+		from.precede(to)
+	}
+	return g, nil
+}
@@ -0,0 +1,89 @@
+package gotaskflow
+
+import "slices"
+
+// Reverse returns a new taskflow with the same tasks but every dependency flipped, for reverse dependency analysis
+// such as Descendants of a task in the reversed one being the tasks it depends on. It is not meant for execution.
+// Subflows are not reversed.
+func (tf *TaskFlow) Reverse() *TaskFlow {
+	reversed := NewTaskFlow(tf.name)
+	clones := make(map[*innerNode]*innerNode, len(tf.graph.nodes))
+	for _, n := range tf.graph.nodes {
+		clone := newNode(n.name)
+		clone.Typ, clone.ptr, clone.priority, clone.tags = n.Typ, n.ptr, n.priority, slices.Clone(n.tags)
+		clones[n] = clone
+		reversed.graph.push(clone)
+	}
+
+	for _, n := range tf.graph.nodes {
+		for _, succ := range n.successors {
+			if clone, ok := clones[succ]; ok {
+				clone.successors = append(clone.successors, clones[n])
+				clones[n].dependents = append(clones[n].dependents, clone)
+			}
+		}
+	}
+	return reversed
+}
+
+// Descendants returns names of tasks reachable from task name, nearest first
+func (tf *TaskFlow) Descendants(name string) []string {
+	start, ok := tf.graph.FindNode(name)
+	if !ok {
+		return nil
+	}
+
+	res := make([]string, 0)
+	visited := map[*innerNode]struct{}{start: {}}
+	queue := []*innerNode{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, succ := range n.successors {
+			if _, ok := visited[succ]; !ok {
+				visited[succ] = struct{}{}
+				res = append(res, succ.name)
+				queue = append(queue, succ)
+			}
+		}
+	}
+	return res
+}
+
+// ShortestPath returns names of tasks on a shortest path from task from to task to, both included.
+// It returns nil if to is unreachable.
+func (tf *TaskFlow) ShortestPath(from, to string) []string {
+	start, ok := tf.graph.FindNode(from)
+	end, found := tf.graph.FindNode(to)
+	if !ok || !found {
+		return nil
+	}
+
+	prev := map[*innerNode]*innerNode{start: nil}
+	queue := []*innerNode{start}
+	for len(queue) > 0 && !containsKey(prev, end) {
+		n := queue[0]
+		queue = queue[1:]
+		for _, succ := range n.successors {
+			if !containsKey(prev, succ) {
+				prev[succ] = n
+				queue = append(queue, succ)
+			}
+		}
+	}
+	if !containsKey(prev, end) {
+		return nil
+	}
+
+	path := make([]string, 0)
+	for cur := end; cur != nil; cur = prev[cur] {
+		path = append(path, cur.name)
+	}
+	slices.Reverse(path)
+	return path
+}
+
+func containsKey[K comparable, V any](m map[K]V, k K) bool {
+	_, ok := m[k]
+	return ok
+}
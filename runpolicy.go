@@ -0,0 +1,67 @@
+package gotaskflow
+
+import (
+	"errors"
+	"sync"
+)
+
+// RunPolicy decides what Run does while executor is running another taskflow, see WithRunPolicy
+type RunPolicy int
+
+const (
+	RunAllow     RunPolicy = iota // taskflows run at once, sharing work queue and workers
+	RunSerialize                  // a taskflow waits until taskflows run ahead of it finish, in order Run is called
+	RunReject                     // a taskflow fails with ErrExecutorBusy without running
+)
+
+// ErrExecutorBusy is reported by RunHandle if a taskflow is run while executor runs another one, see RunReject
+var ErrExecutorBusy = errors.New("executor is running another taskflow")
+
+// runGate admits top level runs by RunPolicy. Serialized runs wait in a queue, each woken by the run ahead of it.
+type runGate struct {
+	policy  RunPolicy
+	running bool
+	pending []chan struct{}
+	mu      *sync.Mutex
+}
+
+func newRunGate(policy RunPolicy) *runGate {
+	return &runGate{policy: policy, pending: make([]chan struct{}, 0), mu: &sync.Mutex{}}
+}
+
+// enter waits for turn of a run, or fails with ErrExecutorBusy. leave must be called once the admitted run finishes
+func (r *runGate) enter() error {
+	if r.policy == RunAllow {
+		return nil
+	}
+	r.mu.Lock()
+	if !r.running {
+		r.running = true
+		r.mu.Unlock()
+		return nil
+	}
+	if r.policy == RunReject {
+		r.mu.Unlock()
+		return ErrExecutorBusy
+	}
+	turn := make(chan struct{})
+	r.pending = append(r.pending, turn)
+	r.mu.Unlock()
+	<-turn
+	return nil
+}
+
+// leave hands the gate over to the earliest pending run
+func (r *runGate) leave() {
+	if r.policy == RunAllow {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) == 0 {
+		r.running = false
+		return
+	}
+	close(r.pending[0])
+	r.pending = r.pending[1:]
+}
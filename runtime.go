@@ -0,0 +1,78 @@
+package gotaskflow
+
+import "log/slog"
+
+var stateNames = map[int32]string{
+	kNodeStateIdle:     "idle",
+	kNodeStateWaiting:  "waiting",
+	kNodeStateRunning:  "running",
+	kNodeStateFinished: "finished",
+	kNodeStateFailed:   "failed",
+	kNodeStateSkipped:  "skipped",
+}
+
+// Runtime lets a running handle access state of the run it belongs to
+type Runtime struct {
+	node *innerNode
+}
+
+// TaskControl is the Runtime passed to handles of controlled tasks
+type TaskControl struct {
+	*Runtime
+}
+
+// Name returns name of running task
+func (rt *Runtime) Name() string {
+	return rt.node.name
+}
+
+// RunID returns id of the run, see WithRunID
+func (rt *Runtime) RunID() uint64 {
+	return rt.node.g.runID.Load()
+}
+
+// Canceled reports whether the taskflow running task, or a subflow holding it, is canceled by Cancel or a panic.
+// Long running handles should poll it and return early, or wait on Context.
+func (rt *Runtime) Canceled() bool {
+	return rt.node.g.isCanceled()
+}
+
+// Set stores value under key in the store of current run, shared by all tasks of taskflow including ones in subflows.
+// The store is cleared when taskflow runs again.
+func (rt *Runtime) Set(key string, value any) {
+	rt.node.g.root().store.Load().Store(key, value)
+}
+
+// Get loads value stored under key by Set in current run
+func (rt *Runtime) Get(key string) (any, bool) {
+	return rt.node.g.root().store.Load().Load(key)
+}
+
+// Logger returns logger of executor set by WithSlog, or slog.Default if none, with attributes of running task:
+// its name, the graph holding it and the run id, so that logs of tasks are correlated without each handle adding them
+func (rt *Runtime) Logger() *slog.Logger {
+	l := rt.node.g.root().slog
+	if l == nil {
+		l = slog.Default()
+	}
+	return l.With("task", rt.node.name, "graph", rt.node.g.name, "run", rt.node.g.runID.Load())
+}
+
+// DependentStates returns states of tasks running task depends on by name,
+// one of idle, waiting, running, finished, failed or skipped
+func (rt *Runtime) DependentStates() map[string]string {
+	states := make(map[string]string, len(rt.node.dependents))
+	for _, dep := range rt.node.dependents {
+		states[dep.name] = stateNames[dep.state.Load()]
+	}
+	return states
+}
+
+// root returns the graph of taskflow which g is nested in, g itself if it is not a subflow
+func (g *eGraph) root() *eGraph {
+	cur := g
+	for cur.parent != nil {
+		cur = cur.parent
+	}
+	return cur
+}
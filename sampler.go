@@ -0,0 +1,87 @@
+package gotaskflow
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sampler records tasks running on pool workers periodically, instead of a span per task.
+// See WithProfileSampleInterval.
+type sampler struct {
+	interval time.Duration
+	running  []atomic.Pointer[innerNode] // task of each pool worker, nil if idle
+	profiler *profiler
+	mu       *sync.Mutex
+	flows    int // running taskflows, sampling stops when none
+	stop     chan struct{}
+}
+
+func newSampler(interval time.Duration, concurrency uint, p *profiler) *sampler {
+	return &sampler{
+		interval: interval,
+		running:  make([]atomic.Pointer[innerNode], concurrency),
+		profiler: p,
+		mu:       &sync.Mutex{},
+	}
+}
+
+// start begins sampling unless a taskflow is already running
+func (s *sampler) start() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flows++
+	if s.flows == 1 {
+		s.stop = make(chan struct{})
+		go s.loop(s.stop)
+	}
+}
+
+// done stops sampling once no taskflow is running
+func (s *sampler) done() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flows--
+	if s.flows == 0 {
+		close(s.stop)
+	}
+}
+
+func (s *sampler) loop(stop chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+func (s *sampler) sample() {
+	for i := range s.running {
+		if n := s.running[i].Load(); n != nil {
+			s.profiler.addSample(n, s.interval)
+		}
+	}
+}
+
+func (s *sampler) enter(worker int, n *innerNode) {
+	if s != nil && worker >= 0 && worker < len(s.running) {
+		s.running[worker].Store(n)
+	}
+}
+
+func (s *sampler) leave(worker int) {
+	if s != nil && worker >= 0 && worker < len(s.running) {
+		s.running[worker].Store(nil)
+	}
+}
@@ -0,0 +1,68 @@
+package gotaskflow
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// ErrDisallowedPackage is reported by RunHandle if a task handle is defined in a disallowed package, see WithDisallowedPackages
+var ErrDisallowedPackage = errors.New("task handle defined in disallowed package")
+
+// handles returns funcs given by user that node runs: its handle and gate, builder of subflow, or predict func
+// of condition. Constructors like NewControlledTask wrap handle in a closure of this package, so origin is used.
+func (n *innerNode) handles() []any {
+	if p, ok := n.ptr.(*Static); ok {
+		return []any{n.origin, p.gate}
+	}
+	return []any{n.origin}
+}
+
+// funcPackage returns path of the package defining f, such as "os/exec" of exec.Command
+func funcPackage(f any) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+	// github.com/a/b.(*T).M.func1: package ends at the first dot after the last slash
+	name := fn.Name()
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+// sandbox checks that handles of node are not defined in disallowed packages, see WithDisallowedPackages
+func (e *innerExecutorImpl) sandbox(node *innerNode) error {
+	if len(e.disallowed) == 0 {
+		return nil
+	}
+	for _, h := range node.handles() {
+		pkg := funcPackage(h)
+		for _, d := range e.disallowed {
+			if pkg == d || strings.HasPrefix(pkg, d+"/") {
+				return fmt.Errorf("%v %v is defined in package %v -> %w", node.Typ, node.name, pkg, ErrDisallowedPackage)
+			}
+		}
+	}
+	return nil
+}
+
+// reject fails node without running it, releasing its successors like a failed task
+func (e *innerExecutorImpl) reject(node *innerNode, err error) {
+	node.g.fail(err)
+	e.transition(node, kNodeStateFailed)
+	e.finish(node, -1, err)
+	e.sche_successors(node, node.drop())
+	e.arrive(node)
+	node.g.joinCounter.Decrease()
+	e.wg.Done()
+	node.g.scheCond.Signal()
+}
@@ -0,0 +1,431 @@
+package gotaskflow
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+)
+
+// kSimMaxSteps bounds tasks run in a simulated run by default, so that a loop always taken back ends
+const kSimMaxSteps = 1 << 16
+
+// WithProbabilities declares how likely condition task takes each of its branches, indexed like return values
+// of its predict func, or like its keys in sorted order for a string condition. Simulated runs take branches by them
+// instead of calling predict func, see TaskFlow.Simulate; branches are taken uniformly otherwise.
+// Weights need not sum to 1. It panics if task is not a condition, or weights are negative or all zero.
+func (t *Task) WithProbabilities(p []float64) *Task {
+	cond, ok := t.node.ptr.(*Condition)
+	if !ok {
+		panic(fmt.Sprintf("task %v is not a condition", t.node.name))
+	}
+	sum := 0.0
+	for _, w := range p {
+		if w < 0 {
+			panic(fmt.Sprintf("probability of condition %v cannot be negative, got %v", t.node.name, p))
+		}
+		sum += w
+	}
+	if sum == 0 {
+		panic(fmt.Sprintf("probabilities of condition %v sum to zero", t.node.name))
+	}
+	cond.probabilities = slices.Clone(p)
+	return t
+}
+
+// SimOption configures simulated runs, see TaskFlow.Simulate
+type SimOption func(opts *simOptions)
+
+type simOptions struct {
+	seed     int64
+	cost     func(t *Task) time.Duration
+	oracle   func(cond *Task, visit int) int
+	maxSteps int
+}
+
+// WithSimSeed seeds the random source picking branches by probabilities, 0 by default, so that reports are reproducible
+func WithSimSeed(seed int64) SimOption {
+	return func(opts *simOptions) {
+		opts.seed = seed
+	}
+}
+
+// WithSimCost estimates cost of a task run. By default a task costs as much as in the latest real run, 0 if it did not run.
+func WithSimCost(cost func(t *Task) time.Duration) SimOption {
+	return func(opts *simOptions) {
+		opts.cost = cost
+	}
+}
+
+// WithBranchOracle picks branches of conditions in simulated runs, overriding probabilities.
+// visit counts earlier runs of cond in the same simulated run, so that loops can be driven,
+// and a negative return falls back to probabilities.
+func WithBranchOracle(oracle func(cond *Task, visit int) int) SimOption {
+	return func(opts *simOptions) {
+		opts.oracle = oracle
+	}
+}
+
+// WithSimMaxSteps bounds tasks run in a simulated run, which fails once exceeded as a likely endless loop
+func WithSimMaxSteps(n int) SimOption {
+	return func(opts *simOptions) {
+		opts.maxSteps = n
+	}
+}
+
+// SimReport is the result of TaskFlow.Simulate, averaged over simulated runs
+type SimReport struct {
+	Runs   int                `json:"runs"`
+	Work   time.Duration      `json:"work"`   // total cost of tasks run
+	Span   time.Duration      `json:"span"`   // time to drain graph given unlimited workers
+	Visits map[string]float64 `json:"visits"` // times each task runs, skipped tasks are not counted
+}
+
+// SimulationError is a simulated run not draining graph properly, see TaskFlow.Simulate and TaskFlow.Explore
+type SimulationError struct {
+	Graph   string
+	Choices []string // branches taken by conditions in order, as "condition->branch"
+	Reason  string
+}
+
+func (e *SimulationError) Error() string {
+	return fmt.Sprintf("simulated run of %v taking %v: %v", e.Graph, e.Choices, e.Reason)
+}
+
+// Simulate runs tf runs times without calling any handle, conditions taking branches by their probabilities
+// or the oracle, to estimate its expected cost and check each run drains graph: a run fails if a task is scheduled
+// again before it finished, or tasks are left neither run nor skipped, like a join waiting for an untaken branch.
+// Subflows are single tasks, and gates, skip tags and canceling conditions are not simulated.
+// It stops at the first failed run, returning a SimulationError.
+func (tf *TaskFlow) Simulate(runs int, opts ...SimOption) (SimReport, error) {
+	if runs <= 0 {
+		return SimReport{}, fmt.Errorf("simulated runs must be positive, got %v", runs)
+	}
+	o := simOptions{maxSteps: kSimMaxSteps}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for _, n := range tf.graph.nodes {
+		if p, ok := n.ptr.(*Condition); ok && p.probabilities != nil && len(p.probabilities) != len(simBranches(p)) {
+			return SimReport{}, fmt.Errorf("condition %v has %v probabilities for %v branches", n.name, len(p.probabilities), len(simBranches(p)))
+		}
+	}
+	rng := rand.New(rand.NewSource(o.seed))
+	cost := tf.graph.simCost(o.cost)
+
+	report := SimReport{Runs: runs, Visits: make(map[string]float64)}
+	for i := 0; i < runs; i++ {
+		sim := newSimRun(tf.graph, o.maxSteps, cost, func(n *innerNode, visit int, branches []*innerNode) int {
+			if o.oracle != nil {
+				if b := o.oracle(&Task{node: n}, visit); b >= 0 {
+					return b
+				}
+			}
+			return pickBranch(rng, n.ptr.(*Condition).probabilities, len(branches))
+		})
+		if err := sim.run(); err != nil {
+			return report, err
+		}
+		report.Work += sim.work
+		report.Span += sim.span
+		for n, cnt := range sim.visits {
+			report.Visits[n.name] += float64(cnt)
+		}
+	}
+	report.Work /= time.Duration(runs)
+	report.Span /= time.Duration(runs)
+	for name := range report.Visits {
+		report.Visits[name] /= float64(runs)
+	}
+	return report, nil
+}
+
+// ExploreReport is the result of TaskFlow.Explore
+type ExploreReport struct {
+	Paths     int `json:"paths"`     // branch combinations simulated to the end
+	Truncated int `json:"truncated"` // combinations cut at the bound of choices
+}
+
+// Explore simulates every combination of branches taken by conditions like Simulate, up to maxChoices
+// conditions run in a simulated run, so that loops are unrolled at most that far. It returns errors of all
+// failed combinations joined.
+func (tf *TaskFlow) Explore(maxChoices int) (ExploreReport, error) {
+	if maxChoices < 0 {
+		return ExploreReport{}, fmt.Errorf("max choices cannot be negative, got %v", maxChoices)
+	}
+	cost := tf.graph.simCost(nil)
+	report := ExploreReport{}
+	errs := make([]error, 0)
+	prefixes := [][]int{{}}
+	for len(prefixes) > 0 {
+		prefix := prefixes[len(prefixes)-1]
+		prefixes = prefixes[:len(prefixes)-1]
+
+		taken, fanouts := make([]int, 0, maxChoices), make([]int, 0, maxChoices)
+		truncated := false
+		sim := newSimRun(tf.graph, kSimMaxSteps, cost, func(n *innerNode, visit int, branches []*innerNode) int {
+			k := len(taken)
+			if k == maxChoices {
+				truncated = true
+				return -1
+			}
+			b := 0
+			if k < len(prefix) {
+				b = prefix[k]
+			}
+			taken, fanouts = append(taken, b), append(fanouts, len(branches))
+			return b
+		})
+		err := sim.run()
+		for k := len(prefix); k < len(taken); k++ {
+			for b := 1; b < fanouts[k]; b++ {
+				prefixes = append(prefixes, append(slices.Clone(taken[:k]), b))
+			}
+		}
+		switch {
+		case truncated:
+			report.Truncated++
+		case err != nil:
+			report.Paths++
+			errs = append(errs, err)
+		default:
+			report.Paths++
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+// pickBranch picks one of n branches by weights p, uniformly if p is nil
+func pickBranch(rng *rand.Rand, p []float64, n int) int {
+	if p == nil {
+		return rng.Intn(n)
+	}
+	sum := 0.0
+	for _, w := range p {
+		sum += w
+	}
+	r := rng.Float64() * sum
+	for i, w := range p {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	return n - 1
+}
+
+// simCost returns cost of a node in simulated runs, by cost if not nil, or by its latest run otherwise
+func (g *eGraph) simCost(cost func(t *Task) time.Duration) func(n *innerNode) time.Duration {
+	if cost != nil {
+		return func(n *innerNode) time.Duration { return cost(&Task{node: n}) }
+	}
+	g.costsMu.Lock()
+	defer g.costsMu.Unlock()
+	latest := make(map[*innerNode]time.Duration, len(g.costs))
+	for n, c := range g.costs {
+		latest[n] = c.cost
+	}
+	return func(n *innerNode) time.Duration { return latest[n] }
+}
+
+// simBranches returns successors of condition p indexed by branch: by return value, or by key in sorted order
+func simBranches(p *Condition) []*innerNode {
+	if p.keyHandle != nil {
+		keys := make([]string, 0, len(p.stringMapper))
+		for key := range p.stringMapper {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		branches := make([]*innerNode, 0, len(keys))
+		for _, key := range keys {
+			branches = append(branches, p.stringMapper[key])
+		}
+		return branches
+	}
+	branches := make([]*innerNode, len(p.mapper))
+	for i, succ := range p.mapper {
+		if i < uint(len(branches)) {
+			branches[i] = succ
+		}
+	}
+	return branches
+}
+
+// simRun replays how executor schedules and skips nodes of a graph, one node at a time and without running handles.
+// It keeps counters of its own rather than those of nodes, so that it does not interfere with real runs.
+type simRun struct {
+	g        *eGraph
+	maxSteps int
+	cost     func(n *innerNode) time.Duration
+	// choose returns branch taken by condition n at its visit-th run, negative to stop the simulated run
+	choose func(n *innerNode, visit int, branches []*innerNode) int
+
+	waits    map[*innerNode]int // strong dependents and arrivals of each node
+	counters map[*innerNode]int
+	state    map[*innerNode]int32
+	live     map[*innerNode]bool
+	ready    map[*innerNode]time.Duration // when node is released in simulated time
+	looping  map[*innerNode]bool
+	visits   map[*innerNode]int
+	queue    []*innerNode
+	choices  []string
+	work     time.Duration
+	span     time.Duration
+	defect   string
+}
+
+func newSimRun(g *eGraph, maxSteps int, cost func(n *innerNode) time.Duration,
+	choose func(n *innerNode, visit int, branches []*innerNode) int) *simRun {
+	s := &simRun{
+		g:        g,
+		maxSteps: maxSteps,
+		cost:     cost,
+		choose:   choose,
+		waits:    make(map[*innerNode]int, len(g.nodes)),
+		counters: make(map[*innerNode]int, len(g.nodes)),
+		state:    make(map[*innerNode]int32, len(g.nodes)),
+		live:     make(map[*innerNode]bool),
+		ready:    make(map[*innerNode]time.Duration),
+		looping:  make(map[*innerNode]bool),
+		visits:   make(map[*innerNode]int),
+	}
+	for _, n := range g.nodes {
+		n.rw.RLock()
+		s.waits[n] = n.strongDependents() + len(n.arrivals)
+		n.rw.RUnlock()
+		s.counters[n] = s.waits[n]
+		if n.Typ == nodeCondition {
+			s.looping[n] = reachable(n.successors, n)
+		}
+	}
+	return s
+}
+
+// run simulates a run of graph, returning a SimulationError if it does not drain properly
+func (s *simRun) run() error {
+	for _, n := range s.g.nodes {
+		if len(n.dependents) == 0 && s.counters[n] == 0 {
+			s.push(n, 0)
+		}
+	}
+
+	for steps := 0; len(s.queue) > 0 && s.defect == ""; steps++ {
+		if steps == s.maxSteps {
+			return s.fail(fmt.Sprintf("more than %v tasks run, likely an endless loop", s.maxSteps))
+		}
+		n := s.queue[0]
+		s.queue = s.queue[1:]
+		end := s.ready[n] + s.cost(n)
+		s.work += s.cost(n)
+		s.span = max(s.span, end)
+		s.state[n] = kNodeStateFinished
+		visit := s.visits[n]
+		s.visits[n]++
+
+		if p, ok := n.ptr.(*Condition); ok {
+			branches := simBranches(p)
+			if len(branches) == 0 {
+				return s.fail(fmt.Sprintf("condition %v has no branch", n.name))
+			}
+			b := s.choose(n, visit, branches)
+			if b < 0 {
+				return nil
+			}
+			if b >= len(branches) || branches[b] == nil {
+				return s.fail(fmt.Sprintf("condition %v has no branch %v, %v in total", n.name, b, len(branches)))
+			}
+			next := branches[b]
+			s.choices = append(s.choices, fmt.Sprintf("%v->%v", n.name, next.name))
+			s.push(next, end)
+			if !s.looping[n] {
+				for _, succ := range n.successors {
+					if succ != next {
+						s.skip(succ)
+					}
+				}
+			}
+		} else {
+			for _, succ := range n.successors {
+				s.live[succ] = true
+				if s.release(succ, end) == 0 {
+					s.push(succ, end)
+				}
+			}
+		}
+		s.arrive(n, end)
+		// rearm
+		s.counters[n] = s.waits[n]
+		s.live[n] = false
+		s.ready[n] = 0
+	}
+	if s.defect != "" {
+		return s.fail(s.defect)
+	}
+
+	stranded := make([]*innerNode, 0)
+	for _, n := range s.g.nodes {
+		if s.state[n] == kNodeStateIdle && (s.live[n] || s.counters[n] != s.waits[n]) {
+			stranded = append(stranded, n)
+		}
+	}
+	if len(stranded) > 0 {
+		slices.SortFunc(stranded, func(a, b *innerNode) int { return cmp.Compare(a.name, b.name) })
+		return s.fail(fmt.Sprintf("tasks %v are neither run nor skipped", strings.Join(nodeNames(stranded), ", ")))
+	}
+	return nil
+}
+
+func (s *simRun) fail(reason string) error {
+	return &SimulationError{Graph: s.g.name, Choices: slices.Clone(s.choices), Reason: reason}
+}
+
+func (s *simRun) push(n *innerNode, at time.Duration) {
+	if s.state[n] == kNodeStateWaiting {
+		s.defect = fmt.Sprintf("task %v is scheduled again before it finished", n.name)
+		return
+	}
+	s.state[n] = kNodeStateWaiting
+	s.ready[n] = max(s.ready[n], at)
+	s.queue = append(s.queue, n)
+}
+
+func (s *simRun) release(n *innerNode, at time.Duration) int {
+	s.counters[n]--
+	s.ready[n] = max(s.ready[n], at)
+	return s.counters[n]
+}
+
+func (s *simRun) arrive(n *innerNode, at time.Duration) {
+	for _, b := range n.arrives {
+		if s.release(b, at) == 0 {
+			s.live[b] = true
+			s.push(b, at)
+		}
+	}
+}
+
+// skip mirrors executor skipBranch
+func (s *simRun) skip(n *innerNode) {
+	if s.counters[n] != 0 || s.live[n] || s.state[n] != kNodeStateIdle {
+		return
+	}
+	s.state[n] = kNodeStateSkipped
+	s.arrive(n, s.ready[n])
+	for _, succ := range n.successors {
+		if n.Typ == nodeCondition {
+			s.skip(succ)
+			continue
+		}
+		if s.release(succ, s.ready[n]) != 0 {
+			continue
+		}
+		if s.live[succ] {
+			s.push(succ, s.ready[succ])
+		} else {
+			s.skip(succ)
+		}
+	}
+}
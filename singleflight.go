@@ -0,0 +1,88 @@
+package gotaskflow
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WithSingleflightKey makes task share the run of a task of the same key already running on the executor,
+// such as refreshing a token shared by taskflows run at once. The later task waits for that run instead of running
+// its handle, and fails if it fails. Waiting holds a worker. Only static tasks are shared, empty key means none.
+func (t *Task) WithSingleflightKey(key string) *Task {
+	t.node.flightKey = key
+	return t
+}
+
+// flight is a run of a static handle, shared by tasks of its key arriving while it runs
+type flight struct {
+	leader string // name of the task running handle
+	done   chan struct{}
+	err    error
+}
+
+// flights keeps runs in progress by singleflight key, see Task.WithSingleflightKey
+type flights struct {
+	runs map[string]*flight
+	mu   *sync.Mutex
+}
+
+func newFlights() *flights {
+	return &flights{runs: make(map[string]*flight), mu: &sync.Mutex{}}
+}
+
+// join returns the run of key in progress, or starts one led by node, reporting whether node leads it
+func (f *flights) join(key string, node *innerNode) (*flight, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if run, ok := f.runs[key]; ok {
+		return run, false
+	}
+	run := &flight{leader: node.name, done: make(chan struct{})}
+	f.runs[key] = run
+	return run, true
+}
+
+// land ends run of key with err, waking tasks waiting for it
+func (f *flights) land(key string, run *flight, err error) {
+	f.mu.Lock()
+	delete(f.runs, key)
+	f.mu.Unlock()
+	run.err = err
+	close(run.done)
+}
+
+// call runs handle of static node, or waits for the run of its singleflight key in progress and returns its failure
+func (e *innerExecutorImpl) call(node *innerNode, p *Static) (err error) {
+	if node.flightKey == "" {
+		return runHandle(p)
+	}
+	run, leader := e.flights.join(node.flightKey, node)
+	if !leader {
+		<-run.done
+		if run.err != nil {
+			return fmt.Errorf("run of %v shared by key %v -> %w", run.leader, node.flightKey, run.err)
+		}
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			// waiters are woken before panic is reported by runStatic
+			e.flights.land(node.flightKey, run, fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+		e.flights.land(node.flightKey, run, err)
+	}()
+	return runHandle(p)
+}
+
+// runHandle runs handle of p, returning error of an error task
+func runHandle(p *Static) error {
+	switch h := p.handle.(type) {
+	case func():
+		h()
+	case func() error:
+		return h()
+	}
+	return nil
+}
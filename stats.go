@@ -0,0 +1,114 @@
+package gotaskflow
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NodeRunStats accumulates runs of tasks of a name in executor, see Executor.NodeStats
+type NodeRunStats struct {
+	RunCount      uint64        `json:"run_count"` // finished runs, failed ones included
+	TotalDuration time.Duration `json:"total_duration"`
+	Failures      uint64        `json:"failures"`
+}
+
+type nodeCounter struct {
+	runs     atomic.Uint64
+	failures atomic.Uint64
+	cost     atomic.Int64 // in nanoseconds
+}
+
+// nodeStats keeps counters by task name for the lifetime of executor, unlike profiler it is never reset
+type nodeStats struct {
+	counters map[string]*nodeCounter
+	mu       *sync.RWMutex
+}
+
+func newNodeStats() *nodeStats {
+	return &nodeStats{
+		counters: make(map[string]*nodeCounter),
+		mu:       &sync.RWMutex{},
+	}
+}
+
+func (s *nodeStats) counter(name string) *nodeCounter {
+	s.mu.RLock()
+	c, ok := s.counters[name]
+	s.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok = s.counters[name]; !ok {
+		c = &nodeCounter{}
+		s.counters[name] = c
+	}
+	return c
+}
+
+// record counts a finished run of task name, which costs d
+func (s *nodeStats) record(name string, d time.Duration, failed bool) {
+	c := s.counter(name)
+	c.runs.Add(1)
+	c.cost.Add(int64(d))
+	if failed {
+		c.failures.Add(1)
+	}
+}
+
+func (s *nodeStats) get(name string) NodeRunStats {
+	s.mu.RLock()
+	c, ok := s.counters[name]
+	s.mu.RUnlock()
+	if !ok {
+		return NodeRunStats{}
+	}
+	return NodeRunStats{
+		RunCount:      c.runs.Load(),
+		TotalDuration: time.Duration(c.cost.Load()),
+		Failures:      c.failures.Load(),
+	}
+}
+
+// NodeStat is NodeRunStats of a task of taskflow, see TaskFlow.SlowestNodes
+type NodeStat struct {
+	Name string `json:"name"`
+	NodeRunStats
+}
+
+// TopN returns at most n nodes of g costing most in total over all its runs, ties and nodes never run by name
+func (g *eGraph) TopN(n int) []*innerNode {
+	if n <= 0 {
+		return nil
+	}
+	costs := make(map[*innerNode]time.Duration, len(g.nodes))
+	for _, node := range g.nodes {
+		costs[node] = g.history.get(node.name).TotalDuration
+	}
+	nodes := slices.Clone(g.nodes)
+	slices.SortFunc(nodes, func(a, b *innerNode) int {
+		if c := cmp.Compare(costs[b], costs[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	return nodes[:min(n, len(nodes))]
+}
+
+// SlowestNodes returns at most n tasks of tf costing most in total over all runs of tf, by any executor,
+// the first place to look at for speeding taskflow up. Tasks of subflows are accounted to the subflow task.
+// Without any run, tasks are ordered by name.
+func (tf *TaskFlow) SlowestNodes(n int) []NodeStat {
+	nodes := tf.graph.TopN(n)
+	stats := make([]NodeStat, 0, len(nodes))
+	for _, node := range nodes {
+		stats = append(stats, NodeStat{Name: node.name, NodeRunStats: tf.graph.history.get(node.name)})
+	}
+	return stats
+}
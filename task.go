@@ -1,5 +1,10 @@
 package gotaskflow
 
+import (
+	"fmt"
+	"slices"
+)
+
 // Basic component of Taskflow
 type Task struct {
 	node *innerNode
@@ -12,6 +17,33 @@ func NewTask(name string, f func()) *Task {
 	}
 }
 
+// NewErrorTask returns a static task whose handle reports failure by returning an error rather than by panic.
+// A non-nil error fails the task and cancels taskflow like a panic, and is reported by RunHandle.Err.
+func NewErrorTask(name string, f func() error) *Task {
+	return &Task{
+		node: builder.NewStatic(name, f),
+	}
+}
+
+// NewConditionalTask returns a static task running f only if pred holds once task is ready. Otherwise it is skipped
+// like by SkipTags, finishing instantly and releasing its successors, so that they run either way.
+// It is lighter than a condition for gating a single step, such as by a feature flag.
+func NewConditionalTask(name string, pred func() bool, f func()) *Task {
+	node := builder.NewStatic(name, f)
+	node.ptr.(*Static).gate = pred
+	return &Task{node: node}
+}
+
+// NewControlledTask returns a static task whose handle gets a TaskControl, to notice cancellation while running
+func NewControlledTask(name string, f func(tc TaskControl)) *Task {
+	node := builder.NewStatic(name, nil)
+	node.ptr.(*Static).handle = func() {
+		f(TaskControl{&Runtime{node: node}})
+	}
+	node.origin = f
+	return &Task{node: node}
+}
+
 // NewSubflow returns a subflow task
 func NewSubflow(name string, f func(sf *Subflow)) *Task {
 	return &Task{
@@ -26,21 +58,156 @@ func NewCondition(name string, predict func() uint) *Task {
 	}
 }
 
+// NewRuntimeCondition returns a condition task whose predict func gets a Runtime, to branch on state of the run
+func NewRuntimeCondition(name string, predict func(rt *Runtime) uint) *Task {
+	node := builder.NewCondition(name, nil)
+	node.ptr.(*Condition).handle = func() uint {
+		return predict(&Runtime{node: node})
+	}
+	node.origin = predict
+	return &Task{node: node}
+}
+
+// Choice is the result of a canceling condition: branch Next is taken, and branches in Cancel are canceled
+type Choice struct {
+	Next   uint
+	Cancel []uint
+}
+
+// NewCancelingCondition returns a condition task which cancels branches besides choosing one, see Choice.
+// Canceled branches are skipped along with their successors right away, counted as done by joins they feed,
+// even if the condition is in a loop, whose untaken branches are otherwise left for later iterations.
+// A canceled branch cannot be chosen later in the same run.
+func NewCancelingCondition(name string, predict func() Choice) *Task {
+	node := builder.NewCondition(name, nil)
+	cond := node.ptr.(*Condition)
+	cond.handle = func() uint {
+		choice := predict()
+		cond.canceling = choice.Cancel
+		return choice.Next
+	}
+	node.origin = predict
+	return &Task{node: node}
+}
+
+// NewStringCondition returns a condition task whose predict func return value is the key of its successor, see Case.
+func NewStringCondition(name string, predict func() string) *Task {
+	return &Task{
+		node: builder.NewStringCondition(name, predict),
+	}
+}
+
+// NewRuntimeStringCondition returns a string condition task whose predict func gets a Runtime,
+// to pick a successor by values upstream tasks stored in the run, see Runtime.Get and Case
+func NewRuntimeStringCondition(name string, predict func(rt *Runtime) string) *Task {
+	node := builder.NewStringCondition(name, nil)
+	node.ptr.(*Condition).keyHandle = func() string {
+		return predict(&Runtime{node: node})
+	}
+	node.origin = predict
+	return &Task{node: node}
+}
+
 // Precede: Tasks all depend on *this*.
-// In Addition, order of tasks is correspond to predict result, ranging from 0...len(tasks)
+// In Addition, order of tasks is correspond to predict result, ranging from 0...len(tasks).
+// For condition, each call appends branches after existing ones, so cond.Then(a); cond.Then(b) makes a branch 0 and b branch 1.
+// For string condition, each task is keyed by its name.
+// Declaring an edge again is a no-op, for condition it means a task already a branch.
+// Edges can be added between runs of taskflow, not during one.
 func (t *Task) Precede(tasks ...*Task) {
-	if cond, ok := t.node.ptr.(*Condition); ok {
-		for i, task := range tasks {
-			cond.mapper[uint(i)] = task.node
+	cond, ok := t.node.ptr.(*Condition)
+	if !ok {
+		for _, task := range tasks {
+			t.node.precede(task.node)
 		}
+		return
+	}
+
+	for _, task := range tasks {
+		if cond.keyHandle != nil {
+			if cond.stringMapper[task.node.name] == task.node {
+				continue
+			}
+			cond.stringMapper[task.node.name] = task.node
+		} else {
+			if cond.branch(task.node) {
+				continue
+			}
+			cond.mapper[uint(len(cond.mapper))] = task.node
+		}
+		t.node.precede(task.node)
+	}
+}
+
+// Then makes next depend on *this* and returns next, so that a chain reads a.Then(b).Then(c).
+// A nil task is skipped: nil.Then(b) returns b, and a.Then(nil) returns a.
+func (t *Task) Then(next *Task) *Task {
+	if t == nil {
+		return next
+	}
+	if next == nil {
+		return t
+	}
+	t.Precede(next)
+	return next
+}
+
+// Chain makes each task depend on the previous one, nil tasks are skipped
+func Chain(tasks ...*Task) {
+	var prev *Task
+	for _, task := range tasks {
+		prev = prev.Then(task)
+	}
+}
+
+// FanOut makes all of dst depend on src, nil tasks are skipped.
+// If src is a condition, dst are appended as its branches in order, as with Precede.
+func FanOut(src *Task, dst ...*Task) {
+	if src == nil {
+		return
+	}
+	src.Precede(slices.DeleteFunc(slices.Clone(dst), func(t *Task) bool { return t == nil })...)
+}
+
+// FanIn makes dst depend on all of srcs, nil tasks are skipped
+func FanIn(srcs []*Task, dst *Task) {
+	for _, task := range srcs {
+		task.Then(dst)
 	}
+}
 
+// nodesOf returns nodes of tasks in order
+func nodesOf(tasks []*Task) []*innerNode {
+	nodes := make([]*innerNode, 0, len(tasks))
 	for _, task := range tasks {
-		t.node.precede(task.node) // TODO: 如何去重
+		nodes = append(nodes, task.node)
+	}
+	return nodes
+}
+
+// Result returns value set by Subflow.SetResult in latest finished run of subflow task, nil for other tasks
+func (t *Task) Result() any {
+	if p, ok := t.node.ptr.(*Subflow); ok {
+		return p.getResult()
+	}
+	return nil
+}
+
+// Case makes task the successor of string condition *this* selected by key
+func (t *Task) Case(key string, task *Task) *Task {
+	cond, ok := t.node.ptr.(*Condition)
+	if !ok || cond.keyHandle == nil {
+		panic(fmt.Sprintf("task %v is not a string condition", t.node.name))
+	}
+	if cond.stringMapper[key] == task.node {
+		return t
 	}
+	cond.stringMapper[key] = task.node
+	t.node.precede(task.node)
+	return t
 }
 
-// Succeed: *this* deps on tasks
+// Succeed: *this* deps on tasks, edges can be added between runs of taskflow like Precede
 func (t *Task) Succeed(tasks ...*Task) {
 	for _, task := range tasks {
 		task.node.precede(t.node)
@@ -51,12 +218,107 @@ func (t *Task) Name() string {
 	return t.node.name
 }
 
+// Rename changes name of task, such as prefixing it with a tenant for profiles. Edges are kept,
+// and so are keys of string conditions it is a successor of. It panics if name is taken in its flow.
+func (t *Task) Rename(name string) *Task {
+	if g := t.node.g; g != nil {
+		g.rename(t.node, name)
+		return t
+	}
+	t.node.name = name
+	return t
+}
+
+// RunID returns id of the current or latest run of task, so that observers can tell runs apart
+func (t *Task) RunID() uint64 {
+	if t.node.g == nil {
+		return 0
+	}
+	return t.node.g.runID.Load()
+}
+
+// WithAsyncBuild makes subflow task run its handle on a goroutine of its own rather than on a pool worker,
+// for handles blocking on IO to decide tasks to build, such as by reading a config file. The worker is free meanwhile,
+// and subflow takes a worker again once handle returns. Handle of a subflow built already is never run again.
+// It panics if task is not a subflow.
+func (t *Task) WithAsyncBuild() *Task {
+	p, ok := t.node.ptr.(*Subflow)
+	if !ok {
+		panic(fmt.Sprintf("task %v is not a subflow", t.node.name))
+	}
+	p.async = true
+	return t
+}
+
 // Priority sets task's sche priority. Noted that due to goroutine concurrent mode, it can only assure task schedule priority, rather than its execution.
+// It can be changed between runs of taskflow.
 func (t *Task) Priority(p TaskPriority) *Task {
 	t.node.priority = p
 	return t
 }
 
+// Tag attaches tags to task, which can be used to select or skip tasks, see TaskFlow.TasksByTag and SkipTags
+func (t *Task) Tag(tags ...string) *Task {
+	for _, tag := range tags {
+		if !slices.Contains(t.node.tags, tag) {
+			t.node.tags = append(t.node.tags, tag)
+		}
+	}
+	return t
+}
+
+// Tags returns tags attached to task
+func (t *Task) Tags() []string {
+	return slices.Clone(t.node.tags)
+}
+
+// WithLabel puts task into group label, such as "database" or "io", so that profiles can be filtered by it,
+// see Executor.ProfileFiltered. A task has at most one label, the latest one wins. Tags are used for selecting tasks instead.
+func (t *Task) WithLabel(label string) *Task {
+	t.node.label = label
+	return t
+}
+
+// OnSuccess registers f run by executor each time task succeeds, after its handle returns and before its successors
+// are released. Callbacks run in registration order, one panicking is logged and skipped without failing taskflow.
+func (t *Task) OnSuccess(f func()) *Task {
+	if t.node.callbacks == nil {
+		t.node.callbacks = &callbacks{}
+	}
+	t.node.callbacks.success = append(t.node.callbacks.success, f)
+	return t
+}
+
+// OnFail registers f run by executor each time task fails, like OnSuccess. recovered is the cause: value recovered
+// from a panic, error returned by an error task, ErrTimeout, or ErrSubflowTooDeep.
+func (t *Task) OnFail(f func(recovered any)) *Task {
+	if t.node.callbacks == nil {
+		t.node.callbacks = &callbacks{}
+	}
+	t.node.callbacks.fail = append(t.node.callbacks.fail, f)
+	return t
+}
+
+// Panic returns panic of task in its latest run, nil if it did not panic
+func (t *Task) Panic() *PanicError {
+	return t.node.panicked.Load()
+}
+
+// Label returns label of task, see WithLabel
+func (t *Task) Label() string {
+	return t.node.label
+}
+
+// WithSpanLabeler sets how spans of task are named in profiles, instead of by task name.
+// labeler is called each time task runs, so that runs of a task in a loop or of parameterized tasks can be told apart.
+// Task names are unique in a graph, so parameterized tasks cannot share a name, such as fetch, in one taskflow or subflow.
+// Give them distinct names like fetch_3 and fetch_7, and let labeler name their spans fetch[shard=3] and fetch[shard=7].
+// A span is named by task name if labeler panics.
+func (t *Task) WithSpanLabeler(labeler func(t *Task) string) *Task {
+	t.node.labeler = labeler
+	return t
+}
+
 // Task sche priority
 type TaskPriority uint
 
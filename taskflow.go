@@ -1,13 +1,44 @@
 package gotaskflow
 
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
 // TaskFlow represents a series of tasks organized in DAG.
 // Tasks must be pushed via a `Push` api.
+// Between runs, that is once a run is waited for and before the next one starts, tasks can be pushed,
+// edges added and priorities changed. Each run works out entries and join counters from the edges it starts with.
+// Changing a taskflow while it runs is not supported.
 type TaskFlow struct {
-	name  string
-	graph *eGraph
+	name      string
+	graph     *eGraph
+	submitted atomic.Bool // run by Executor.SubmitOnce and not finished yet
+	shared    *sharedRun  // run by Executor.RunOnce and not finished yet
+	sharedMu  *sync.Mutex
+}
+
+// sharedRun is a run of Executor.RunOnce, whose result is handed to callers arriving while it runs
+type sharedRun struct {
+	done chan struct{}
+	err  error
+}
+
+// RunID returns id of the current or latest run of taskflow, 0 if it never ran
+func (tf *TaskFlow) RunID() uint64 {
+	return tf.graph.runID.Load()
+}
+
+// Canceled reports whether the current or latest run of taskflow was canceled, by Executor.Cancel or by a failed task.
+// It is valid once the run is waited for, see RunHandle.Canceled for concurrent runs.
+func (tf *TaskFlow) Canceled() bool {
+	return tf.graph.canceled.Load()
 }
 
-// Reset resets taskflow
+// Reset resets taskflow, dropping failure and values of the latest run. A run resets taskflow as well,
+// so it is not needed before running again, see TaskFlow for changes allowed between runs.
 func (tf *TaskFlow) Reset() {
 	tf.graph.reset()
 }
@@ -15,17 +46,107 @@ func (tf *TaskFlow) Reset() {
 // NewTaskFlow returns a taskflow struct
 func NewTaskFlow(name string) *TaskFlow {
 	return &TaskFlow{
-		graph: newGraph(name),
+		name:     name,
+		graph:    newGraph(name),
+		sharedMu: &sync.Mutex{},
 	}
 }
 
-// Push pushs all task into taskflow
+// Push pushs all task into taskflow, it panics if a task name is already taken, see TryPush
 func (tf *TaskFlow) Push(tasks ...*Task) {
 	for _, task := range tasks {
 		tf.graph.push(task.node)
 	}
 }
 
+// TryPush is Push returning an error instead of panicking if a task name is already taken, in which case no task is pushed
+func (tf *TaskFlow) TryPush(tasks ...*Task) error {
+	return tf.graph.tryPush(nodesOf(tasks)...)
+}
+
 func (tf *TaskFlow) Name() string {
 	return tf.name
 }
+
+// OnBeforeRun registers hook run by executor before tasks of each run are scheduled, hooks run in registration order.
+// A panic in hook is reported like a task panic and cancels the run.
+func (tf *TaskFlow) OnBeforeRun(hook func()) *TaskFlow {
+	tf.graph.beforeRun = append(tf.graph.beforeRun, hook)
+	return tf
+}
+
+// OnAfterRun registers hook run by executor once all scheduled tasks of each run finished, hooks run in registration order.
+// err is the first task panic, or ErrCanceled if run is canceled.
+func (tf *TaskFlow) OnAfterRun(hook func(err error)) *TaskFlow {
+	tf.graph.afterRun = append(tf.graph.afterRun, hook)
+	return tf
+}
+
+// RandomizeOrder makes executor dispatch ready tasks in a random order seeded by seed, instead of by priority.
+// It is meant for testing: a flow that only works under a specific dispatch order has missing dependencies.
+func (tf *TaskFlow) RandomizeOrder(seed int64) *TaskFlow {
+	tf.graph.randomize(seed)
+	return tf
+}
+
+// TasksByTag returns tasks carrying tag, including ones in instancelized subflows
+func (tf *TaskFlow) TasksByTag(tag string) []*Task {
+	tasks := make([]*Task, 0)
+	tf.graph.walk(func(n *innerNode) {
+		if slices.Contains(n.tags, tag) {
+			tasks = append(tasks, &Task{node: n})
+		}
+	})
+	return tasks
+}
+
+// WithMaxConcurrency caps how many tasks of taskflow run at once, on top of executor concurrency.
+// Tasks inside subflows are not counted.
+func (tf *TaskFlow) WithMaxConcurrency(n uint) *TaskFlow {
+	if n == 0 {
+		panic("taskflow concurrency cannot be zero")
+	}
+	tf.graph.limiter = newLimiter(n)
+	return tf
+}
+
+// ParallelFor returns a taskflow running fn on every item in parallel, with at most maxConcurrency items at once.
+// Item tasks are wired between a start and an end task. maxConcurrency <= 0 means no cap other than executor's.
+func ParallelFor[T any](items []T, fn func(item T), maxConcurrency int) *TaskFlow {
+	tf := NewTaskFlow("parallel_for")
+	if maxConcurrency > 0 {
+		tf.WithMaxConcurrency(uint(maxConcurrency))
+	}
+
+	start, end := NewTask("start", func() {}), NewTask("end", func() {})
+	tf.Push(start, end)
+	if len(items) == 0 {
+		start.Precede(end)
+		return tf
+	}
+
+	for i, item := range items {
+		item := item
+		task := NewTask(fmt.Sprintf("item_%d", i), func() {
+			fn(item)
+		})
+		start.Precede(task)
+		task.Precede(end)
+		tf.Push(task)
+	}
+	return tf
+}
+
+// ConditionChoices returns choices made by each condition in the latest run, in order.
+// A choice is the index, or the key for string condition, of the chosen successor. Conditions in a loop have many choices.
+func (tf *TaskFlow) ConditionChoices() map[string][]string {
+	choices := make(map[string][]string)
+	tf.graph.walk(func(n *innerNode) {
+		if cond, ok := n.ptr.(*Condition); ok {
+			if history := cond.history(); len(history) > 0 {
+				choices[n.name] = append(choices[n.name], history...)
+			}
+		}
+	})
+	return choices
+}
@@ -1,15 +1,27 @@
 package gotaskflow_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	gotaskflow "github.com/noneback/go-taskflow"
+	taskflowpb "github.com/noneback/go-taskflow/proto/v1"
 	"github.com/noneback/go-taskflow/utils"
+	"google.golang.org/protobuf/proto"
 )
 
 type rgChain[R comparable] struct {
@@ -536,3 +548,1509 @@ func TestTaskflowPriority(t *testing.T) {
 		}
 	}
 }
+
+func TestTaskflowRandomizeOrder(t *testing.T) {
+	for seed := int64(0); seed < 5; seed++ {
+		q := utils.NewQueue[string]()
+		A, B, C, D :=
+			gotaskflow.NewTask("A", func() {
+				q.Put("A")
+			}),
+			gotaskflow.NewTask("B", func() {
+				q.Put("B")
+			}),
+			gotaskflow.NewTask("C", func() {
+				q.Put("C")
+			}),
+			gotaskflow.NewTask("D", func() {
+				q.Put("D")
+			})
+		A.Precede(C)
+		B.Precede(C)
+		C.Precede(D)
+
+		tf := gotaskflow.NewTaskFlow("G").RandomizeOrder(seed)
+		tf.Push(A, B, C, D)
+		executor.Run(tf).Wait()
+
+		chain := newRgChain[string]()
+		chain.grouping("A", "B")
+		chain.grouping("C")
+		chain.grouping("D")
+		checkTopology(t, q, chain)
+	}
+}
+
+func TestTaskflowTags(t *testing.T) {
+	q := utils.NewQueue[string]()
+	A, B, C :=
+		gotaskflow.NewTask("A", func() {
+			q.Put("A")
+		}).Tag("io", "phase:load"),
+		gotaskflow.NewTask("B", func() {
+			q.Put("B")
+		}).Tag("slow"),
+		gotaskflow.NewTask("C", func() {
+			q.Put("C")
+		}).Tag("io")
+	A.Precede(B)
+	B.Precede(C)
+	tf := gotaskflow.NewTaskFlow("G")
+	tf.Push(A, B, C)
+
+	if tasks := tf.TasksByTag("io"); len(tasks) != 2 || tasks[0].Name() != "A" || tasks[1].Name() != "C" {
+		t.Fail()
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		executor.Run(tf, gotaskflow.SkipTags("slow")).Wait()
+		chain := newRgChain[string]()
+		chain.grouping("A")
+		chain.grouping("C")
+		checkTopology(t, q, chain)
+		if q.Len() != 0 {
+			t.Fail()
+		}
+	})
+
+	t.Run("no skip", func(t *testing.T) {
+		executor.Run(tf).Wait()
+		chain := newRgChain[string]()
+		chain.grouping("A")
+		chain.grouping("B")
+		chain.grouping("C")
+		checkTopology(t, q, chain)
+	})
+}
+
+func TestTaskflowStringCondition(t *testing.T) {
+	q := utils.NewQueue[string]()
+	status := "ok"
+	cond := gotaskflow.NewStringCondition("status", func() string {
+		return status
+	})
+	ok, retry, fail :=
+		gotaskflow.NewTask("ok", func() {
+			q.Put("ok")
+		}),
+		gotaskflow.NewTask("retry", func() {
+			q.Put("retry")
+		}),
+		gotaskflow.NewTask("fail", func() {
+			q.Put("fail")
+		})
+	cond.Precede(ok, retry)
+	cond.Case("error", fail)
+
+	tf := gotaskflow.NewTaskFlow("G")
+	tf.Push(cond, ok, retry, fail)
+
+	for _, status = range []string{"ok", "retry", "error"} {
+		executor.Run(tf).Wait()
+	}
+	for _, expected := range []string{"ok", "retry", "fail"} {
+		if real := q.PeakAndTake(); real != expected {
+			t.Errorf("expected %v, got %v", expected, real)
+		}
+	}
+
+	if err := gotaskflow.Visualize(tf, os.Stdout); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParallelFor(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		var sum, running, maxRunning atomic.Int32
+		items := []int32{1, 2, 3, 4, 5, 6, 7, 8}
+		tf := gotaskflow.ParallelFor(items, func(item int32) {
+			n := running.Add(1)
+			for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			sum.Add(item)
+			running.Add(-1)
+		}, 3)
+		executor.Run(tf).Wait()
+
+		if sum.Load() != 36 {
+			t.Errorf("expected sum 36, got %v", sum.Load())
+		}
+		if maxRunning.Load() > 3 {
+			t.Errorf("expected at most 3 items running, got %v", maxRunning.Load())
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		tf := gotaskflow.ParallelFor([]int{}, func(item int) {
+			t.Fail()
+		}, 3)
+		executor.Run(tf).Wait()
+	})
+}
+
+func TestTaskflowConditionChoices(t *testing.T) {
+	i := 0
+	tf := gotaskflow.NewTaskFlow("G")
+	init := gotaskflow.NewTask("init", func() {
+		i = 0
+	})
+	cond := gotaskflow.NewCondition("cond", func() uint {
+		i++
+		if i > 2 {
+			return 0
+		}
+		return 1
+	})
+	done := gotaskflow.NewTask("done", func() {})
+	init.Precede(cond)
+	cond.Precede(done, cond)
+	tf.Push(init, cond, done)
+
+	executor.Run(tf).Wait()
+	if choices := tf.ConditionChoices(); fmt.Sprint(choices["cond"]) != "[1 1 0]" {
+		t.Errorf("unexpected choices %v", choices)
+	}
+
+	// history is per run
+	executor.Run(tf).Wait()
+	if choices := tf.ConditionChoices(); len(choices["cond"]) != 3 {
+		t.Errorf("unexpected choices %v", choices)
+	}
+
+	var buf bytes.Buffer
+	if err := gotaskflow.Visualize(tf, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "color=red") {
+		t.Errorf("expected taken branch to be highlighted")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	build := func(withD bool) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow("G")
+		A, B, C := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {}), gotaskflow.NewTask("C", func() {})
+		tf.Push(A, B, C)
+		if withD {
+			D := gotaskflow.NewTask("D", func() {})
+			A.Precede(D)
+			D.Precede(C)
+			tf.Push(D)
+		} else {
+			A.Precede(B)
+			B.Precede(C)
+		}
+		return tf
+	}
+
+	if diff := gotaskflow.Diff(build(false), build(false)); !diff.Empty() {
+		t.Errorf("expected no diff, got\n%v", diff)
+	}
+
+	expected := "+ node D\n" +
+		"+ edge A -> D\n" +
+		"+ edge D -> C\n" +
+		"- edge A -> B\n" +
+		"- edge B -> C\n"
+	if diff := gotaskflow.Diff(build(false), build(true)); diff.String() != expected {
+		t.Errorf("expected diff\n%v\ngot\n%v", expected, diff)
+	}
+}
+
+func TestTaskflowConditionJoin(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	var order []string
+	var mu sync.Mutex
+	record := func(name string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+		}
+	}
+
+	cond := gotaskflow.NewCondition("cond", func() uint { return 0 })
+	B1, B2 := gotaskflow.NewTask("B1", record("B1")), gotaskflow.NewTask("B2", record("B2"))
+	C2 := gotaskflow.NewTask("C2", record("C2"))
+	J := gotaskflow.NewTask("J", record("J"))
+	cond.Precede(B1, B2)
+	B2.Precede(C2)
+	B1.Precede(J)
+	C2.Precede(J)
+	tf.Push(cond, B1, B2, C2, J)
+
+	if err := tf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		order = nil
+		done := make(chan struct{})
+		go func() {
+			executor.Run(tf).Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("flow stalls on untaken branch")
+		}
+		if fmt.Sprint(order) != "[B1 J]" {
+			t.Errorf("unexpected order %v", order)
+		}
+	}
+}
+
+func TestTaskflowCancelingCondition(t *testing.T) {
+	for _, canceling := range []bool{false, true} {
+		tf := gotaskflow.NewTaskFlow("G")
+		var order []string
+		var mu sync.Mutex
+		record := func(name string) func() {
+			return func() {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, name)
+			}
+		}
+
+		i := 0
+		cond := gotaskflow.NewCancelingCondition("cond", func() gotaskflow.Choice {
+			if i++; i < 3 {
+				return gotaskflow.Choice{Next: 0}
+			}
+			if canceling {
+				return gotaskflow.Choice{Next: 1, Cancel: []uint{2}}
+			}
+			return gotaskflow.Choice{Next: 1}
+		})
+		A, B := gotaskflow.NewTask("A", record("A")), gotaskflow.NewTask("B", record("B"))
+		J := gotaskflow.NewTask("J", record("J"))
+		init := gotaskflow.NewTask("init", func() {})
+		// untaken branches of a looping condition are left for later iterations, so J waits for B unless B is canceled
+		init.Precede(cond)
+		cond.Precede(cond, A, B)
+		gotaskflow.FanIn([]*gotaskflow.Task{A, B}, J)
+		tf.Push(init, cond, A, B, J)
+
+		executor.Run(tf).Wait()
+		expected := "[A]"
+		if canceling {
+			expected = "[A J]"
+		}
+		if fmt.Sprint(order) != expected {
+			t.Errorf("canceling %v: expected %v, got %v", canceling, expected, order)
+		}
+	}
+}
+
+func TestTaskflowValidate(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {})
+	A.Precede(B)
+	B.Precede(A)
+	tf.Push(A, B)
+	if err := tf.Validate(); err == nil || !strings.Contains(err.Error(), "cycle without condition") {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+
+	tf = gotaskflow.NewTaskFlow("G")
+	cond := gotaskflow.NewCondition("cond", func() uint { return 0 })
+	B1, B2, J := gotaskflow.NewTask("B1", func() {}), gotaskflow.NewTask("B2", func() {}), gotaskflow.NewTask("J", func() {})
+	back := gotaskflow.NewCondition("back", func() uint { return 0 })
+	cond.Precede(B1, B2)
+	B1.Precede(J)
+	B2.Precede(J)
+	J.Precede(back)
+	back.Precede(cond)
+	tf.Push(cond, B1, B2, J, back)
+	if err := tf.Validate(); err == nil || !strings.Contains(err.Error(), "J joins branches of looping condition cond") {
+		t.Errorf("expected join error, got %v", err)
+	}
+
+	tf = gotaskflow.NewTaskFlow("G")
+	empty := gotaskflow.NewCondition("empty", func() uint { return 0 })
+	keyed := gotaskflow.NewStringCondition("keyed", func() string { return "x" })
+	pick := gotaskflow.NewCondition("pick", func() uint { return 1 })
+	X, Y := gotaskflow.NewTask("X", func() {}), gotaskflow.NewTask("Y", func() {})
+	pick.Precede(X, Y)
+	tf.Push(empty, keyed, pick, X)
+	err := tf.Validate()
+	var verr *gotaskflow.ValidationError
+	if !errors.As(err, &verr) || verr.Graph != "G" || !strings.Contains(err.Error(), "task empty in G: no successor for return value 0") ||
+		!strings.Contains(err.Error(), "string condition has no successor") || !strings.Contains(err.Error(), "branch 1 Y belongs to graph") {
+		t.Errorf("expected condition errors, got %v", err)
+	}
+
+	tf.Push(Y)
+	if err := pick.ValidateCondition(1); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := pick.ValidateCondition(2); err == nil || !strings.Contains(err.Error(), "return value 2, 2 successors") {
+		t.Errorf("expected error of uncovered return value, got %v", err)
+	}
+	if err := X.ValidateCondition(0); !errors.As(err, &verr) || verr.Reason != "not a condition" {
+		t.Errorf("expected error of static task, got %v", err)
+	}
+}
+
+func TestTaskflowTopology(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	A := gotaskflow.NewTask("A", func() {}).Tag("io").Priority(gotaskflow.HIGH)
+	cond := gotaskflow.NewCondition("cond", func() uint { return 0 })
+	B, C := gotaskflow.NewTask("B", func() {}), gotaskflow.NewTask("C", func() {})
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("inner", func() {}))
+	})
+	A.Precede(cond)
+	cond.Precede(B, C)
+	B.Precede(sub)
+	tf.Push(A, cond, B, C, sub)
+	executor.Run(tf).Wait()
+
+	topo := tf.Topology()
+	edges := make([]string, 0)
+	for _, e := range topo.Edges {
+		edges = append(edges, fmt.Sprintf("%s->%s:%s", e.From, e.To, e.Kind()))
+	}
+	expected := "[A->cond:strong cond->B:condition-branch-0 cond->C:condition-branch-1 B->sub:strong]"
+	if fmt.Sprint(edges) != expected {
+		t.Errorf("expected edges %v, got %v", expected, edges)
+	}
+	if topo.Nodes[0].Priority != gotaskflow.HIGH || fmt.Sprint(topo.Nodes[0].Tags) != "[io]" {
+		t.Errorf("unexpected node %+v", topo.Nodes[0])
+	}
+	if s := topo.Nodes[4].Subflow; s == nil || len(s.Nodes) != 1 || s.Nodes[0].Name != "inner" {
+		t.Errorf("expected instantiated subflow, got %+v", s)
+	}
+
+	// snapshot is not affected by later changes
+	C.Precede(B)
+	if len(topo.Edges) != 4 || len(tf.Topology().Edges) != 5 {
+		t.Errorf("expected topology to be a snapshot")
+	}
+}
+
+func TestSubflowDynamic(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	order := make([]int, 0)
+	var foreign error
+	sub := gotaskflow.NewSubflow("chain", func(sf *gotaskflow.Subflow) {
+		for i := 0; i < 100; i++ {
+			i := i
+			sf.AddTask(fmt.Sprint(i), func() { order = append(order, i) })
+		}
+		for i := 1; i < 100; i++ {
+			prev, _ := sf.FindTask(fmt.Sprint(i - 1))
+			cur, _ := sf.FindTask(fmt.Sprint(i))
+			sf.Precede(prev, cur)
+		}
+		if _, ok := sf.FindTask("100"); ok {
+			foreign = errors.New("found unknown task")
+		}
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					foreign = errors.New("expected panic on task out of subflow")
+				}
+			}()
+			head, _ := sf.FindTask("0")
+			sf.Precede(gotaskflow.NewTask("outer", func() {}), head)
+		}()
+	})
+	tf.Push(sub)
+	executor.Run(tf).Wait()
+
+	if foreign != nil {
+		t.Error(foreign)
+	}
+	if len(order) != 100 {
+		t.Fatalf("expected 100 tasks run, got %v", len(order))
+	}
+	for i, v := range order {
+		if v != i {
+			t.Fatalf("expected linear chain, got %v", order)
+		}
+	}
+
+	// sibling subflows under the same parent are ordered by the parent, not by edges between their tasks
+	tf = gotaskflow.NewTaskFlow("siblings")
+	var fetch *gotaskflow.Task
+	first := gotaskflow.NewSubflow("first", func(sf *gotaskflow.Subflow) {
+		fetch = sf.AddTask("fetch", func() {})
+	})
+	second := gotaskflow.NewSubflow("second", func(sf *gotaskflow.Subflow) {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "precede subflows in their parent") {
+				foreign = fmt.Errorf("expected panic on task of sibling subflow, got %v", r)
+			}
+		}()
+		sf.Precede(fetch, sf.AddTask("parse", func() {}))
+	})
+	first.Precede(second)
+	tf.Push(first, second)
+	executor.Run(tf).Wait()
+	if foreign != nil {
+		t.Error(foreign)
+	}
+}
+
+func TestSubflowResult(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	sub := gotaskflow.NewSubflow("sum", func(sf *gotaskflow.Subflow) {
+		var sum atomic.Int64
+		tasks := make([]*gotaskflow.Task, 0)
+		for i := 1; i <= 4; i++ {
+			i := i
+			tasks = append(tasks, gotaskflow.NewTask(fmt.Sprint(i), func() { sum.Add(int64(i)) }))
+		}
+		done := gotaskflow.NewTask("done", func() { sf.SetResult(sum.Load()) })
+		for _, task := range tasks {
+			task.Precede(done)
+		}
+		sf.Push(tasks...)
+		sf.Push(done)
+	})
+	var got any
+	next := gotaskflow.NewTask("next", func() { got = sub.Result() })
+	sub.Precede(next)
+	tf.Push(sub, next)
+
+	if sub.Result() != nil {
+		t.Errorf("expected no result before run")
+	}
+	executor.Run(tf).Wait()
+	if got != int64(10) {
+		t.Errorf("expected result 10, got %v", got)
+	}
+	if next.Result() != nil {
+		t.Errorf("expected no result of static task")
+	}
+}
+
+func TestTypedTask(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	parse := gotaskflow.NewTypedTask("parse", func(s string) []string { return strings.Fields(s) })
+	count := gotaskflow.NewTypedTask("count", func(words []string) int { return len(words) })
+	double := gotaskflow.NewTypedTask("double", func(n int) int { return n * 2 })
+	gotaskflow.Pipe(parse, count)
+	gotaskflow.Pipe(count, double)
+	tf.Push(parse.Task, count.Task, double.Task)
+
+	parse.SetInput("a b c")
+	executor.Run(tf).Wait()
+	if count.Output() != 3 || double.Output() != 6 {
+		t.Errorf("expected 3 and 6, got %v and %v", count.Output(), double.Output())
+	}
+
+	tf.Reset()
+	parse.SetInput("a b")
+	executor.Run(tf).Wait()
+	if double.Output() != 4 {
+		t.Errorf("expected 4, got %v", double.Output())
+	}
+}
+
+func TestTaskflowMerge(t *testing.T) {
+	var order []string
+	build := func(name string, tasks ...string) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow(name)
+		var prev *gotaskflow.Task
+		for _, task := range tasks {
+			task := task
+			cur := gotaskflow.NewTask(task, func() { order = append(order, task) })
+			if prev != nil {
+				prev.Precede(cur)
+			}
+			tf.Push(cur)
+			prev = cur
+		}
+		return tf
+	}
+
+	tf, release := build("build", "compile", "test"), build("release", "package", "publish")
+	if err := tf.Merge(release, gotaskflow.Bridge{From: "test", To: "package"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(release.Topology().Nodes) != 0 {
+		t.Errorf("expected other to be left empty")
+	}
+	gotaskflow.NewExecutor(1).Run(tf).Wait()
+	if fmt.Sprint(order) != "[compile test package publish]" {
+		t.Errorf("unexpected order %v", order)
+	}
+
+	if err := tf.Merge(build("dup", "compile")); err == nil || !strings.Contains(err.Error(), "name collision [compile]") {
+		t.Errorf("expected collision error, got %v", err)
+	}
+	if err := tf.Merge(build("unknown", "deploy"), gotaskflow.Bridge{From: "missing", To: "deploy"}); err == nil {
+		t.Errorf("expected unknown task error")
+	}
+
+	// merged result is validated, and nothing is changed on error
+	cyclic := gotaskflow.NewTaskFlow("cyclic")
+	r, u := gotaskflow.NewTask("r", func() {}), gotaskflow.NewTask("u", func() {})
+	r.Precede(u)
+	u.Precede(r)
+	cyclic.Push(r, u)
+	before := tf.Topology()
+	if err := tf.Merge(cyclic, gotaskflow.Bridge{From: "publish", To: "r"}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+	if after := tf.Topology(); len(after.Nodes) != len(before.Nodes) || len(after.Edges) != len(before.Edges) {
+		t.Errorf("expected taskflow unchanged, got %+v", after)
+	}
+	if len(cyclic.Topology().Nodes) != 2 {
+		t.Errorf("expected other unchanged")
+	}
+
+	// duplicate bridges are wired once, rolling them back keeps edges of tf
+	if err := tf.Merge(cyclic, gotaskflow.Bridge{From: "compile", To: "r"}, gotaskflow.Bridge{From: "compile", To: "r"}); err == nil {
+		t.Errorf("expected cycle error")
+	}
+	if after := tf.Topology(); fmt.Sprint(after.Edges) != fmt.Sprint(before.Edges) {
+		t.Errorf("expected edges %v unchanged, got %v", before.Edges, after.Edges)
+	}
+}
+
+func TestTaskChaining(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	tasks := make([]*gotaskflow.Task, 0)
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		tasks = append(tasks, gotaskflow.NewTask(name, func() {}))
+	}
+	a, b, c, d, e, f, g := tasks[0], tasks[1], tasks[2], tasks[3], tasks[4], tasks[5], tasks[6]
+	tf.Push(tasks...)
+
+	if last := a.Then(b).Then(nil).Then(c); last != c {
+		t.Errorf("expected Then to return next task")
+	}
+	gotaskflow.FanOut(c, d, nil, e)
+	gotaskflow.FanIn([]*gotaskflow.Task{d, nil, e}, f)
+	gotaskflow.Chain(nil, f, g)
+	// duplicate edges are ignored
+	a.Precede(b)
+	gotaskflow.Chain(f, g)
+
+	edges := make([]string, 0)
+	for _, e := range tf.Topology().Edges {
+		edges = append(edges, e.From+e.To)
+	}
+	if fmt.Sprint(edges) != "[ab bc cd ce df ef fg]" {
+		t.Errorf("unexpected edges %v", edges)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		executor.Run(tf).Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("duplicate edges break execution")
+	}
+}
+
+func TestTypedPipeline(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	src := gotaskflow.NewSource("src", func() []int { return []int{1, 2, 3} })
+	sum := gotaskflow.Map(src, "sum", func(nums []int) int {
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	})
+	format := gotaskflow.Map(sum, "format", func(n int) string { return fmt.Sprintf("sum=%d", n) })
+	tf.Push(src.Task, sum.Task, format.Task)
+
+	executor.Run(tf).Wait()
+	if format.Output() != "sum=6" {
+		t.Errorf("unexpected output %v", format.Output())
+	}
+}
+
+func TestTaskRename(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {})
+	A.Precede(B)
+	tf.Push(A, B)
+
+	if B.Rename("tenant/B").Name() != "tenant/B" {
+		t.Fatalf("unexpected name %v", B.Name())
+	}
+	if got := tf.Descendants("A"); len(got) != 1 || got[0] != "tenant/B" {
+		t.Errorf("unexpected descendants %v", got)
+	}
+	if got := tf.Descendants("B"); got != nil {
+		t.Errorf("expected old name gone, got %v", got)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic on taken name")
+		}
+	}()
+	B.Rename("A")
+}
+
+func TestTaskflowTryPush(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	if err := tf.TryPush(gotaskflow.NewTask("A", func() {})); err != nil {
+		t.Fatal(err)
+	}
+	err := tf.TryPush(gotaskflow.NewTask("B", func() {}), gotaskflow.NewTask("A", func() {}))
+	if err == nil || !strings.Contains(err.Error(), "task A already exists in graph G") {
+		t.Errorf("expected duplicate name reported, got %v", err)
+	}
+	if n := len(tf.Topology().Nodes); n != 1 {
+		t.Errorf("expected no task pushed despite duplicate, got %v tasks", n)
+	}
+
+	var subErr error
+	tf.Push(gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("X", func() {}))
+		subErr = sf.TryPush(gotaskflow.NewTask("X", func() {}))
+	}))
+	executor.Run(tf).Wait()
+	if subErr == nil || !strings.Contains(subErr.Error(), "task X already exists in graph sub") {
+		t.Errorf("expected duplicate name in subflow reported, got %v", subErr)
+	}
+}
+
+func TestTaskflowDuplicateEdges(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	var runs atomic.Int32
+	cond := gotaskflow.NewCondition("cond", func() uint { return 0 })
+	A, B, C := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() { runs.Add(1) }), gotaskflow.NewTask("C", func() {})
+	cond.Precede(A, C)
+	cond.Precede(A, C)
+	A.Precede(B)
+	A.Precede(B)
+	B.Succeed(A)
+	tf.Push(cond, A, B, C)
+
+	if n := len(tf.Topology().Edges); n != 3 {
+		t.Errorf("expected 3 edges, got %v", n)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		executor.Run(tf).Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("duplicate edges hang execution")
+	}
+	if runs.Load() != 1 {
+		t.Errorf("expected B to run once, got %v", runs.Load())
+	}
+}
+
+func TestTaskflowConditionThen(t *testing.T) {
+	var ran sync.Map
+	task := func(name string) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() { ran.Store(name, true) })
+	}
+	for choice, want := range []string{"A", "B", "C"} {
+		tf := gotaskflow.NewTaskFlow("G")
+		cond := gotaskflow.NewCondition("cond", func() uint { return uint(choice) })
+		A, B, C := task("A"), task("B"), task("C")
+		cond.Then(A)
+		cond.Then(B)
+		gotaskflow.FanOut(cond, B, C)
+		tf.Push(cond, A, B, C)
+
+		if n := len(tf.Topology().Edges); n != 3 {
+			t.Errorf("expected 3 edges, got %v", n)
+		}
+		ran.Range(func(k, _ any) bool { ran.Delete(k); return true })
+		executor.Run(tf).Wait()
+		ran.Range(func(k, _ any) bool {
+			if k != want {
+				t.Errorf("choice %v: expected only %v to run, got %v", choice, want, k)
+			}
+			return true
+		})
+		if _, ok := ran.Load(want); !ok {
+			t.Errorf("choice %v: expected %v to run", choice, want)
+		}
+	}
+}
+
+func TestTaskflowProto(t *testing.T) {
+	build := func(registry *gotaskflow.Registry) *gotaskflow.TaskFlow {
+		tf := gotaskflow.NewTaskFlow("G")
+		A := gotaskflow.NewTask("A", registry.Statics["A"]).Priority(gotaskflow.HIGH).Tag("io")
+		cond := gotaskflow.NewCondition("cond", registry.Conditions["cond"])
+		B, C := gotaskflow.NewTask("B", registry.Statics["B"]), gotaskflow.NewTask("C", registry.Statics["C"])
+		str := gotaskflow.NewStringCondition("str", registry.StringConditions["str"])
+		sub := gotaskflow.NewSubflow("sub", registry.Subflows["sub"])
+		A.Precede(cond)
+		cond.Precede(C, B)
+		B.Precede(str)
+		str.Case("go", sub)
+		tf.Push(A, cond, B, C, str, sub)
+		return tf
+	}
+
+	var order []string
+	record := func(name string) func() {
+		return func() { order = append(order, name) }
+	}
+	registry := gotaskflow.Registry{
+		Statics:          map[string]func(){"A": record("A"), "B": record("B"), "C": record("C"), "inner": record("inner")},
+		Conditions:       map[string]func() uint{"cond": func() uint { return 1 }},
+		StringConditions: map[string]func() string{"str": func() string { return "go" }},
+	}
+	registry.Subflows = map[string]func(sf *gotaskflow.Subflow){"sub": func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("inner", registry.Statics["inner"]))
+	}}
+
+	origin := build(&registry)
+	executor.Run(origin).Wait()
+	data, err := origin.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pb := &taskflowpb.Graph{}
+	if err := proto.Unmarshal(data, pb); err != nil {
+		t.Fatal(err)
+	}
+	if pb.Version != 1 || len(pb.Nodes) != 6 || pb.Nodes[5].Type != taskflowpb.NodeType_NODE_TYPE_SUBFLOW || pb.Nodes[5].Subflow.Nodes[0].Name != "inner" {
+		t.Errorf("unexpected message %v", pb)
+	}
+
+	tf := gotaskflow.NewTaskFlow("")
+	if err := tf.UnmarshalProto(data, registry); err != nil {
+		t.Fatal(err)
+	}
+	if diff := gotaskflow.Diff(origin, tf); !diff.Empty() || tf.Name() != "G" {
+		t.Errorf("expected same topology, got\n%v", diff)
+	}
+	if node := tf.Topology().Nodes[0]; node.Priority != gotaskflow.HIGH || fmt.Sprint(node.Tags) != "[io]" {
+		t.Errorf("unexpected node %+v", node)
+	}
+
+	order = nil
+	executor.Run(tf).Wait()
+	// condition picks branch 1, which is B
+	if fmt.Sprint(order) != "[A B inner]" {
+		t.Errorf("unexpected order %v", order)
+	}
+
+	delete(registry.Statics, "C")
+	if err := gotaskflow.NewTaskFlow("").UnmarshalProto(data, registry); err == nil {
+		t.Errorf("expected missing handle error")
+	}
+	if err := gotaskflow.NewTaskFlow("").UnmarshalProto([]byte{0x08, 0x02}, registry); err == nil {
+		t.Errorf("expected version error")
+	}
+}
+
+func TestTaskflowRunHooks(t *testing.T) {
+	var events []string
+	tf := gotaskflow.NewTaskFlow("G")
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("inner", func() { events = append(events, "inner") }))
+	})
+	tf.Push(sub)
+	tf.OnBeforeRun(func() { events = append(events, "before1") }).
+		OnBeforeRun(func() { events = append(events, "before2") }).
+		OnAfterRun(func(err error) { events = append(events, fmt.Sprintf("after %v", err)) })
+
+	executor.Run(tf).Wait()
+	if fmt.Sprint(events) != "[before1 before2 inner after <nil>]" {
+		t.Errorf("unexpected events %v", events)
+	}
+
+	events = nil
+	tf = gotaskflow.NewTaskFlow("G")
+	tf.Push(gotaskflow.NewTask("A", func() { events = append(events, "A") }))
+	tf.OnBeforeRun(func() { panic("no db") }).
+		OnAfterRun(func(err error) { events = append(events, fmt.Sprintf("after %v", err)) })
+	executor.Run(tf).Wait()
+	if fmt.Sprint(events) != "[after hook before_run panic: no db]" {
+		t.Errorf("unexpected events %v", events)
+	}
+}
+
+func TestTaskflowReverse(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B, C, D := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {}), gotaskflow.NewTask("C", func() {}), gotaskflow.NewTask("D", func() {})
+	A.Precede(B, C)
+	B.Precede(D)
+	C.Precede(D)
+	tf.Push(A, B, C, D)
+
+	if d := tf.Descendants("A"); fmt.Sprint(d) != "[B C D]" {
+		t.Errorf("unexpected descendants %v", d)
+	}
+
+	reversed := tf.Reverse()
+	if d := reversed.Descendants("D"); fmt.Sprint(d) != "[B C A]" {
+		t.Errorf("unexpected reversed descendants %v", d)
+	}
+	if p := reversed.ShortestPath("D", "A"); fmt.Sprint(p) != "[D B A]" {
+		t.Errorf("unexpected path %v", p)
+	}
+	if p := reversed.ShortestPath("A", "D"); p != nil {
+		t.Errorf("expected no path, got %v", p)
+	}
+	// origin is untouched
+	if p := tf.ShortestPath("A", "D"); fmt.Sprint(p) != "[A B D]" {
+		t.Errorf("unexpected path %v", p)
+	}
+}
+
+func TestTaskflowRuntimeCondition(t *testing.T) {
+	for _, status := range []int{200, 404} {
+		tf := gotaskflow.NewTaskFlow("G")
+		var taken, keyed string
+		fetch := gotaskflow.NewControlledTask("fetch", func(tc gotaskflow.TaskControl) {
+			tc.Set("status", status)
+		})
+		route := gotaskflow.NewRuntimeCondition("route", func(rt *gotaskflow.Runtime) uint {
+			if rt.DependentStates()["fetch"] != "finished" {
+				t.Errorf("unexpected states %v", rt.DependentStates())
+			}
+			if v, ok := rt.Get("status"); ok && v == 404 {
+				return 1
+			}
+			return 0
+		})
+		ok, notFound := gotaskflow.NewTask("ok", func() { taken = "ok" }), gotaskflow.NewTask("not_found", func() { taken = "not_found" })
+		byKey := gotaskflow.NewRuntimeStringCondition("by_key", func(rt *gotaskflow.Runtime) string {
+			v, _ := rt.Get("status")
+			return fmt.Sprint(v)
+		})
+		found, missing := gotaskflow.NewTask("found", func() { keyed = "found" }), gotaskflow.NewTask("missing", func() { keyed = "missing" })
+		fetch.Precede(route, byKey)
+		route.Precede(ok, notFound)
+		byKey.Case("200", found).Case("404", missing)
+		tf.Push(fetch, route, ok, notFound, byKey, found, missing)
+
+		executor.Run(tf).Wait()
+		if expected := map[int]string{200: "ok", 404: "not_found"}[status]; taken != expected {
+			t.Errorf("status %v: expected branch %v, got %v", status, expected, taken)
+		}
+		if expected := map[int]string{200: "found", 404: "missing"}[status]; keyed != expected {
+			t.Errorf("status %v: expected case %v, got %v", status, expected, keyed)
+		}
+	}
+}
+
+func TestTaskflowLayers(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B, C, D := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {}), gotaskflow.NewTask("C", func() {}), gotaskflow.NewTask("D", func() {})
+	A.Precede(B, C)
+	B.Precede(D)
+	C.Precede(D)
+	A.Precede(D)
+	tf.Push(A, B, C, D)
+
+	layers, err := tf.Layers()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(layers) != "[[A] [B C] [D]]" {
+		t.Errorf("unexpected layers %v", layers)
+	}
+	if w := tf.MaxParallelism(); w != 2 {
+		t.Errorf("expected max parallelism 2 of diamond, got %v", w)
+	}
+	if c := tf.RecommendedConcurrency(); c != uint(min(2, runtime.NumCPU())) {
+		t.Errorf("unexpected recommended concurrency %v", c)
+	}
+	if c := gotaskflow.NewTaskFlow("empty").RecommendedConcurrency(); c != 1 {
+		t.Errorf("expected concurrency 1 of empty flow, got %v", c)
+	}
+
+	loop := gotaskflow.NewTaskFlow("L")
+	E, F := gotaskflow.NewTask("E", func() {}), gotaskflow.NewTask("F", func() {})
+	cond := gotaskflow.NewCondition("cond", func() uint { return 1 })
+	E.Precede(F)
+	F.Precede(cond)
+	cond.Precede(F, gotaskflow.NewTask("done", func() {}))
+	loop.Push(E, F, cond)
+
+	layers, err = loop.Layers()
+	if err == nil {
+		t.Fatalf("expected cycle error")
+	}
+	if fmt.Sprint(layers) != "[[E]]" {
+		t.Errorf("unexpected partial layers %v", layers)
+	}
+	if w := loop.MaxParallelism(); w != 2 {
+		t.Errorf("expected tasks in cycle counted as a layer, got %v", w)
+	}
+}
+
+func TestTaskflowCriticalPath(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	sleep := func(name string, d time.Duration) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() { time.Sleep(d) })
+	}
+	A, B, C := sleep("A", 10*time.Millisecond), sleep("B", 30*time.Millisecond), sleep("C", 5*time.Millisecond)
+	D := gotaskflow.NewSubflow("D", func(sf *gotaskflow.Subflow) {
+		sf.Push(sleep("inner", 5*time.Millisecond))
+	})
+	A.Precede(B, C)
+	D.Succeed(B, C)
+	tf.Push(A, B, C, D)
+
+	if path, cost := tf.CriticalPath(); path != nil || cost != 0 {
+		t.Errorf("expected no path before run, got %v %v", path, cost)
+	}
+	executor.Run(tf).Wait()
+
+	path, cost := tf.CriticalPath()
+	names := make([]string, 0, len(path))
+	for _, task := range path {
+		names = append(names, task.Name())
+	}
+	if fmt.Sprint(names) != "[A B D]" {
+		t.Errorf("unexpected critical path %v", names)
+	}
+	if cost < 45*time.Millisecond {
+		t.Errorf("expected cost covering subflow, got %v", cost)
+	}
+}
+
+func TestTaskflowBarrier(t *testing.T) {
+	executor := gotaskflow.NewExecutor(8, gotaskflow.WithStrictCounting())
+	tf := gotaskflow.NewTaskFlow("G")
+	var n int
+	var done, straggled atomic.Int32
+	var seen, seenStraggled int32
+	// subflow is built once, in the first run
+	plan := gotaskflow.NewTask("plan", func() {
+		if n == 0 {
+			n = 3 + rand.Intn(5)
+		}
+	})
+	barrier := gotaskflow.NewBarrier("barrier")
+	observe := gotaskflow.NewTask("observe", func() {
+		seen, seenStraggled = done.Load(), straggled.Load()
+	})
+	barrier.Precede(observe)
+	sf := gotaskflow.NewSubflow("phase", func(sf *gotaskflow.Subflow) {
+		for i := 0; i < n; i++ {
+			work := gotaskflow.NewTask(fmt.Sprintf("work_%d", i), func() {
+				time.Sleep(time.Millisecond)
+				done.Add(1)
+			})
+			work.Arrive(barrier)
+			sf.Push(work)
+		}
+		sf.Push(gotaskflow.NewTask("straggler", func() {
+			time.Sleep(50 * time.Millisecond)
+			straggled.Add(1)
+		}))
+	})
+	sf.Arrive(barrier)
+	plan.Precede(sf)
+	tf.Push(plan, sf, barrier, observe)
+
+	for run := 1; run <= 2; run++ {
+		done.Store(0)
+		straggled.Store(0)
+		h := executor.RunAsync(tf)
+		<-h.Done()
+		if h.Err() != nil {
+			t.Fatalf("unexpected error: %v", h.Err())
+		}
+		if int(seen) != n || seenStraggled != 0 || straggled.Load() != 1 {
+			t.Errorf("run %v: expected barrier after %v arrivals only, got %v arrivals and %v stragglers", run, n, seen, seenStraggled)
+		}
+	}
+}
+
+func TestTaskflowConditionalTask(t *testing.T) {
+	exec := gotaskflow.NewExecutor(4, gotaskflow.WithCoalescing(), gotaskflow.WithLogger(io.Discard))
+	enabled := false
+	for _, flag := range []bool{false, true} {
+		tf := gotaskflow.NewTaskFlow("G")
+		var stepRan, afterRan bool
+		var state string
+		before := gotaskflow.NewTask("before", func() { enabled = flag })
+		step := gotaskflow.NewConditionalTask("step", func() bool { return enabled }, func() { stepRan = true })
+		after := gotaskflow.NewControlledTask("after", func(tc gotaskflow.TaskControl) {
+			afterRan = true
+			state = tc.DependentStates()["step"]
+		})
+		gotaskflow.Chain(before, step, after)
+		tf.Push(before, step, after)
+
+		h := exec.RunAsync(tf)
+		<-h.Done()
+		if h.Err() != nil || stepRan != flag || !afterRan {
+			t.Errorf("flag %v: unexpected run, step %v, after %v, err %v", flag, stepRan, afterRan, h.Err())
+		}
+		if expected := map[bool]string{false: "skipped", true: "finished"}[flag]; state != expected {
+			t.Errorf("flag %v: expected step %v, got %v", flag, expected, state)
+		}
+	}
+
+	tf := gotaskflow.NewTaskFlow("G")
+	tf.Push(gotaskflow.NewConditionalTask("broken", func() bool { panic("no flag") }, func() { t.Error("should not run") }))
+	h := exec.RunAsync(tf)
+	<-h.Done()
+	if err := h.Err(); err == nil || !strings.Contains(err.Error(), "no flag") {
+		t.Errorf("expected panic of predicate, got %v", err)
+	}
+}
+
+func TestSubflowVisibleToSuccessors(t *testing.T) {
+	exec := gotaskflow.NewExecutor(8)
+	for i := 0; i < 20; i++ {
+		tf := gotaskflow.NewTaskFlow("G")
+		sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+			first := gotaskflow.NewTask("first", func() { time.Sleep(time.Millisecond) })
+			last := gotaskflow.NewControlledTask("last", func(tc gotaskflow.TaskControl) {
+				time.Sleep(time.Millisecond)
+				tc.Set("answer", 42)
+			})
+			first.Precede(last)
+			sf.Push(first, last)
+		})
+		var got any
+		read := gotaskflow.NewControlledTask("read", func(tc gotaskflow.TaskControl) {
+			got, _ = tc.Get("answer")
+		})
+		sub.Precede(read)
+		tf.Push(sub, read)
+
+		exec.Run(tf).Wait()
+		if got != 42 {
+			t.Fatalf("iteration %v: successor of subflow read %v before its last task finished", i, got)
+		}
+	}
+}
+
+func TestSubflowAsyncBuild(t *testing.T) {
+	exec := gotaskflow.NewExecutor(1, gotaskflow.WithLogger(io.Discard))
+	tf := gotaskflow.NewTaskFlow("G")
+	signal := make(chan struct{})
+	var waited bool
+	// builder waits for a sibling task, which needs the only worker
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		select {
+		case <-signal:
+			waited = true
+		case <-time.After(time.Second):
+		}
+		sf.SetResult("built")
+	}).WithAsyncBuild()
+	sibling := gotaskflow.NewTask("sibling", func() { close(signal) })
+	tf.Push(sub, sibling)
+
+	exec.Run(tf).Wait()
+	if !waited || sub.Result() != "built" {
+		t.Errorf("expected builder off pool to let sibling run, waited %v, result %v", waited, sub.Result())
+	}
+
+	tf = gotaskflow.NewTaskFlow("G")
+	tf.Push(gotaskflow.NewSubflow("broken", func(sf *gotaskflow.Subflow) { panic("no config") }).WithAsyncBuild())
+	h := exec.RunAsync(tf)
+	<-h.Done()
+	if err := h.Err(); err == nil || !strings.Contains(err.Error(), "no config") {
+		t.Errorf("expected panic of builder, got %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic of static task")
+		}
+	}()
+	gotaskflow.NewTask("static", func() {}).WithAsyncBuild()
+}
+
+func TestSubflowSideEffectBeforeSuccessors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+	tf := gotaskflow.NewTaskFlow("G")
+	sub := gotaskflow.NewSubflow("write", func(sf *gotaskflow.Subflow) {
+		sf.Push(gotaskflow.NewTask("slow_write", func() {
+			time.Sleep(20 * time.Millisecond)
+			if err := os.WriteFile(path, []byte("done"), 0o644); err != nil {
+				t.Error(err)
+			}
+		}))
+	})
+	var content string
+	read := gotaskflow.NewTask("read", func() {
+		data, _ := os.ReadFile(path)
+		content = string(data)
+	})
+	sub.Precede(read)
+	tf.Push(sub, read)
+
+	executor.Run(tf).Wait()
+	if content != "done" {
+		t.Errorf("successor ran before subflow finished writing, read %q", content)
+	}
+}
+
+func TestTaskflowStatistics(t *testing.T) {
+	if s := gotaskflow.NewTaskFlow("empty").Statistics(); s != (gotaskflow.Statistics{}) {
+		t.Errorf("unexpected statistics of empty flow %+v", s)
+	}
+
+	single := gotaskflow.NewTaskFlow("single")
+	single.Push(gotaskflow.NewTask("A", func() {}))
+	if s := single.Statistics(); s != (gotaskflow.Statistics{NodeCount: 1, MaxDepth: 1, MaxWidth: 1}) {
+		t.Errorf("unexpected statistics of single node %+v", s)
+	}
+
+	// A -> sub(X -> inner(I1, I2, I3) -> Y) -> cond -> {B, C}, D alone
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B, C, D := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {}), gotaskflow.NewTask("C", func() {}), gotaskflow.NewTask("D", func() {})
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		X, Y := gotaskflow.NewTask("X", func() {}), gotaskflow.NewTask("Y", func() {})
+		inner := gotaskflow.NewSubflow("inner", func(sf *gotaskflow.Subflow) {
+			sf.Push(gotaskflow.NewTask("I1", func() {}), gotaskflow.NewTask("I2", func() {}), gotaskflow.NewTask("I3", func() {}))
+		})
+		gotaskflow.Chain(X, inner, Y)
+		sf.Push(X, inner, Y)
+	})
+	cond := gotaskflow.NewCondition("cond", func() uint { return 0 })
+	gotaskflow.Chain(A, sub, cond)
+	cond.Precede(B, C)
+	tf.Push(A, sub, cond, B, C, D)
+
+	before := tf.Statistics()
+	if before.NodeCount != 6 || before.SubflowCount != 1 || before.MaxDepth != 4 || before.MaxWidth != 2 {
+		t.Errorf("unexpected statistics before subflows are instantiated %+v", before)
+	}
+
+	executor.Run(tf).Wait()
+	expected := gotaskflow.Statistics{
+		NodeCount:      12,
+		EdgeCount:      6,
+		SubflowCount:   2,
+		ConditionCount: 1,
+		MaxDepth:       6, // A, X, I*, Y, cond, B
+		MaxWidth:       3,
+	}
+	if s := tf.Statistics(); s != expected {
+		t.Errorf("expected statistics %+v, got %+v", expected, s)
+	}
+
+	// cycles through conditions are loops, not counted
+	B.Precede(A)
+	if s := tf.Statistics(); s.CyclicSubgraphs {
+		t.Errorf("unexpected cycle reported of condition loop %+v", s)
+	}
+	C.Precede(D)
+	D.Precede(C)
+	if s := tf.Statistics(); !s.CyclicSubgraphs {
+		t.Errorf("expected cycle reported, got %+v", s)
+	}
+}
+
+func TestTaskflowSimulate(t *testing.T) {
+	// cond -> {A, B} -> J, A costs 10ms and B 30ms
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B, J := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {}), gotaskflow.NewTask("J", func() {})
+	cond := gotaskflow.NewCondition("cond", func() uint { panic("predict should not be called") }).WithProbabilities([]float64{3, 1})
+	cond.Precede(A, B)
+	A.Precede(J)
+	B.Precede(J)
+	tf.Push(cond, A, B, J)
+
+	costs := map[string]time.Duration{"A": 10 * time.Millisecond, "B": 30 * time.Millisecond}
+	report, err := tf.Simulate(4000, gotaskflow.WithSimSeed(1), gotaskflow.WithSimCost(func(t *gotaskflow.Task) time.Duration {
+		return costs[t.Name()]
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := report.Visits["A"]; v < 0.7 || v > 0.8 {
+		t.Errorf("expected A taken about 75%% of runs, got %v", v)
+	}
+	if report.Visits["J"] != 1 || report.Visits["cond"] != 1 {
+		t.Errorf("expected cond and J run once each run, got %v", report.Visits)
+	}
+	if report.Work < 14*time.Millisecond || report.Work > 16*time.Millisecond || report.Span != report.Work {
+		t.Errorf("expected work and span about 15ms, got %+v", report)
+	}
+
+	// the oracle overrides probabilities
+	report, err = tf.Simulate(10, gotaskflow.WithBranchOracle(func(cond *gotaskflow.Task, visit int) int { return 1 }))
+	if err != nil || report.Visits["A"] != 0 || report.Visits["B"] != 1 {
+		t.Errorf("expected B always taken by oracle, got %+v, %v", report, err)
+	}
+	paths, err := tf.Explore(4)
+	if err != nil || paths != (gotaskflow.ExploreReport{Paths: 2}) {
+		t.Errorf("expected 2 paths explored without error, got %+v, %v", paths, err)
+	}
+
+	// init -> body -> loop: continue to body, or exit
+	tf = gotaskflow.NewTaskFlow("loop")
+	init, body, exit := gotaskflow.NewTask("init", func() {}), gotaskflow.NewTask("body", func() {}), gotaskflow.NewTask("exit", func() {})
+	loop := gotaskflow.NewCondition("loop", func() uint { return 1 }).WithProbabilities([]float64{1, 1})
+	gotaskflow.Chain(init, body, loop)
+	loop.Precede(body, exit)
+	tf.Push(init, body, loop, exit)
+	report, err = tf.Simulate(4000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := report.Visits["body"]; v < 1.8 || v > 2.2 {
+		t.Errorf("expected body run about twice, got %v", v)
+	}
+	if _, err := tf.Simulate(1, gotaskflow.WithSimMaxSteps(100), gotaskflow.WithBranchOracle(func(*gotaskflow.Task, int) int { return 0 })); err == nil {
+		t.Error("expected endless loop reported")
+	}
+	paths, err = tf.Explore(3)
+	if err != nil || paths != (gotaskflow.ExploreReport{Paths: 3, Truncated: 1}) {
+		t.Errorf("expected 3 paths and 1 truncated, got %+v, %v", paths, err)
+	}
+
+	// J joins branches of a looping condition, so that it waits for the untaken one
+	tf = gotaskflow.NewTaskFlow("mixed")
+	start := gotaskflow.NewTask("start", func() {})
+	init, A, B, J = gotaskflow.NewTask("init", func() {}), gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {}), gotaskflow.NewTask("J", func() {})
+	cond = gotaskflow.NewCondition("cond", func() uint { return 0 })
+	gotaskflow.Chain(start, init, cond)
+	cond.Precede(A, B, init)
+	A.Precede(J)
+	B.Precede(J)
+	tf.Push(start, init, cond, A, B, J)
+	_, err = tf.Explore(2)
+	var simErr *gotaskflow.SimulationError
+	if !errors.As(err, &simErr) || !strings.Contains(simErr.Reason, "J") {
+		t.Fatalf("expected J reported stranded, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "[cond->A]") || !strings.Contains(err.Error(), "[cond->init cond->B]") {
+		t.Errorf("expected failed paths reported with choices, got %v", err)
+	}
+
+	cond.WithProbabilities([]float64{1, 1})
+	if _, err := tf.Simulate(1); err == nil || !strings.Contains(err.Error(), "2 probabilities for 3 branches") {
+		t.Errorf("expected mismatched probabilities reported, got %v", err)
+	}
+}
+
+func TestTaskflowStats(t *testing.T) {
+	// A -> {B, C, D}, {B, C} -> E -> sub(X -> {Y, Z, W}), D -> cond -> {F, G}
+	tf := gotaskflow.NewTaskFlow("G")
+	task := func(name string) *gotaskflow.Task { return gotaskflow.NewTask(name, func() {}) }
+	A, B, C, D, E, F, G := task("A"), task("B"), task("C"), task("D"), task("E"), task("F"), task("G")
+	cond := gotaskflow.NewCondition("cond", func() uint { return 0 })
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		X, Y, Z, W := task("X"), task("Y"), task("Z"), task("W")
+		X.Precede(Y, Z, W)
+		sf.Push(X, Y, Z, W)
+	})
+	A.Precede(B, C, D)
+	gotaskflow.FanIn([]*gotaskflow.Task{B, C}, E)
+	E.Precede(sub)
+	D.Precede(cond)
+	cond.Precede(F, G)
+	tf.Push(A, B, C, D, E, F, G, cond, sub)
+
+	if s := tf.Stats(); s.NodeCount != 9 || s.SubflowCount != 1 || s.Sinks != 3 || s.MaxDepth != 4 {
+		t.Errorf("unexpected stats before subflow is instantiated %+v", s)
+	}
+
+	executor.Run(tf).Wait()
+	expected := gotaskflow.GraphStats{
+		Statistics: gotaskflow.Statistics{
+			NodeCount:      13,
+			EdgeCount:      12,
+			SubflowCount:   1,
+			ConditionCount: 1,
+			MaxDepth:       5, // A, B, E, sub/X, sub/Y
+			MaxWidth:       3,
+		},
+		Statics:           11,
+		ConditionBranches: 2,
+		Entries:           2, // A, sub/X
+		Sinks:             6, // F, G, sub, sub/Y, sub/Z, sub/W
+		MaxFanIn:          2,
+		MaxFanInNodes:     []string{"E"},
+		MaxFanOut:         3,
+		MaxFanOutNodes:    []string{"A", "sub/X"},
+	}
+	if s := tf.Stats(); fmt.Sprintf("%+v", s) != fmt.Sprintf("%+v", expected) {
+		t.Errorf("expected stats %+v, got %+v", expected, s)
+	}
+	if s := tf.Stats(); s.Statistics != tf.Statistics() {
+		t.Errorf("expected stats to share Statistics %+v, got %+v", tf.Statistics(), s.Statistics)
+	}
+}
+
+func TestTaskflowExportMarkdown(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("pipeline")
+	load := gotaskflow.NewTask("load", func() {}).Priority(gotaskflow.HIGH).WithLabel("io").Tag("db", "slow")
+	check := gotaskflow.NewStringCondition("check", func() string { return "ok" })
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		X, Y := gotaskflow.NewTask("X", func() {}), gotaskflow.NewTask(`say "hi" | bye`, func() {})
+		X.Precede(Y)
+		sf.Push(X, Y)
+	})
+	fallback := gotaskflow.NewTask("fallback", func() {})
+	load.Precede(check)
+	check.Case("ok", sub).Case("bad", fallback)
+	tf.Push(load, check, sub, fallback)
+
+	expected := "# pipeline\n\n" +
+		"```mermaid\nflowchart LR\n" +
+		"  n0[\"load\"]\n" +
+		"  n1{\"check\"}\n" +
+		"  subgraph n2 [\"sub\"]\n" +
+		"    n3[\"X\"]\n" +
+		"    n4[\"say #quot;hi#quot; | bye\"]\n" +
+		"    n3 --> n4\n" +
+		"  end\n" +
+		"  n5[\"fallback\"]\n" +
+		"  n0 --> n1\n" +
+		"  n1 -.->|\"ok\"| n2\n" +
+		"  n1 -.->|\"bad\"| n5\n" +
+		"```\n\n" +
+		"## Tasks\n\n" +
+		"| Task | Type | Priority | Label | Tags | Dependents |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| load | static | HIGH | io | db, slow |  |\n" +
+		"| check | condition | NORMAL |  |  | load |\n" +
+		"| sub | subflow | NORMAL |  |  | check |\n" +
+		"| fallback | static | NORMAL |  |  | check |\n" +
+		"\n<details>\n<summary>Subflow sub</summary>\n\n" +
+		"### Tasks\n\n" +
+		"| Task | Type | Priority | Label | Tags | Dependents |\n" +
+		"| --- | --- | --- | --- | --- | --- |\n" +
+		"| X | static | NORMAL |  |  |  |\n" +
+		"| say \"hi\" \\| bye | static | NORMAL |  |  | X |\n" +
+		"\n</details>\n"
+	if md := tf.ExportMarkdown(); md != expected {
+		t.Errorf("unexpected markdown:\n%s", md)
+	}
+}
+
+func TestTaskflowMutateBetweenRuns(t *testing.T) {
+	executor := gotaskflow.NewExecutor(1)
+	var mu sync.Mutex
+	order := make([]string, 0)
+	task := func(name string) *gotaskflow.Task {
+		return gotaskflow.NewTask(name, func() {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		})
+	}
+	tf := gotaskflow.NewTaskFlow("G")
+	A, B, C := task("A"), task("B").Priority(gotaskflow.LOW), task("C").Priority(gotaskflow.LOW)
+	tf.Push(A, B, C)
+	executor.Run(tf).Wait()
+	if order[0] != "A" {
+		t.Errorf("expected A first by priority, got %v", order)
+	}
+
+	// C becomes the only entry, and D is pushed with an edge into A
+	order = order[:0]
+	C.Precede(A, B)
+	D := task("D")
+	tf.Push(D)
+	D.Precede(A)
+	A.Priority(gotaskflow.LOW)
+	B.Priority(gotaskflow.HIGH)
+	executor.Run(tf).Wait()
+	if len(order) != 4 || order[0] == "A" || order[0] == "B" || order[2] != "B" || order[3] != "A" {
+		t.Errorf("expected new edges and priorities followed, got %v", order)
+	}
+	if err := tf.Validate(); err != nil {
+		t.Errorf("expected mutated taskflow valid, got %v", err)
+	}
+}
+
+func TestTaskflowSlowestNodes(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	for i := 0; i < 100; i++ {
+		d := time.Duration(i%5) * 100 * time.Microsecond
+		if i >= 97 {
+			d = time.Duration(i-90) * 5 * time.Millisecond
+		}
+		tf.Push(gotaskflow.NewTask(fmt.Sprintf("T%02d", i), func() { time.Sleep(d) }))
+	}
+	names := func(stats []gotaskflow.NodeStat) []string {
+		res := make([]string, 0, len(stats))
+		for _, s := range stats {
+			res = append(res, s.Name)
+		}
+		return res
+	}
+
+	if got := names(tf.SlowestNodes(3)); !slices.Equal(got, []string{"T00", "T01", "T02"}) {
+		t.Errorf("expected tasks by name before any run, got %v", got)
+	}
+
+	executor := gotaskflow.NewExecutor(8)
+	executor.Run(tf).Wait()
+	executor.Run(tf).Wait()
+	top := tf.SlowestNodes(3)
+	if got := names(top); !slices.Equal(got, []string{"T99", "T98", "T97"}) {
+		t.Errorf("expected slowest tasks first, got %v", got)
+	}
+	if top[0].RunCount != 2 || top[0].TotalDuration < 2*45*time.Millisecond {
+		t.Errorf("expected runs of T99 accumulated, got %+v", top[0])
+	}
+	if len(tf.SlowestNodes(1000)) != 100 || len(tf.SlowestNodes(0)) != 0 {
+		t.Error("expected n capped by number of tasks")
+	}
+}
+
+func TestVisualizeRanks(t *testing.T) {
+	tf := gotaskflow.NewTaskFlow("G")
+	init, cond, body, back, done :=
+		gotaskflow.NewTask("init", func() {}), gotaskflow.NewCondition("cond", func() uint { return 1 }),
+		gotaskflow.NewTask("body", func() {}), gotaskflow.NewTask("back", func() {}), gotaskflow.NewTask("done", func() {})
+	sub := gotaskflow.NewSubflow("sub", func(sf *gotaskflow.Subflow) {
+		A, B := gotaskflow.NewTask("A", func() {}), gotaskflow.NewTask("B", func() {})
+		A.Precede(B)
+		sf.Push(A, B)
+	})
+	init.Precede(cond)
+	cond.Precede(body, done)
+	body.Precede(back)
+	back.Precede(cond)
+	done.Precede(sub)
+	tf.Push(init, cond, body, back, done, sub)
+
+	var buf bytes.Buffer
+	if err := gotaskflow.Visualize(tf, &buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	rank := func(name string) string {
+		begin := strings.Index(dot, "subgraph "+name+" {")
+		if begin < 0 {
+			return ""
+		}
+		return dot[begin : begin+strings.Index(dot[begin:], "}")]
+	}
+	// the loop closed by back does not break ranking
+	for name, tasks := range map[string][]string{
+		"rank_G_0": {"init"}, "rank_G_1": {"cond"}, "rank_G_2": {"body", "done"}, "rank_G_3": {"back"},
+		"rank_sub_0": {"A"}, "rank_sub_1": {"B"},
+	} {
+		r := rank(name)
+		if !strings.Contains(r, "rank=same") {
+			t.Errorf("expected %v ranked same, got %q", name, r)
+		}
+		for _, task := range tasks {
+			if !strings.Contains(r, "\t"+task+"\t") {
+				t.Errorf("expected %v in %v, got %q", task, name, r)
+			}
+		}
+	}
+	if strings.Contains(rank("rank_G_3"), "\tsub\t") {
+		t.Error("expected subflow left to its cluster")
+	}
+}
@@ -0,0 +1,104 @@
+package taskflowtest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	gotaskflow "github.com/noneback/go-taskflow"
+)
+
+// FakeClock is a gotaskflow.Clock whose time moves only by Advance, for deterministic timing in tests.
+// A task can stand for work of some duration by advancing it, see gotaskflow.WithClock.
+type FakeClock struct {
+	mu     *sync.Mutex
+	now    time.Time
+	timers []*fakeTimer // pending, in order of when they are due
+}
+
+// NewFakeClock returns a FakeClock starting at start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{mu: &sync.Mutex{}, now: start, timers: make([]*fakeTimer, 0)}
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	f     func()
+}
+
+// Stop removes t from pending timers, reporting false if it has fired or been stopped
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := slices.Index(c.timers, t)
+	if i < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, i, i+1)
+	return true
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc calls f once clock is advanced by d, on the goroutine advancing it. f is called right away
+// on a goroutine of its own if d is not positive, like time.AfterFunc.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) gotaskflow.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	if d <= 0 {
+		go f()
+		return t
+	}
+	i, _ := slices.BinarySearchFunc(c.timers, t.at, func(t *fakeTimer, at time.Time) int {
+		// later than timers due at the same time, which fire in order they are set
+		if t.at.After(at) {
+			return 1
+		}
+		return -1
+	})
+	c.timers = slices.Insert(c.timers, i, t)
+	return t
+}
+
+// Sleep blocks until clock is advanced by d
+func (c *FakeClock) Sleep(d time.Duration) {
+	woken := make(chan struct{})
+	c.AfterFunc(d, func() { close(woken) })
+	<-woken
+}
+
+// Advance moves clock forward by d, calling functions of timers due by then in order, each seeing its own due time
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		if len(c.timers) == 0 || c.timers[0].at.After(end) {
+			if end.After(c.now) {
+				c.now = end
+			}
+			c.mu.Unlock()
+			return
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		c.mu.Unlock()
+		t.f()
+	}
+}
+
+// Pending returns how many timers are waiting to fire, such as to wait until a sleeper is blocked before advancing
+func (c *FakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
@@ -0,0 +1,46 @@
+package taskflowtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := NewFakeClock(start)
+	fired := make([]string, 0)
+	record := func(name string) func() {
+		return func() { fired = append(fired, fmt.Sprintf("%v@%v", name, clk.Now().Sub(start))) }
+	}
+	clk.AfterFunc(2*time.Second, record("b"))
+	clk.AfterFunc(time.Second, record("a"))
+	clk.AfterFunc(2*time.Second, record("c"))
+	stopped := clk.AfterFunc(time.Second, record("stopped"))
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("expected pending timer stopped once")
+	}
+
+	clk.Advance(1500 * time.Millisecond)
+	if fmt.Sprint(fired) != "[a@1s]" || clk.Now().Sub(start) != 1500*time.Millisecond {
+		t.Errorf("unexpected timers fired %v at %v", fired, clk.Now())
+	}
+	clk.Advance(time.Hour)
+	if fmt.Sprint(fired) != "[a@1s b@2s c@2s]" || clk.Pending() != 0 {
+		t.Errorf("expected timers fired in order, got %v", fired)
+	}
+
+	base := clk.Now()
+	woken := make(chan time.Time)
+	go func() {
+		clk.Sleep(time.Minute)
+		woken <- clk.Now()
+	}()
+	for clk.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(time.Minute)
+	if at := <-woken; at.Sub(base) != time.Minute {
+		t.Errorf("expected sleeper woken a minute later, got %v", at.Sub(base))
+	}
+}
@@ -0,0 +1,170 @@
+// Package taskflowtest helps testing taskflows, by recording tasks run by executor and asserting their order.
+package taskflowtest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	gotaskflow "github.com/noneback/go-taskflow"
+)
+
+// EventKind is a step of task lifecycle recorded by Recorder
+type EventKind int
+
+const (
+	Started EventKind = iota
+	Finished
+	Failed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Started:
+		return "started"
+	case Finished:
+		return "finished"
+	case Failed:
+		return "failed"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Event is a recorded step of a task, Seq orders events of a Recorder
+type Event struct {
+	Task string
+	Kind EventKind
+	Seq  int
+}
+
+// Recorder records tasks started and finished by executor, register it via Option.
+// Tasks are told apart by name, tasks in subflows included, so that names should be unique in tested taskflows.
+type Recorder struct {
+	gotaskflow.BaseObserver
+	mu     *sync.Mutex
+	events []Event
+}
+
+// NewRecorder returns an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{mu: &sync.Mutex{}, events: make([]Event, 0)}
+}
+
+// Option registers r as observer of executor, such as gotaskflow.NewExecutor(4, rec.Option())
+func (r *Recorder) Option() gotaskflow.Option {
+	return gotaskflow.WithObserver(r)
+}
+
+func (r *Recorder) OnStarted(task *gotaskflow.Task) {
+	r.record(task.Name(), Started)
+}
+
+func (r *Recorder) OnFinished(task *gotaskflow.Task, failed bool) {
+	if failed {
+		r.record(task.Name(), Failed)
+	} else {
+		r.record(task.Name(), Finished)
+	}
+}
+
+func (r *Recorder) record(name string, kind EventKind) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, Event{Task: name, Kind: kind, Seq: len(r.events)})
+}
+
+// Events returns recorded events in order
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]Event, len(r.events))
+	copy(res, r.events)
+	return res
+}
+
+// Order returns names of tasks in the order they started, a task appearing once per run
+func (r *Recorder) Order() []string {
+	res := make([]string, 0)
+	for _, ev := range r.Events() {
+		if ev.Kind == Started {
+			res = append(res, ev.Task)
+		}
+	}
+	return res
+}
+
+// Runs returns how many times task named name started
+func (r *Recorder) Runs(name string) int {
+	return len(r.spans(name))
+}
+
+// Reset drops recorded events, such as between runs of a test
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = r.events[:0]
+}
+
+// span is a run of a task, end is -1 if it has not finished
+type span struct {
+	begin, end int
+}
+
+// spans returns runs of task named name in order
+func (r *Recorder) spans(name string) []span {
+	res := make([]span, 0)
+	for _, ev := range r.Events() {
+		if ev.Task != name {
+			continue
+		}
+		if ev.Kind == Started {
+			res = append(res, span{begin: ev.Seq, end: -1})
+		} else if len(res) > 0 && res[len(res)-1].end < 0 {
+			res[len(res)-1].end = ev.Seq
+		}
+	}
+	return res
+}
+
+// AssertBefore fails t unless the first run of before finished ahead of the first run of after started
+func (r *Recorder) AssertBefore(t testing.TB, before, after string) {
+	t.Helper()
+	b, a := r.spans(before), r.spans(after)
+	switch {
+	case len(b) == 0:
+		t.Errorf("expected %v to run before %v, but %v never ran", before, after, before)
+	case len(a) == 0:
+		t.Errorf("expected %v to run before %v, but %v never ran", before, after, after)
+	case b[0].end < 0 || b[0].end > a[0].begin:
+		t.Errorf("expected %v to finish before %v started, got order %v", before, after, r.Order())
+	}
+}
+
+// AssertConcurrent fails t unless a run of a overlapped a run of b
+func (r *Recorder) AssertConcurrent(t testing.TB, a, b string) {
+	t.Helper()
+	for _, x := range r.spans(a) {
+		for _, y := range r.spans(b) {
+			if overlap(x, y) {
+				return
+			}
+		}
+	}
+	t.Errorf("expected %v and %v to run concurrently, got events %v", a, b, r.Events())
+}
+
+func overlap(x, y span) bool {
+	// an unfinished run lasts to the end
+	ends := func(s span, at int) bool { return s.end >= 0 && s.end < at }
+	return !ends(x, y.begin) && !ends(y, x.begin)
+}
+
+// AssertNeverRan fails t if any of tasks named names ran, such as those on an untaken branch
+func (r *Recorder) AssertNeverRan(t testing.TB, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if n := r.Runs(name); n > 0 {
+			t.Errorf("expected %v never to run, but it ran %v times", name, n)
+		}
+	}
+}
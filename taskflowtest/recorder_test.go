@@ -0,0 +1,66 @@
+package taskflowtest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	gotaskflow "github.com/noneback/go-taskflow"
+)
+
+// fakeTB collects failures instead of failing the test
+type fakeTB struct {
+	testing.TB
+	errs []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errs = append(f.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder()
+	executor := gotaskflow.NewExecutor(4, rec.Option())
+
+	// load -> {a, b} -> cond -> {transform, skipped}, a and b wait for each other to run together
+	tf := gotaskflow.NewTaskFlow("G")
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	meet := func() {
+		wg.Done()
+		wg.Wait()
+	}
+	load := gotaskflow.NewTask("load", func() {})
+	a, b := gotaskflow.NewTask("a", meet), gotaskflow.NewTask("b", meet)
+	cond := gotaskflow.NewCondition("cond", func() uint { return 0 })
+	transform, skipped := gotaskflow.NewTask("transform", func() {}), gotaskflow.NewTask("skipped", func() {})
+	load.Precede(a, b)
+	a.Precede(cond)
+	b.Precede(cond)
+	cond.Precede(transform, skipped)
+	tf.Push(load, a, b, cond, transform, skipped)
+	executor.Run(tf).Wait()
+
+	rec.AssertBefore(t, "load", "transform")
+	rec.AssertConcurrent(t, "a", "b")
+	rec.AssertNeverRan(t, "skipped")
+	if order := rec.Order(); len(order) != 5 || order[0] != "load" || order[4] != "transform" {
+		t.Errorf("unexpected order %v", order)
+	}
+
+	fake := &fakeTB{TB: t}
+	rec.AssertBefore(fake, "transform", "load")
+	rec.AssertBefore(fake, "load", "missing")
+	rec.AssertConcurrent(fake, "load", "transform")
+	rec.AssertNeverRan(fake, "cond")
+	if len(fake.errs) != 4 {
+		t.Errorf("expected 4 failed assertions, got %v", fake.errs)
+	}
+
+	rec.Reset()
+	if len(rec.Events()) != 0 || rec.Runs("load") != 0 {
+		t.Errorf("expected no events after reset, got %v", rec.Events())
+	}
+}
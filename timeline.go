@@ -0,0 +1,198 @@
+package gotaskflow
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/noneback/go-taskflow/utils"
+)
+
+// TimelineFormat is the output format of ProfileTimeline
+type TimelineFormat int
+
+const (
+	TimelineText        TimelineFormat = iota // rows of unicode bars, for terminals
+	TimelineSVG                               // svg document, for reports
+	TimelineTextByLayer                       // as TimelineText, one row per topological layer instead of per worker
+	TimelineSVGByLayer                        // as TimelineSVG, one row per topological layer instead of per worker
+)
+
+const (
+	kTimelineTextWidth = 80
+	kTimelineSVGWidth  = 1000
+	kTimelineSVGRow    = 24
+	kTimelineSVGLabel  = 80
+)
+
+// timeline lays out spans in rows, one row per pool worker or per topological layer
+type timeline struct {
+	begin time.Time
+	end   time.Time
+	rows  [][]*span
+	label string // of rows
+}
+
+func newTimeline(spans []*span, byLayer bool) *timeline {
+	tl := &timeline{label: "worker"}
+	row := func(s *span) int { return s.worker }
+	if byLayer {
+		tl.label = "layer"
+		row = newLayering().of
+	}
+	if len(spans) == 0 {
+		return tl
+	}
+
+	spans = slices.Clone(spans)
+	sortSpans(spans)
+
+	tl.begin, tl.end = spans[0].begin, spans[0].begin
+	for _, s := range spans {
+		if end := s.begin.Add(s.cost); end.After(tl.end) {
+			tl.end = end
+		}
+		r := row(s)
+		for len(tl.rows) <= r {
+			tl.rows = append(tl.rows, make([]*span, 0))
+		}
+		tl.rows[r] = append(tl.rows[r], s)
+	}
+	return tl
+}
+
+// layering finds topological layer of span nodes. Tasks of a subflow are placed in layers after the subflow task,
+// and tasks in a cycle go after the layers of their graph.
+type layering struct {
+	layers map[*eGraph]map[*innerNode]int
+	depth  map[*eGraph]int // count of layers
+}
+
+func newLayering() *layering {
+	return &layering{
+		layers: make(map[*eGraph]map[*innerNode]int),
+		depth:  make(map[*eGraph]int),
+	}
+}
+
+func (l *layering) of(s *span) int {
+	if s.node == nil {
+		return 0
+	}
+	offset := 0
+	if s.parent != nil {
+		offset = l.of(s.parent) + 1
+	}
+	return offset + l.layer(s.node)
+}
+
+func (l *layering) layer(n *innerNode) int {
+	if _, ok := l.layers[n.g]; !ok {
+		layers, _ := n.g.Layers()
+		m := make(map[*innerNode]int)
+		for i, layer := range layers {
+			for _, node := range layer {
+				m[node] = i
+			}
+		}
+		l.layers[n.g], l.depth[n.g] = m, len(layers)
+	}
+	if i, ok := l.layers[n.g][n]; ok {
+		return i
+	}
+	return l.depth[n.g]
+}
+
+func (tl *timeline) total() time.Duration {
+	return tl.end.Sub(tl.begin)
+}
+
+// scale maps offset d from timeline begin to [0, width]
+func (tl *timeline) scale(d time.Duration, width int) int {
+	if tl.total() <= 0 {
+		return 0
+	}
+	return int(int64(d) * int64(width) / int64(tl.total()))
+}
+
+func (tl *timeline) writeText(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "timeline %v, %d %ss\n", utils.NormalizeDuration(tl.total()), len(tl.rows), tl.label)
+	for i, row := range tl.rows {
+		bar := []rune(strings.Repeat("·", kTimelineTextWidth))
+		for _, s := range row {
+			from := tl.scale(s.begin.Sub(tl.begin), kTimelineTextWidth)
+			to := max(tl.scale(s.begin.Add(s.cost).Sub(tl.begin), kTimelineTextWidth), from+1)
+			for i := from; i < to && i < kTimelineTextWidth; i++ {
+				bar[i] = '█'
+			}
+		}
+		fmt.Fprintf(&sb, "%s %-3d |%s|\n", tl.label, i, string(bar))
+		for _, s := range row {
+			fmt.Fprintf(&sb, "  %s,%s +%v cost %v\n", s.extra.typ, s.extra.name,
+				utils.NormalizeDuration(s.begin.Sub(tl.begin)), utils.NormalizeDuration(s.cost))
+		}
+	}
+
+	if _, err := w.Write([]byte(sb.String())); err != nil {
+		return fmt.Errorf("write timeline -> %w", err)
+	}
+	return nil
+}
+
+func (tl *timeline) writeSVG(w io.Writer) error {
+	var sb strings.Builder
+	width, height := kTimelineSVGLabel+kTimelineSVGWidth, kTimelineSVGRow*(len(tl.rows)+1)
+	fmt.Fprintf(&sb, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"11\">\n", width, height)
+	fmt.Fprintf(&sb, "<text x=\"0\" y=\"%d\">timeline %s</text>\n", kTimelineSVGRow-8, html.EscapeString(utils.NormalizeDuration(tl.total())))
+	for i, row := range tl.rows {
+		y := kTimelineSVGRow * (i + 1)
+		fmt.Fprintf(&sb, "<text x=\"0\" y=\"%d\">%s %d</text>\n", y+kTimelineSVGRow-8, tl.label, i)
+		for _, s := range row {
+			x := kTimelineSVGLabel + tl.scale(s.begin.Sub(tl.begin), kTimelineSVGWidth)
+			barWidth := max(tl.scale(s.cost, kTimelineSVGWidth), 1)
+			label := html.EscapeString(fmt.Sprintf("%s,%s,cost %v", s.extra.typ, s.extra.name, utils.NormalizeDuration(s.cost)))
+			fmt.Fprintf(&sb, "<g><title>%s</title><rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\" stroke=\"white\"/>", label, x, y, barWidth, kTimelineSVGRow-4, timelineColor(s.extra.typ))
+			fmt.Fprintf(&sb, "<text x=\"%d\" y=\"%d\">%s</text></g>\n", x+2, y+kTimelineSVGRow-10, html.EscapeString(s.extra.name))
+		}
+	}
+	sb.WriteString("</svg>\n")
+
+	if _, err := w.Write([]byte(sb.String())); err != nil {
+		return fmt.Errorf("write timeline -> %w", err)
+	}
+	return nil
+}
+
+func timelineColor(typ nodeType) string {
+	switch typ {
+	case nodeSubflow:
+		return "#BDBDBD"
+	case nodeCondition:
+		return "#A5D6A7"
+	default:
+		return "#90CAF9"
+	}
+}
+
+func (t *profiler) timeline(w io.Writer, format TimelineFormat) error {
+	t.mu.Lock()
+	spans := make([]*span, 0, len(t.spans))
+	for _, s := range t.spans {
+		spans = append(spans, s)
+	}
+	t.mu.Unlock()
+
+	tl := newTimeline(spans, format == TimelineTextByLayer || format == TimelineSVGByLayer)
+	switch format {
+	case TimelineText, TimelineTextByLayer:
+		return tl.writeText(w)
+	case TimelineSVG, TimelineSVGByLayer:
+		return tl.writeSVG(w)
+	default:
+		return fmt.Errorf("unsupported timeline format %v", format)
+	}
+}
@@ -0,0 +1,99 @@
+package gotaskflow
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is the failure of a task exceeding its timeout, see Task.WithTimeout
+var ErrTimeout = errors.New("task timed out")
+
+// WithTimeout fails task and cancels its taskflow once task runs longer than d. Handles are not interrupted,
+// controlled tasks can notice it by TaskControl.Canceled. For subflow, d covers its handle and its tasks,
+// which are canceled with the subflow graph. Zero means no timeout.
+func (t *Task) WithTimeout(d time.Duration) *Task {
+	t.node.timeout = d
+	return t
+}
+
+// startTimeout starts the timer of node if it has a timeout. When the timer fires, graph of node fails,
+// and so is canceled sub, the graph of a subflow node. stop stops the timer, reporting whether it fired.
+func (e *innerExecutorImpl) startTimeout(node *innerNode, sub *eGraph) (stop func() (fired bool)) {
+	if node.timeout <= 0 {
+		return func() bool { return false }
+	}
+	timer := e.clock.AfterFunc(node.timeout, func() {
+		if sub != nil {
+			// nested graphs see it by isCanceled, and their nodes may still be being pushed by handle
+			sub.canceled.Store(true)
+			sub.scheCond.Broadcast()
+		}
+		node.g.fail(fmt.Errorf("%v %v -> %w after %v", node.Typ, node.name, ErrTimeout, node.timeout))
+		node.g.scheCond.Signal()
+	})
+	return func() bool {
+		return !timer.Stop()
+	}
+}
+
+// WithSoftDeadline warns once task runs longer than d, without failing or canceling anything. The warning is written
+// into logger of executor and sent to observers implementing SoftDeadlineObserver, and span of the run is flagged, see SpanInfo.OverDeadline.
+// For subflow, d covers its handle and its tasks. Zero means no soft deadline.
+func (t *Task) WithSoftDeadline(d time.Duration) *Task {
+	t.node.softDeadline = d
+	return t
+}
+
+// startSoftDeadline starts the warning timer of node if it has a soft deadline. stop stops the timer, reporting whether it fired,
+// that is node ran over its soft deadline, in which case stop waits for the warning, so that it comes before node finishes.
+func (e *innerExecutorImpl) startSoftDeadline(node *innerNode) (stop func() (fired bool)) {
+	if node.softDeadline <= 0 {
+		return func() bool { return false }
+	}
+	warned := make(chan struct{})
+	timer := e.clock.AfterFunc(node.softDeadline, func() {
+		defer close(warned)
+		e.reporter().log("soft deadline", fmt.Sprintf("%v %v in graph %v, run %d, runs longer than %v",
+			node.Typ, node.name, node.g.name, node.g.runID.Load(), node.softDeadline),
+			"task", node.name, "graph", node.g.name, "run", node.g.runID.Load(), "deadline", node.softDeadline)
+		for _, obs := range e.observers {
+			if obs, ok := obs.(SoftDeadlineObserver); ok {
+				obs.OnSoftDeadline(&Task{node: node}, node.softDeadline)
+			}
+		}
+	})
+	return func() bool {
+		if timer.Stop() {
+			return false
+		}
+		<-warned
+		return true
+	}
+}
+
+// ErrDeadlineExceeded is the failure of a taskflow not finished by its deadline, see TaskFlow.SetDeadline
+var ErrDeadlineExceeded = errors.New("taskflow deadline exceeded")
+
+// SetDeadline cancels taskflow if it is still running at d, failing the run with ErrDeadlineExceeded.
+// A run starting after d is canceled right away. Subflows are bound by the deadline too. Zero d means no deadline.
+func (tf *TaskFlow) SetDeadline(d time.Time) {
+	tf.graph.deadline = d
+}
+
+// checkDeadline fails g, or a graph it is nested in, whose deadline is passed by now
+func (g *eGraph) checkDeadline(now time.Time) {
+	for cur := g; cur != nil; cur = cur.parent {
+		if !cur.deadline.IsZero() && !now.Before(cur.deadline) {
+			cur.exceed()
+		}
+	}
+}
+
+// exceed fails g by its deadline, unless it already failed
+func (g *eGraph) exceed() {
+	if g.failure.Load() == nil {
+		g.fail(fmt.Errorf("%v -> %w at %v", g.name, ErrDeadlineExceeded, g.deadline.Format(time.RFC3339Nano)))
+	}
+	g.scheCond.Broadcast()
+}
@@ -0,0 +1,137 @@
+package gotaskflow
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Topology is a read-only snapshot of taskflow structure, not affected by later changes of taskflow
+type Topology struct {
+	Name  string
+	Nodes []TopologyNode
+	Edges []TopologyEdge
+}
+
+// TopologyNode describes a task
+type TopologyNode struct {
+	Name     string
+	Type     string // static, condition or subflow
+	Priority TaskPriority
+	Tags     []string
+	Subflow  *Topology // graph of subflow, nil unless subflow is instantiated
+}
+
+// TopologyEdge is a dependency from task From to task To
+type TopologyEdge struct {
+	From   string
+	To     string
+	Branch int    // index of branch if From is a condition, -1 for strong dependency
+	Label  string // label of branch, which is the key for string condition
+}
+
+// Kind returns "strong" or "condition-branch-N"
+func (e TopologyEdge) Kind() string {
+	if e.Branch < 0 {
+		return "strong"
+	}
+	return fmt.Sprintf("condition-branch-%d", e.Branch)
+}
+
+// Topology returns a snapshot of taskflow structure, including instantiated subflows
+func (tf *TaskFlow) Topology() *Topology {
+	return tf.graph.topology()
+}
+
+func (g *eGraph) topology() *Topology {
+	topo := &Topology{
+		Name:  g.name,
+		Nodes: make([]TopologyNode, 0, len(g.nodes)),
+		Edges: make([]TopologyEdge, 0),
+	}
+
+	for _, n := range g.nodes {
+		node := TopologyNode{
+			Name:     n.name,
+			Type:     string(n.Typ),
+			Priority: n.priority,
+			Tags:     slices.Clone(n.tags),
+		}
+		if p, ok := n.ptr.(*Subflow); ok && p.g.instancelized {
+			node.Subflow = p.g.topology()
+		}
+		topo.Nodes = append(topo.Nodes, node)
+
+		cond, isCond := n.ptr.(*Condition)
+		for idx, succ := range n.successors {
+			edge := TopologyEdge{From: n.name, To: succ.name, Branch: -1}
+			if isCond {
+				edge.Branch, edge.Label = idx, cond.label(idx, succ)
+			}
+			topo.Edges = append(topo.Edges, edge)
+		}
+	}
+	return topo
+}
+
+// GraphStats is complexity of a taskflow, such as to hold flows to a complexity budget. It extends Statistics,
+// whose counts and MaxDepth it shares, with entries, sinks, fan-in and fan-out computed from Topology.
+// Instantiated subflows are counted in, whose tasks are named by path like "sub/task".
+type GraphStats struct {
+	Statistics
+	Statics           int      `json:"statics"`
+	ConditionBranches int      `json:"condition_branches"` // edges out of conditions
+	Entries           int      `json:"entries"`            // tasks without dependents, in taskflow or any subflow
+	Sinks             int      `json:"sinks"`              // tasks without successors, in taskflow or any subflow
+	MaxFanIn          int      `json:"max_fan_in"`
+	MaxFanInNodes     []string `json:"max_fan_in_nodes"` // tasks with MaxFanIn dependents, sorted
+	MaxFanOut         int      `json:"max_fan_out"`
+	MaxFanOutNodes    []string `json:"max_fan_out_nodes"` // tasks with MaxFanOut successors, sorted
+}
+
+// Stats returns complexity of taskflow, see GraphStats
+func (tf *TaskFlow) Stats() GraphStats {
+	s := GraphStats{Statistics: tf.Statistics(), MaxFanInNodes: make([]string, 0), MaxFanOutNodes: make([]string, 0)}
+	tf.Topology().stats(&s, "")
+	slices.Sort(s.MaxFanInNodes)
+	slices.Sort(s.MaxFanOutNodes)
+	return s
+}
+
+// stats adds complexity of t beyond Statistics to s, naming its tasks by prefix
+func (t *Topology) stats(s *GraphStats, prefix string) {
+	fanIn, fanOut := make(map[string]int, len(t.Nodes)), make(map[string]int, len(t.Nodes))
+	for _, e := range t.Edges {
+		fanIn[e.To]++
+		fanOut[e.From]++
+		if e.Branch >= 0 {
+			s.ConditionBranches++
+		}
+	}
+	// fan reports a new maximum, or ties with the current one
+	fan := func(v, cur int, nodes []string, name string) (int, []string) {
+		switch {
+		case v > cur:
+			return v, []string{name}
+		case v == cur && v > 0:
+			return cur, append(nodes, name)
+		}
+		return cur, nodes
+	}
+
+	for _, n := range t.Nodes {
+		if nodeType(n.Type) == nodeStatic {
+			s.Statics++
+		}
+		if fanIn[n.Name] == 0 {
+			s.Entries++
+		}
+		if fanOut[n.Name] == 0 {
+			s.Sinks++
+		}
+		s.MaxFanIn, s.MaxFanInNodes = fan(fanIn[n.Name], s.MaxFanIn, s.MaxFanInNodes, prefix+n.Name)
+		s.MaxFanOut, s.MaxFanOutNodes = fan(fanOut[n.Name], s.MaxFanOut, s.MaxFanOutNodes, prefix+n.Name)
+		if n.Subflow != nil {
+			n.Subflow.stats(s, prefix+n.Name+"/")
+		}
+	}
+}
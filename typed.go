@@ -0,0 +1,78 @@
+package gotaskflow
+
+import "sync"
+
+// TypedTask is a static task computing Out from In, where In is either set by SetInput or
+// piped from output of an upstream TypedTask, see Pipe.
+type TypedTask[In, Out any] struct {
+	*Task
+	fn     func(In) Out
+	in     In
+	out    Out
+	source func() In // output of upstream, overrides in if set
+	mu     *sync.Mutex
+}
+
+// NewTypedTask returns a typed static task running fn
+func NewTypedTask[In, Out any](name string, fn func(In) Out) *TypedTask[In, Out] {
+	t := &TypedTask[In, Out]{
+		fn: fn,
+		mu: &sync.Mutex{},
+	}
+	t.Task = NewTask(name, t.run)
+	t.node.origin = fn
+	return t
+}
+
+func (t *TypedTask[In, Out]) run() {
+	t.mu.Lock()
+	in, source := t.in, t.source
+	t.mu.Unlock()
+
+	if source != nil {
+		in = source()
+	}
+	out := t.fn(in)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.out = out
+}
+
+// SetInput sets input of task, ignored if task is piped from an upstream
+func (t *TypedTask[In, Out]) SetInput(v In) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.in = v
+}
+
+// Output returns output of task in latest run, zero value before task runs
+func (t *TypedTask[In, Out]) Output() Out {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.out
+}
+
+// Pipe makes down depend on up, taking output of up as its input
+func Pipe[A, B, C any](up *TypedTask[A, B], down *TypedTask[B, C]) {
+	up.Precede(down.Task)
+
+	down.mu.Lock()
+	defer down.mu.Unlock()
+	down.source = up.Output
+}
+
+// NewSource returns a typed task producing a value from nothing, the head of a typed pipeline
+func NewSource[T any](name string, fn func() T) *TypedTask[struct{}, T] {
+	return NewTypedTask(name, func(struct{}) T {
+		return fn()
+	})
+}
+
+// Map returns a typed task computing fn on output of t, which depends on t.
+// The returned task still has to be pushed into a taskflow.
+func Map[In, T, R any](t *TypedTask[In, T], name string, fn func(T) R) *TypedTask[T, R] {
+	next := NewTypedTask(name, fn)
+	Pipe(t, next)
+	return next
+}
@@ -11,7 +11,7 @@ import (
 
 type cotask struct {
 	ctx *context.Context
-	f   func()
+	f   func(worker int)
 }
 
 func (ct *cotask) zero() {
@@ -27,6 +27,17 @@ type Copool struct {
 	coworker     atomic.Int32
 	mu           *sync.Mutex
 	taskObjPool  *ObjectPool[*cotask]
+	workerIDs    []bool // ids in use, a worker takes the smallest free one
+	workerInit   func() any
+	locals       []any // slot of each worker id, set by workerInit
+}
+
+// workers maps id of worker goroutines of pools with worker init to their slots
+var workers sync.Map
+
+type workerRef struct {
+	cp *Copool
+	id int
 }
 
 // NewCopool return a goroutinue pool with specified cap
@@ -52,9 +63,21 @@ func (cp *Copool) Go(f func()) {
 
 // CtxGo executes f and accepts the context.
 func (cp *Copool) CtxGo(ctx *context.Context, f func()) {
+	cp.CtxGoWorker(ctx, func(int) { f() })
+}
+
+// GoWorker executes f, passing the id of worker goroutine running it.
+// Ids are small and reused after a worker exits, a live worker keeps its id.
+func (cp *Copool) GoWorker(f func(worker int)) {
+	ctx := context.Background()
+	cp.CtxGoWorker(&ctx, f)
+}
+
+// CtxGoWorker executes f with the worker id and accepts the context.
+func (cp *Copool) CtxGoWorker(ctx *context.Context, f func(worker int)) {
 	cp.corun.Add(1)
 	task := cp.taskObjPool.Get()
-	task.f = func() {
+	task.f = func(worker int) {
 		defer func() {
 			if r := recover(); r != nil {
 				if cp.panicHandler != nil {
@@ -67,7 +90,7 @@ func (cp *Copool) CtxGo(ctx *context.Context, f func()) {
 			}
 		}()
 		defer cp.corun.Add(-1)
-		f()
+		f(worker)
 	}
 
 	task.ctx = ctx
@@ -80,6 +103,13 @@ func (cp *Copool) CtxGo(ctx *context.Context, f func()) {
 
 		go func() {
 			defer cp.coworker.Add(-1)
+			worker := cp.acquireWorkerID()
+			defer cp.releaseWorkerID(worker)
+			if cp.workerInit != nil {
+				gid := GoroutineID()
+				workers.Store(gid, workerRef{cp: cp, id: worker})
+				defer workers.Delete(gid)
+			}
 
 			for {
 				cp.mu.Lock()
@@ -90,7 +120,7 @@ func (cp *Copool) CtxGo(ctx *context.Context, f func()) {
 
 				task := cp.taskQ.PeakAndTake()
 				cp.mu.Unlock()
-				task.f()
+				task.f(worker)
 				task.zero()
 				cp.taskObjPool.Put(task)
 			}
@@ -102,6 +132,59 @@ func (cp *Copool) CtxGo(ctx *context.Context, f func()) {
 
 }
 
+func (cp *Copool) acquireWorkerID() int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for id, used := range cp.workerIDs {
+		if !used {
+			cp.workerIDs[id] = true
+			return id
+		}
+	}
+	cp.workerIDs = append(cp.workerIDs, true)
+	id := len(cp.workerIDs) - 1
+	if cp.workerInit != nil {
+		cp.locals = append(cp.locals, nil)
+		cp.mu.Unlock()
+		local := cp.workerInit()
+		cp.mu.Lock()
+		cp.locals[id] = local
+	}
+	return id
+}
+
+func (cp *Copool) releaseWorkerID(id int) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.workerIDs[id] = false
+}
+
+// SetLIFO makes pool run the latest submitted task first, it must be called before any task is submitted.
+func (cp *Copool) SetLIFO() *Copool {
+	cp.taskQ = NewLIFOQueue[*cotask]()
+	return cp
+}
+
+// SetWorkerInit sets init, called before a worker id runs its first task. Its result is kept in the slot of the id,
+// which is handed over to later workers of the same id, see Local. It must be called before any task is submitted.
+func (cp *Copool) SetWorkerInit(init func() any) *Copool {
+	cp.workerInit = init
+	return cp
+}
+
+// Local returns the slot of pool worker running the calling goroutine, false if it is not a worker of a pool with worker init.
+// A slot is only used by one goroutine at a time.
+func Local() (any, bool) {
+	v, ok := workers.Load(GoroutineID())
+	if !ok {
+		return nil, false
+	}
+	ref := v.(workerRef)
+	ref.cp.mu.Lock()
+	defer ref.cp.mu.Unlock()
+	return ref.cp.locals[ref.id], true
+}
+
 // SetPanicHandler sets the panic handler.
 func (cp *Copool) SetPanicHandler(f func(*context.Context, interface{})) *Copool {
 	cp.panicHandler = f
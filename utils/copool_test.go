@@ -112,3 +112,113 @@ func BenchmarkGo(b *testing.B) {
 		wg.Wait()
 	}
 }
+
+func TestPoolWorkerID(t *testing.T) {
+	p := NewCopool(4)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	running := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		p.GoWorker(func(worker int) {
+			defer wg.Done()
+			mu.Lock()
+			if running[worker] {
+				t.Errorf("worker id %v is used by two running workers", worker)
+			}
+			running[worker] = true
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running[worker] = false
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+}
+
+func TestPoolWorkerLocal(t *testing.T) {
+	var created atomic.Int32
+	p := NewCopool(4).SetWorkerInit(func() any {
+		return created.Add(1)
+	})
+	if _, ok := Local(); ok {
+		t.Errorf("expected no slot outside of pool")
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	slots := make(map[int]any)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		p.GoWorker(func(worker int) {
+			defer wg.Done()
+			local, ok := Local()
+			if !ok {
+				t.Errorf("expected slot of worker %v", worker)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if prev, ok := slots[worker]; ok && prev != local {
+				t.Errorf("worker id %v got slot %v, then %v", worker, prev, local)
+			}
+			slots[worker] = local
+		})
+	}
+	wg.Wait()
+	if created.Load() > 4 || int(created.Load()) != len(slots) {
+		t.Errorf("expected one slot per worker id, created %v for %v ids", created.Load(), len(slots))
+	}
+}
+
+func TestPoolWorkerSlowInit(t *testing.T) {
+	var created atomic.Int32
+	p := NewCopool(8).SetWorkerInit(func() any {
+		time.Sleep(20 * time.Millisecond)
+		return created.Add(1)
+	})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	slots := make(map[int]any)
+	start := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		p.GoWorker(func(worker int) {
+			defer wg.Done()
+			local, _ := Local()
+			mu.Lock()
+			if prev, ok := slots[worker]; ok {
+				t.Errorf("worker id %v is used by two running workers, slots %v and %v", worker, prev, local)
+			}
+			slots[worker] = local
+			mu.Unlock()
+			<-start
+		})
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(start)
+	wg.Wait()
+
+	seen := make(map[any]bool)
+	for worker, local := range slots {
+		if local == nil || seen[local] {
+			t.Errorf("worker id %v got slot %v, expected its own", worker, local)
+		}
+		seen[local] = true
+	}
+	if len(slots) != 8 || created.Load() != 8 {
+		t.Errorf("expected 8 worker ids with own slots, got %v ids, created %v", len(slots), created.Load())
+	}
+}
+
+func TestGoroutineID(t *testing.T) {
+	ids := make(chan int, 2)
+	go func() { ids <- GoroutineID() }()
+	go func() { ids <- GoroutineID() }()
+	a, b := <-ids, <-ids
+	if a <= 0 || b <= 0 || a == b || GoroutineID() <= 0 {
+		t.Errorf("unexpected goroutine ids %v, %v", a, b)
+	}
+}
@@ -1,45 +1,112 @@
 package utils
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/eapache/queue/v2"
 )
 
+// ErrQueueFull is returned by PutWithLimit if queue is full
+var ErrQueueFull = errors.New("queue is full")
+
 // thread safe Queue
 type Queue[T any] struct {
-	q  *queue.Queue[T]
-	mu *sync.Mutex
+	q     *queue.Queue[T]
+	stack []T // used instead of q if lifo
+	lifo  bool
+	mu    *sync.Mutex
+	empty *sync.Cond
 }
 
 func NewQueue[T any]() *Queue[T] {
+	mu := &sync.Mutex{}
 	return &Queue[T]{
-		q:  queue.New[T](),
-		mu: &sync.Mutex{},
+		q:     queue.New[T](),
+		mu:    mu,
+		empty: sync.NewCond(mu),
+	}
+}
+
+// NewLIFOQueue returns a Queue whose PeakAndTake takes the latest put element
+func NewLIFOQueue[T any]() *Queue[T] {
+	q := NewQueue[T]()
+	q.lifo = true
+	return q
+}
+
+func (q *Queue[T]) length() int {
+	if q.lifo {
+		return len(q.stack)
 	}
+	return q.q.Length()
 }
 
 func (q *Queue[T]) Peak() T {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	if q.lifo {
+		return q.stack[len(q.stack)-1]
+	}
 	return q.q.Peek()
 }
 
 func (q *Queue[T]) Len() int32 {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	return int32(q.q.Length())
+	return int32(q.length())
 }
 
 func (q *Queue[T]) Put(data T) {
+	q.PutWithLimit(data, 0)
+}
+
+// PutWithLimit puts data unless queue holds limit elements already, then it returns ErrQueueFull.
+// A limit of zero or less means no limit.
+func (q *Queue[T]) PutWithLimit(data T, limit int) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	if limit > 0 && q.length() >= limit {
+		return ErrQueueFull
+	}
+	if q.lifo {
+		q.stack = append(q.stack, data)
+		return nil
+	}
 	q.q.Add(data)
+	return nil
 }
 
+// PeakAndTake takes the next element, or returns the zero value if queue is empty,
+// as consumers sharing the queue may race for its last element
 func (q *Queue[T]) PeakAndTake() T {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	return q.q.Remove()
+	var data T
+	if q.length() == 0 {
+		return data
+	}
+	if q.lifo {
+		var zero T
+		data = q.stack[len(q.stack)-1]
+		q.stack[len(q.stack)-1] = zero
+		q.stack = q.stack[:len(q.stack)-1]
+	} else {
+		data = q.q.Remove()
+	}
+	if q.length() == 0 {
+		q.empty.Broadcast()
+	}
+	return data
+}
+
+// WaitEmpty blocks until queue is empty
+func (q *Queue[T]) WaitEmpty() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.length() != 0 {
+		q.empty.Wait()
+	}
 }
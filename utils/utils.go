@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
-	"sync"
+	"runtime"
+	"strconv"
+	"sync/atomic"
 	"time"
 	"unsafe"
 )
@@ -20,46 +23,38 @@ func UnsafeToBytes(s string) []byte {
 	return unsafe.Slice(ptr, len(s))
 }
 
-// Reference Counter
+// Reference Counter, whose zero value is ready to use
 type RC struct {
-	cnt   int
-	mutex *sync.Mutex
+	cnt atomic.Int64
 }
 
 func NewRC() *RC {
-	return &RC{
-		0, &sync.Mutex{},
-	}
+	return &RC{}
 }
 
 func (c *RC) Increase() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	c.cnt++
+	c.cnt.Add(1)
 }
 
-func (c *RC) Decrease() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	if c.cnt < 1 {
-		panic("RC cannot be negetive")
+// Decrease decreases counter by one and returns the new value
+func (c *RC) Decrease() int {
+	for {
+		cur := c.cnt.Load()
+		if cur < 1 {
+			panic("RC cannot be negetive")
+		}
+		if c.cnt.CompareAndSwap(cur, cur-1) {
+			return int(cur - 1)
+		}
 	}
-	c.cnt--
 }
 
 func (c *RC) Value() int {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	return c.cnt
+	return int(c.cnt.Load())
 }
 
 func (c *RC) Set(val int) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	c.cnt = val
+	c.cnt.Store(int64(val))
 }
 
 // NormalizeDuration normalize duration
@@ -95,3 +90,18 @@ func NormalizeDuration(d time.Duration) string {
 
 	return UnsafeToString(parts)
 }
+
+// GoroutineID returns id of the calling goroutine, parsed from the first line of its stack, "goroutine 1 [running]:"
+func GoroutineID() int {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	fields := bytes.Fields(buf)
+	if len(fields) < 2 {
+		return -1
+	}
+	id, err := strconv.Atoi(string(fields[1]))
+	if err != nil {
+		return -1
+	}
+	return id
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -106,3 +107,54 @@ func TestNormalizeDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestLIFOQueue(t *testing.T) {
+	q := NewLIFOQueue[int]()
+	for i := 0; i < 3; i++ {
+		q.Put(i)
+	}
+	if q.Peak() != 2 {
+		t.Errorf("expected peak 2, got %v", q.Peak())
+	}
+	for i := 2; i >= 0; i-- {
+		if v := q.PeakAndTake(); v != i {
+			t.Errorf("expected %v, got %v", i, v)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("expected empty queue, got %v", q.Len())
+	}
+}
+
+func TestQueueTakeEmpty(t *testing.T) {
+	for _, q := range []*Queue[*int]{NewQueue[*int](), NewLIFOQueue[*int]()} {
+		if v := q.PeakAndTake(); v != nil {
+			t.Errorf("expected nil from empty queue, got %v", v)
+		}
+		one := 1
+		q.Put(&one)
+		if v := q.PeakAndTake(); v != &one || q.PeakAndTake() != nil {
+			t.Errorf("expected element then nil, got %v", v)
+		}
+	}
+}
+
+func TestQueuePutWithLimit(t *testing.T) {
+	for _, q := range []*Queue[int]{NewQueue[int](), NewLIFOQueue[int]()} {
+		for i := 0; i < 2; i++ {
+			if err := q.PutWithLimit(i, 2); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+		}
+		if err := q.PutWithLimit(2, 2); !errors.Is(err, ErrQueueFull) || q.Len() != 2 {
+			t.Errorf("expected full queue of 2, got %v and %v elements", err, q.Len())
+		}
+		if err := q.PutWithLimit(2, 0); err != nil || q.Len() != 3 {
+			t.Errorf("expected no limit, got %v and %v elements", err, q.Len())
+		}
+		q.PeakAndTake()
+		if err := q.PutWithLimit(3, 3); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
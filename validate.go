@@ -0,0 +1,214 @@
+package gotaskflow
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Validate reports topologies that would hang executor:
+// cycles without any condition task, and joins fed by different branches of a looping condition.
+// Branches of a non-looping condition are fine, untaken ones are skipped and joins behave as weak dependencies.
+// It also reports conditions without successors or with successors outside their graph, see Task.ValidateCondition.
+func (tf *TaskFlow) Validate() error {
+	return tf.graph.validate()
+}
+
+func (g *eGraph) validate() error {
+	errs := make([]error, 0)
+	if cycle := g.strongCycle(); cycle != nil {
+		errs = append(errs, fmt.Errorf("cycle without condition in %v: %v", g.name, nodeNames(cycle)))
+	}
+
+	for _, n := range g.nodes {
+		if n.Typ != nodeCondition || !reachable(n.successors, n) {
+			continue
+		}
+		for _, join := range g.mixedJoins(n) {
+			errs = append(errs, fmt.Errorf("%v joins branches of looping condition %v in %v", join.name, n.name, g.name))
+		}
+	}
+
+	for _, n := range g.nodes {
+		if n.Typ == nodeCondition {
+			errs = append(errs, validateCondition(n, 0)...)
+		}
+	}
+
+	for _, n := range g.nodes {
+		if p, ok := n.ptr.(*Subflow); ok && p.g.instancelized {
+			if err := p.g.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("subflow %v -> %w", n.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidationError is a defect of a task found by validation, see TaskFlow.Validate and Task.ValidateCondition
+type ValidationError struct {
+	Graph  string // name of graph holding task, empty if task is not pushed yet
+	Task   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("task %v in %v: %v", e.Task, e.Graph, e.Reason)
+}
+
+// ValidateCondition checks that condition task has a successor for each value its predict func may return,
+// from 0 to maxReturn, which executor otherwise fails the run for. maxReturn is ignored for string conditions.
+// Validate checks every condition in the same way with maxReturn 0, as return values are unknown statically.
+// It returns ValidationError values joined.
+func (t *Task) ValidateCondition(maxReturn uint) error {
+	if t.node.Typ != nodeCondition {
+		return &ValidationError{Graph: graphName(t.node), Task: t.node.name, Reason: "not a condition"}
+	}
+	return errors.Join(validateCondition(t.node, maxReturn)...)
+}
+
+// validateCondition checks that successors of condition n cover 0 to maxReturn, and belong to the graph of n
+func validateCondition(n *innerNode, maxReturn uint) []error {
+	p := n.ptr.(*Condition)
+	invalid := func(format string, args ...any) error {
+		return &ValidationError{Graph: graphName(n), Task: n.name, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	errs := make([]error, 0)
+	branches := make(map[string]*innerNode)
+	if p.keyHandle != nil {
+		if len(p.stringMapper) == 0 {
+			errs = append(errs, invalid("string condition has no successor"))
+		}
+		for key, succ := range p.stringMapper {
+			branches[fmt.Sprintf("key %q", key)] = succ
+		}
+	} else {
+		for i := uint(0); i <= maxReturn; i++ {
+			if _, ok := p.mapper[i]; !ok {
+				errs = append(errs, invalid("no successor for return value %v, %v successors in total", i, len(p.mapper)))
+				break
+			}
+		}
+		for i, succ := range p.mapper {
+			branches[fmt.Sprintf("branch %v", i)] = succ
+		}
+	}
+
+	for branch, succ := range branches {
+		switch {
+		case succ == nil:
+			errs = append(errs, invalid("%v is nil", branch))
+		case succ.g != n.g:
+			errs = append(errs, invalid("%v %v belongs to graph %v", branch, succ.name, graphName(succ)))
+		}
+	}
+	slices.SortFunc(errs, func(a, b error) int { return cmp.Compare(a.Error(), b.Error()) })
+	return errs
+}
+
+func graphName(n *innerNode) string {
+	if n.g == nil {
+		return ""
+	}
+	return n.g.name
+}
+
+// strongCycle returns a cycle made of strong edges only, which never completes
+func (g *eGraph) strongCycle() []*innerNode {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	color := make(map[*innerNode]int, len(g.nodes))
+	stack := make([]*innerNode, 0)
+
+	var dfs func(n *innerNode) []*innerNode
+	dfs = func(n *innerNode) []*innerNode {
+		color[n] = visiting
+		stack = append(stack, n)
+		if n.Typ != nodeCondition {
+			for _, succ := range n.successors {
+				switch color[succ] {
+				case visiting:
+					for i, s := range stack {
+						if s == succ {
+							return append(stack[i:], succ)
+						}
+					}
+				case unvisited:
+					if cycle := dfs(succ); cycle != nil {
+						return cycle
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		color[n] = visited
+		return nil
+	}
+
+	for _, n := range g.nodes {
+		if color[n] == unvisited {
+			if cycle := dfs(n); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// mixedJoins returns nodes strongly depending on more than one branch of cond
+func (g *eGraph) mixedJoins(cond *innerNode) []*innerNode {
+	branches := make([]map[*innerNode]struct{}, 0, len(cond.successors))
+	for _, succ := range cond.successors {
+		branches = append(branches, strongReach(succ))
+	}
+
+	joins := make([]*innerNode, 0)
+	for _, n := range g.nodes {
+		fed := 0
+		for _, branch := range branches {
+			for _, dep := range n.dependents {
+				if _, ok := branch[dep]; ok && dep.Typ != nodeCondition {
+					fed++
+					break
+				}
+			}
+		}
+		if fed > 1 {
+			joins = append(joins, n)
+		}
+	}
+	return joins
+}
+
+// strongReach returns nodes reachable from n following strong edges, including n
+func strongReach(n *innerNode) map[*innerNode]struct{} {
+	reached := map[*innerNode]struct{}{n: {}}
+	stack := []*innerNode{n}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if cur.Typ == nodeCondition {
+			continue
+		}
+		for _, succ := range cur.successors {
+			if _, ok := reached[succ]; !ok {
+				reached[succ] = struct{}{}
+				stack = append(stack, succ)
+			}
+		}
+	}
+	return reached
+}
+
+func nodeNames(nodes []*innerNode) []string {
+	names := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		names = append(names, n.name)
+	}
+	return names
+}
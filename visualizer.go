@@ -72,8 +72,8 @@ func (v *visualizer) visualizeG(gv *graphviz.Graphviz, g *eGraph, parentG *cgrap
 			// fmt.Printf("add edge %v - %v\n", deps.name, node.name)
 			label := ""
 			style := cgraph.SolidEdgeStyle
-			if _, ok := node.ptr.(*Condition); ok {
-				label = fmt.Sprintf("%d", idx)
+			if cond, ok := node.ptr.(*Condition); ok {
+				label = cond.label(idx, deps)
 				style = cgraph.DashedEdgeStyle
 			}
 
@@ -83,14 +83,35 @@ func (v *visualizer) visualizeG(gv *graphviz.Graphviz, g *eGraph, parentG *cgrap
 			}
 			edge.SetLabel(label)
 			edge.SetStyle(style)
+			if cond, ok := node.ptr.(*Condition); ok && cond.taken(deps) {
+				// highlight branch taken in latest run
+				edge.SetColor("red")
+				edge.SetPenWidth(2)
+			}
+
+		}
+	}
 
+	// tasks of a layer share a rank, subflows are left to their clusters, which rank their own tasks
+	for i, layer := range g.rankLayers() {
+		rank := vGraph.SubGraph(fmt.Sprintf("rank_%v_%d", g.name, i), 1)
+		rank.SafeSet("rank", "same", "")
+		for _, node := range layer {
+			if _, ok := node.ptr.(*Subflow); ok {
+				continue
+			}
+			if _, err := rank.CreateNode(node.name); err != nil {
+				return fmt.Errorf("rank node %v -> %w", node.name, err)
+			}
 		}
 	}
 
 	return nil
 }
 
-// Visualize generate raw dag text in dot format and write to writer
+// Visualize generate raw dag text in dot format and write to writer.
+// Tasks are ranked by topological layer, so that tasks of a layer are drawn in a column, see Layers.
+// Condition loops do not break ranking, the edge closing a loop points backwards.
 func Visualize(tf *TaskFlow, writer io.Writer) error {
 	gv := graphviz.New()
 	defer gv.Close()
@@ -0,0 +1,48 @@
+package gotaskflow
+
+import (
+	"reflect"
+
+	"github.com/noneback/go-taskflow/utils"
+)
+
+// WithWorkerLocalStorage gives each pool worker a value of T created by factory before the worker runs its first task,
+// for state too costly to create per task which must not be shared by tasks running at once, like connections.
+// Tasks get the value of their worker by WorkerLocal. factory must not panic, and a later storage of the same T wins.
+func WithWorkerLocalStorage[T any](factory func() T) Option {
+	return func(e *innerExecutorImpl) {
+		if factory == nil {
+			e.invalid("worker local storage factory cannot be nil")
+			return
+		}
+		e.locals[typeOf[T]()] = func() any { return factory() }
+	}
+}
+
+// WorkerLocal returns the value of T of pool worker running the calling task, see WithWorkerLocalStorage.
+// It returns false if the caller is not a task, or executor has no storage of T.
+func WorkerLocal[T any]() (T, bool) {
+	var zero T
+	local, ok := utils.Local()
+	if !ok {
+		return zero, false
+	}
+	v, ok := local.(map[reflect.Type]any)[typeOf[T]()]
+	if !ok {
+		return zero, false
+	}
+	return v.(T), true
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// workerInit creates values of all storages for a worker
+func (e *innerExecutorImpl) workerInit() any {
+	values := make(map[reflect.Type]any, len(e.locals))
+	for typ, factory := range e.locals {
+		values[typ] = factory()
+	}
+	return values
+}
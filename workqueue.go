@@ -0,0 +1,67 @@
+package gotaskflow
+
+import (
+	"sync"
+
+	"github.com/noneback/go-taskflow/utils"
+)
+
+// WorkQueue holds scheduled tasks until they are dispatched to pool, see WithWorkQueue.
+// It is called by many goroutines at once, so it must be thread safe. PeakAndTake returns nil if queue is empty,
+// as graphs sharing executor race to take tasks.
+type WorkQueue interface {
+	Put(task *Task)
+	PeakAndTake() *Task
+	Len() int
+}
+
+// customQueue adapts a WorkQueue given by user to executor
+type customQueue struct {
+	q     WorkQueue
+	mu    *sync.Mutex
+	empty *sync.Cond
+}
+
+func newCustomQueue(q WorkQueue) *customQueue {
+	mu := &sync.Mutex{}
+	return &customQueue{q: q, mu: mu, empty: sync.NewCond(mu)}
+}
+
+func (q *customQueue) Put(node *innerNode) {
+	q.q.Put(&Task{node: node})
+}
+
+// PutWithLimit checks length of q ahead of putting, so that limit may be exceeded by tasks put at once
+func (q *customQueue) PutWithLimit(node *innerNode, limit int) error {
+	if limit > 0 && q.q.Len() >= limit {
+		return utils.ErrQueueFull
+	}
+	q.Put(node)
+	return nil
+}
+
+func (q *customQueue) PeakAndTake() *innerNode {
+	t := q.q.PeakAndTake()
+	if q.q.Len() == 0 {
+		q.mu.Lock()
+		q.empty.Broadcast()
+		q.mu.Unlock()
+	}
+	if t == nil {
+		return nil
+	}
+	return t.node
+}
+
+func (q *customQueue) Len() int32 {
+	return int32(q.q.Len())
+}
+
+// WaitEmpty blocks until queue is empty
+func (q *customQueue) WaitEmpty() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.q.Len() != 0 {
+		q.empty.Wait()
+	}
+}